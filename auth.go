@@ -0,0 +1,86 @@
+package httplib
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/cmstar/go-httplib/headers"
+)
+
+// WithAuthScheme sets the Authorization header to '<scheme> <credentials>', replaces the existing one.
+// If the credentials is empty, the header is the scheme only.
+//
+// The credentials set by this method, WithBasicAuth() and WithBearerToken() are always redacted in the output
+// of Curl() and Dumper, in the HAR files recorded by the har package and in the cassettes of the vcr package.
+// Such requests are marked in the context, see HasCredentials().
+func (x *RequestBuilder) WithAuthScheme(scheme, credentials string) *RequestBuilder {
+	x.header.Set(headers.Authorization, authValue(scheme, credentials))
+	x.auth = true
+	return x
+}
+
+// WithBasicAuth sets the Authorization header with the Basic scheme (RFC 7617), see WithAuthScheme().
+func (x *RequestBuilder) WithBasicAuth(username, password string) *RequestBuilder {
+	return x.WithAuthScheme("Basic", basicCredentials(username, password))
+}
+
+// WithBearerToken sets the Authorization header with the Bearer scheme (RFC 6750), see WithAuthScheme().
+func (x *RequestBuilder) WithBearerToken(token string) *RequestBuilder {
+	return x.WithAuthScheme("Bearer", token)
+}
+
+// WithAuthScheme sets the default Authorization header to '<scheme> <credentials>', replaces the existing one.
+// Like other default headers, it is applied to every request whose builder does not have an Authorization header.
+// See RequestBuilder.WithAuthScheme() for the redaction.
+func (c *Client) WithAuthScheme(scheme, credentials string) *Client {
+	c.header.Set(headers.Authorization, authValue(scheme, credentials))
+	c.auth = true
+	return c
+}
+
+// WithBasicAuth sets the default Authorization header with the Basic scheme, see Client.WithAuthScheme().
+func (c *Client) WithBasicAuth(username, password string) *Client {
+	return c.WithAuthScheme("Basic", basicCredentials(username, password))
+}
+
+// WithBearerToken sets the default Authorization header with the Bearer scheme, see Client.WithAuthScheme().
+func (c *Client) WithBearerToken(token string) *Client {
+	return c.WithAuthScheme("Bearer", token)
+}
+
+// hasCredentials returns true if the Authorization header of the request is set by WithAuthScheme() and the like,
+// either of the builder or of the Client.
+func (x *RequestBuilder) hasCredentials() bool {
+	if x.auth {
+		return true
+	}
+	return x.Client().auth && len(x.header.Values(headers.Authorization)) == 0
+}
+
+// credentialsKey is the key of the context value which marks the requests whose Authorization header
+// is set by WithAuthScheme() and the like.
+type credentialsKey struct{}
+
+func withCredentials(ctx context.Context) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, true)
+}
+
+// HasCredentials returns true if the Authorization header of the request is set by RequestBuilder.WithAuthScheme(),
+// WithBasicAuth() or WithBearerToken(), by the same methods of Client, or by a DigestAuth. The outputs such as dumps
+// should redact the header of such requests.
+func HasCredentials(req *http.Request) bool {
+	marked, _ := req.Context().Value(credentialsKey{}).(bool)
+	return marked
+}
+
+func authValue(scheme, credentials string) string {
+	if credentials == "" {
+		return scheme
+	}
+	return scheme + " " + credentials
+}
+
+func basicCredentials(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
@@ -0,0 +1,501 @@
+package httplib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cmstar/go-httplib/headers"
+)
+
+// The values of the Cache-Status header set on the responses served by a Cache.
+const (
+	// CacheStatusHit means the response is served from the cache without contacting the server.
+	CacheStatusHit = "httplib; hit"
+
+	// CacheStatusRevalidated means the cached response is validated by the server with a 304 Not Modified.
+	CacheStatusRevalidated = "httplib; fwd=stale; fwd-status=304"
+
+	// CacheStatusStale means the server failed and a stale response is served, which is allowed by stale-if-error.
+	CacheStatusStale = "httplib; hit; detail=stale-if-error"
+)
+
+// Cache is a private HTTP cache which follows the semantics of [RFC 9111]. It is used as a Middleware,
+// see Client.WithCache().
+//
+// Only the responses of GET requests are stored. The entries are keyed by the URL and the values of
+// the request headers listed in the Vary header of the response. The Cache honors:
+//   - the Cache-Control directives of the requests and the responses, see headers.ParseCacheControl();
+//   - the Expires, Date and Age headers for computing the freshness;
+//   - the heuristic freshness based on Last-Modified;
+//   - the validators ETag and Last-Modified, a stale response is revalidated with If-None-Match/If-Modified-Since;
+//   - the stale-if-error directive ([RFC 5861]), a stale response is served if the server fails.
+//
+// The requests with their own conditional headers or a Range header are not handled by the Cache.
+// A successful response to a POST/PUT/PATCH/DELETE request invalidates the entry of the URL.
+//
+// The responses served by the Cache have a Cache-Status header, see CacheStatusHit and others.
+// The body of a response is read into memory before it is stored.
+//
+// [RFC 9111]: https://datatracker.ietf.org/doc/html/rfc9111
+// [RFC 5861]: https://datatracker.ietf.org/doc/html/rfc5861
+type Cache struct {
+	storage CacheStorage
+}
+
+// NewCache creates a new instance of Cache with the given storage.
+func NewCache(storage CacheStorage) *Cache {
+	return &Cache{storage}
+}
+
+// Storage returns the storage of the Cache.
+func (c *Cache) Storage() CacheStorage {
+	return c.storage
+}
+
+// WithCache appends the Middleware of the Cache to the Client.
+func (c *Client) WithCache(cache *Cache) *Client {
+	return c.WithMiddleware(cache.Middleware())
+}
+
+// Middleware returns the Middleware which serves the responses from the Cache.
+func (c *Cache) Middleware() Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return c.do(next, req)
+		})
+	}
+}
+
+// cacheEntry is stored in the CacheStorage as JSON.
+//
+// If the response has a Vary header, an entry which only contains Vary is stored with the primary key,
+// and the response is stored with the key which contains the values of the request headers listed in Vary.
+type cacheEntry struct {
+	Vary         []string  `json:"vary,omitempty"`
+	RequestTime  time.Time `json:"requestTime,omitempty"`
+	ResponseTime time.Time `json:"responseTime,omitempty"`
+	Response     []byte    `json:"response,omitempty"` // Dumped by httputil.DumpResponse().
+}
+
+// cachedResponse is a response loaded from the Cache, with the body buffered.
+type cachedResponse struct {
+	key   string
+	entry *cacheEntry
+	res   *http.Response
+	body  []byte
+	cc    *headers.CacheControlDirectives
+}
+
+func (c *Cache) do(next Doer, req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" {
+		res, err := next.Do(req)
+		if err == nil && isUnsafeMethod(req.Method) && res.StatusCode < 400 {
+			c.storage.Delete(cachePrimaryKey(req))
+		}
+		return res, err
+	}
+
+	for _, name := range []string{headers.IfNoneMatch, headers.IfModifiedSince, headers.IfMatch, headers.IfUnmodifiedSince, headers.Range} {
+		if req.Header.Get(name) != "" {
+			return next.Do(req)
+		}
+	}
+
+	reqCC := requestCacheControl(req)
+	if reqCC.NoStore {
+		return next.Do(req)
+	}
+
+	cached := c.load(req)
+	if cached == nil {
+		if reqCC.OnlyIfCached {
+			return gatewayTimeoutResponse(req), nil
+		}
+
+		requestTime := time.Now()
+		res, err := next.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		return c.store(req, res, reqCC, requestTime)
+	}
+
+	now := time.Now()
+	age := cached.currentAge(now)
+	lifetime := cached.freshnessLifetime()
+
+	if canServeCached(reqCC, cached.cc, age, lifetime) {
+		return cached.serve(req, CacheStatusHit, age), nil
+	}
+
+	if reqCC.OnlyIfCached {
+		return gatewayTimeoutResponse(req), nil
+	}
+
+	// Revalidate the stored response.
+	condReq := req.Clone(req.Context())
+	if etag := cached.res.Header.Get(headers.ETag); etag != "" {
+		condReq.Header.Set(headers.IfNoneMatch, etag)
+	}
+	if lastModified := cached.res.Header.Get(headers.LastModified); lastModified != "" {
+		condReq.Header.Set(headers.IfModifiedSince, lastModified)
+	}
+
+	requestTime := time.Now()
+	res, err := next.Do(condReq)
+
+	if err != nil || res.StatusCode >= 500 {
+		if canServeStaleOnError(reqCC, cached.cc, age, lifetime) {
+			if res != nil {
+				drainAndClose(res.Body)
+			}
+			return cached.serve(req, CacheStatusStale, age), nil
+		}
+		return res, err
+	}
+
+	if res.StatusCode != http.StatusNotModified {
+		return c.store(req, res, reqCC, requestTime)
+	}
+
+	// 304 Not Modified, update the stored response with the new header fields. See RFC 9111 section 4.3.4 .
+	drainAndClose(res.Body)
+	for k, v := range res.Header {
+		if k == "Content-Length" {
+			continue
+		}
+		cached.res.Header[k] = v
+	}
+
+	cached.entry.RequestTime = requestTime
+	cached.entry.ResponseTime = time.Now()
+	c.save(cached.key, cached.entry, cached.res, cached.body)
+	return cached.serve(req, CacheStatusRevalidated, cached.currentAge(time.Now())), nil
+}
+
+// store saves the response if it is storable, returns a response which can be read by the caller.
+func (c *Cache) store(req *http.Request, res *http.Response, reqCC *headers.CacheControlDirectives, requestTime time.Time) (*http.Response, error) {
+	responseTime := time.Now()
+	resCC := responseCacheControl(res)
+
+	if !isStorable(res, reqCC, resCC) {
+		return res, nil
+	}
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	key := cachePrimaryKey(req)
+	vary := varyHeaders(res)
+	if len(vary) > 0 {
+		index, _ := json.Marshal(&cacheEntry{Vary: vary})
+		c.storage.Set(key, index)
+		key = cacheVaryKey(key, vary, req)
+	}
+
+	entry := &cacheEntry{
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+	}
+	c.save(key, entry, res, body)
+
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
+
+func (c *Cache) save(key string, entry *cacheEntry, res *http.Response, body []byte) {
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	dump, err := httputil.DumpResponse(res, true)
+	if err != nil {
+		return
+	}
+
+	entry.Response = dump
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	c.storage.Set(key, data)
+}
+
+// load returns the stored response of the request, or nil if it is not found.
+func (c *Cache) load(req *http.Request) *cachedResponse {
+	key := cachePrimaryKey(req)
+	entry := c.loadEntry(key)
+	if entry == nil {
+		return nil
+	}
+
+	if len(entry.Vary) > 0 {
+		key = cacheVaryKey(key, entry.Vary, req)
+		entry = c.loadEntry(key)
+		if entry == nil {
+			return nil
+		}
+	}
+
+	res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(entry.Response)), req)
+	if err != nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil
+	}
+
+	return &cachedResponse{
+		key:   key,
+		entry: entry,
+		res:   res,
+		body:  body,
+		cc:    responseCacheControl(res),
+	}
+}
+
+func (c *Cache) loadEntry(key string) *cacheEntry {
+	data, ok := c.storage.Get(key)
+	if !ok {
+		return nil
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil
+	}
+
+	if len(entry.Vary) == 0 && len(entry.Response) == 0 {
+		return nil
+	}
+	return &entry
+}
+
+// serve returns the cached response for the request.
+func (r *cachedResponse) serve(req *http.Request, status string, age time.Duration) *http.Response {
+	res := r.res
+	res.Request = req
+	res.Body = io.NopCloser(bytes.NewReader(r.body))
+	res.Header.Set(headers.Age, strconv.FormatInt(int64(age/time.Second), 10))
+	res.Header.Set(headers.CacheStatus, status)
+	return res
+}
+
+// currentAge computes the age of the response, see RFC 9111 section 4.2.3 .
+func (r *cachedResponse) currentAge(now time.Time) time.Duration {
+	dateValue := r.date()
+	apparentAge := r.entry.ResponseTime.Sub(dateValue)
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+
+	var ageValue time.Duration
+	if v, err := strconv.ParseInt(r.res.Header.Get(headers.Age), 10, 64); err == nil && v > 0 {
+		ageValue = time.Duration(v) * time.Second
+	}
+
+	responseDelay := r.entry.ResponseTime.Sub(r.entry.RequestTime)
+	correctedAgeValue := ageValue + responseDelay
+
+	correctedInitialAge := apparentAge
+	if correctedAgeValue > correctedInitialAge {
+		correctedInitialAge = correctedAgeValue
+	}
+
+	residentTime := now.Sub(r.entry.ResponseTime)
+	return correctedInitialAge + residentTime
+}
+
+// freshnessLifetime computes the freshness lifetime of the response, see RFC 9111 section 4.2.1 .
+func (r *cachedResponse) freshnessLifetime() time.Duration {
+	if r.cc.MaxAge != nil {
+		return time.Duration(*r.cc.MaxAge) * time.Second
+	}
+
+	if expires := r.res.Header.Get(headers.Expires); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0 // An invalid Expires means already expired.
+		}
+		return t.Sub(r.date())
+	}
+
+	// Heuristic freshness, 10% of the time since the last modification. See RFC 9111 section 4.2.2 .
+	if isHeuristicallyCacheable(r.res.StatusCode) {
+		if t, err := http.ParseTime(r.res.Header.Get(headers.LastModified)); err == nil {
+			if d := r.date().Sub(t); d > 0 {
+				return d / 10
+			}
+		}
+	}
+
+	return 0
+}
+
+// date returns the value of the Date header, or the response time if the header is absent or invalid.
+func (r *cachedResponse) date() time.Time {
+	if t, err := http.ParseTime(r.res.Header.Get(headers.Date)); err == nil {
+		return t
+	}
+	return r.entry.ResponseTime
+}
+
+func canServeCached(reqCC, resCC *headers.CacheControlDirectives, age, lifetime time.Duration) bool {
+	if reqCC.NoCache || resCC.NoCache {
+		return false
+	}
+
+	if reqCC.MaxAge != nil && age > time.Duration(*reqCC.MaxAge)*time.Second {
+		return false
+	}
+
+	if reqCC.MinFresh != nil && lifetime-age < time.Duration(*reqCC.MinFresh)*time.Second {
+		return false
+	}
+
+	if lifetime > age {
+		return true
+	}
+
+	// Stale.
+	if resCC.MustRevalidate || reqCC.MaxStale == nil {
+		return false
+	}
+	return *reqCC.MaxStale < 0 || age-lifetime <= time.Duration(*reqCC.MaxStale)*time.Second
+}
+
+func canServeStaleOnError(reqCC, resCC *headers.CacheControlDirectives, age, lifetime time.Duration) bool {
+	if resCC.MustRevalidate {
+		return false
+	}
+
+	staleness := age - lifetime
+	for _, v := range []*int{reqCC.StaleIfError, resCC.StaleIfError} {
+		if v != nil && staleness <= time.Duration(*v)*time.Second {
+			return true
+		}
+	}
+	return false
+}
+
+func isStorable(res *http.Response, reqCC, resCC *headers.CacheControlDirectives) bool {
+	if reqCC.NoStore || resCC.NoStore {
+		return false
+	}
+
+	for _, name := range varyHeaders(res) {
+		if name == "*" {
+			return false
+		}
+	}
+
+	hasExplicitFreshness := resCC.MaxAge != nil || res.Header.Get(headers.Expires) != ""
+	if !hasExplicitFreshness && !isHeuristicallyCacheable(res.StatusCode) {
+		return false
+	}
+
+	// A response which can never be fresh and has no validators is useless.
+	hasValidator := res.Header.Get(headers.ETag) != "" || res.Header.Get(headers.LastModified) != ""
+	return hasExplicitFreshness || hasValidator
+}
+
+// isHeuristicallyCacheable returns true for the status codes defined as heuristically cacheable in RFC 9110 section 15.1 .
+func isHeuristicallyCacheable(code int) bool {
+	switch code {
+	case 200, 203, 204, 300, 301, 308, 404, 405, 410, 414, 501:
+		return true
+	}
+	return false
+}
+
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return false
+	}
+	return true
+}
+
+func requestCacheControl(req *http.Request) *headers.CacheControlDirectives {
+	values := req.Header.Values(headers.CacheControl)
+	cc := parseCacheControlValues(values)
+
+	// Pragma: no-cache is used only if there is no Cache-Control. See RFC 9111 section 5.4 .
+	if len(values) == 0 && strings.Contains(strings.ToLower(req.Header.Get(headers.Pragma)), "no-cache") {
+		cc.NoCache = true
+	}
+	return cc
+}
+
+func responseCacheControl(res *http.Response) *headers.CacheControlDirectives {
+	return parseCacheControlValues(res.Header.Values(headers.CacheControl))
+}
+
+// parseCacheControlValues parses the Cache-Control header, an invalid value is considered as no-cache.
+func parseCacheControlValues(values []string) *headers.CacheControlDirectives {
+	cc, err := headers.ParseCacheControl(strings.Join(values, ", "))
+	if err != nil {
+		return &headers.CacheControlDirectives{NoCache: true}
+	}
+	return cc
+}
+
+// varyHeaders returns the canonical names of the headers listed in the Vary header, sorted.
+func varyHeaders(res *http.Response) []string {
+	var names []string
+	for _, v := range res.Header.Values(headers.Vary) {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func cachePrimaryKey(req *http.Request) string {
+	return "GET " + req.URL.String()
+}
+
+func cacheVaryKey(primaryKey string, vary []string, req *http.Request) string {
+	var b strings.Builder
+	b.WriteString(primaryKey)
+	for _, name := range vary {
+		b.WriteString("\n")
+		b.WriteString(name)
+		b.WriteString(": ")
+		b.WriteString(strings.Join(req.Header.Values(name), ", "))
+	}
+	return b.String()
+}
+
+func gatewayTimeoutResponse(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     "504 Gateway Timeout",
+		StatusCode: http.StatusGatewayTimeout,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+// drainAndClose reads at most maxDrainSize bytes of the body and closes it.
+func drainAndClose(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, maxDrainSize))
+	body.Close()
+}
@@ -0,0 +1,166 @@
+package httplib
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheStorage stores the entries of a Cache. The implementations must be safe for concurrent use.
+//
+// The storage is best effort, an implementation can drop entries at any time, e.g. to limit the size,
+// and should ignore the errors of the underlying storage.
+type CacheStorage interface {
+	// Get returns the data stored with the key. ok is false if the key is not found.
+	Get(key string) (data []byte, ok bool)
+
+	// Set stores the data with the key, overwrites the existing one.
+	Set(key string, data []byte)
+
+	// Delete removes the data stored with the key. It does nothing if the key is not found.
+	Delete(key string)
+}
+
+// MemoryCacheStorage is a CacheStorage which keeps the entries in memory,
+// the least recently used entries are dropped when the number of entries reaches the limit.
+type MemoryCacheStorage struct {
+	maxEntries int
+
+	mu    sync.Mutex
+	list  *list.List // Front is the most recently used.
+	items map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key  string
+	data []byte
+}
+
+var _ CacheStorage = (*MemoryCacheStorage)(nil)
+
+// NewMemoryCacheStorage creates a new instance of MemoryCacheStorage. maxEntries is the max number of entries,
+// zero or a negative value means no limit.
+func NewMemoryCacheStorage(maxEntries int) *MemoryCacheStorage {
+	return &MemoryCacheStorage{
+		maxEntries: maxEntries,
+		list:       list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get implements CacheStorage.Get().
+func (s *MemoryCacheStorage) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	s.list.MoveToFront(e)
+	return e.Value.(*memoryCacheItem).data, true
+}
+
+// Set implements CacheStorage.Set().
+func (s *MemoryCacheStorage) Set(key string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.items[key]; ok {
+		e.Value.(*memoryCacheItem).data = data
+		s.list.MoveToFront(e)
+		return
+	}
+
+	s.items[key] = s.list.PushFront(&memoryCacheItem{key, data})
+
+	if s.maxEntries > 0 && s.list.Len() > s.maxEntries {
+		oldest := s.list.Back()
+		s.list.Remove(oldest)
+		delete(s.items, oldest.Value.(*memoryCacheItem).key)
+	}
+}
+
+// Delete implements CacheStorage.Delete().
+func (s *MemoryCacheStorage) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.items[key]; ok {
+		s.list.Remove(e)
+		delete(s.items, key)
+	}
+}
+
+// Len returns the number of entries.
+func (s *MemoryCacheStorage) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list.Len()
+}
+
+// DiskCacheStorage is a CacheStorage which keeps each entry in a file in a directory.
+// The file name is the SHA-256 hash of the key.
+type DiskCacheStorage struct {
+	dir string
+}
+
+var _ CacheStorage = (*DiskCacheStorage)(nil)
+
+// NewDiskCacheStorage creates a new instance of DiskCacheStorage, the directory is created if it does not exist.
+func NewDiskCacheStorage(dir string) (*DiskCacheStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DiskCacheStorage{dir}, nil
+}
+
+// Dir returns the directory of the files.
+func (s *DiskCacheStorage) Dir() string {
+	return s.dir
+}
+
+// Get implements CacheStorage.Get().
+func (s *DiskCacheStorage) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Set implements CacheStorage.Set(). The file is written to a temporary file first, then renamed,
+// so that the readers never get a partially written file.
+func (s *DiskCacheStorage) Set(key string, data []byte) {
+	f, err := os.CreateTemp(s.dir, "tmp-*")
+	if err != nil {
+		return
+	}
+
+	_, err = f.Write(data)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), s.path(key))
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+// Delete implements CacheStorage.Delete().
+func (s *DiskCacheStorage) Delete(key string) {
+	os.Remove(s.path(key))
+}
+
+func (s *DiskCacheStorage) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
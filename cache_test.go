@@ -0,0 +1,404 @@
+package httplib_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/cmstar/go-httplib/headers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cacheTestHandler responds with the given headers, the body is 'body-<n>' where n is the number of the request,
+// starts from 1. It responds 304 if the request has If-None-Match/If-Modified-Since matching the ETag/Last-Modified.
+func cacheTestHandler(status int, header ...string) http.HandlerFunc {
+	n := 0
+	return func(w http.ResponseWriter, r *http.Request) {
+		n++
+		for i := 0; i < len(header); i += 2 {
+			w.Header().Set(header[i], header[i+1])
+		}
+
+		etag := w.Header().Get("ETag")
+		lastModified := w.Header().Get("Last-Modified")
+		if (etag != "" && r.Header.Get("If-None-Match") == etag) ||
+			(lastModified != "" && r.Header.Get("If-Modified-Since") == lastModified) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "body-%d", n)
+	}
+}
+
+func newCacheTestClient(s *SequenceServer) *httplib.Client {
+	cache := httplib.NewCache(httplib.NewMemoryCacheStorage(100))
+	return httplib.NewClient(s.URL).WithCache(cache)
+}
+
+func sendCacheTest(t *testing.T, c *httplib.Client, header ...string) *httplib.Response {
+	b := c.NewBuilder("GET", "/path")
+	for i := 0; i < len(header); i += 2 {
+		b.WithHeader(header[i], header[i+1])
+	}
+
+	res, err := b.Send()
+	require.NoError(t, err)
+	return res
+}
+
+func TestCache(t *testing.T) {
+	t.Run("fresh", func(t *testing.T) {
+		s := NewSequenceServer(cacheTestHandler(200, "Cache-Control", "max-age=60"))
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		res := sendCacheTest(t, c)
+		assert.Equal(t, "body-1", res.String())
+		assert.Equal(t, "", res.Header().Get(headers.CacheStatus))
+
+		res = sendCacheTest(t, c)
+		assert.Equal(t, 200, res.StatusCode())
+		assert.Equal(t, "body-1", res.String())
+		assert.Equal(t, httplib.CacheStatusHit, res.Header().Get(headers.CacheStatus))
+		assert.Equal(t, "0", res.Header().Get(headers.Age))
+		assert.Equal(t, 1, s.Count())
+	})
+
+	t.Run("no-store", func(t *testing.T) {
+		s := NewSequenceServer(cacheTestHandler(200, "Cache-Control", "max-age=60, no-store"))
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+		assert.Equal(t, "body-2", sendCacheTest(t, c).String())
+	})
+
+	t.Run("request-no-store", func(t *testing.T) {
+		s := NewSequenceServer(cacheTestHandler(200, "Cache-Control", "max-age=60"))
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c, "Cache-Control", "no-store").String())
+		assert.Equal(t, "body-2", sendCacheTest(t, c).String())
+		assert.Equal(t, "body-2", sendCacheTest(t, c).String())
+	})
+
+	t.Run("not-storable-status", func(t *testing.T) {
+		s := NewSequenceServer(cacheTestHandler(500, "ETag", `"v1"`))
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+		assert.Equal(t, "body-2", sendCacheTest(t, c).String())
+	})
+
+	t.Run("revalidate-etag", func(t *testing.T) {
+		s := NewSequenceServer(cacheTestHandler(200, "Cache-Control", "max-age=0", "ETag", `"v1"`))
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+
+		res := sendCacheTest(t, c)
+		assert.Equal(t, 200, res.StatusCode())
+		assert.Equal(t, "body-1", res.String())
+		assert.Equal(t, httplib.CacheStatusRevalidated, res.Header().Get(headers.CacheStatus))
+		assert.Equal(t, 2, s.Count())
+	})
+
+	t.Run("revalidate-last-modified", func(t *testing.T) {
+		lastModified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+		s := NewSequenceServer(cacheTestHandler(200, "Cache-Control", "no-cache", "Last-Modified", lastModified))
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+
+		res := sendCacheTest(t, c)
+		assert.Equal(t, "body-1", res.String())
+		assert.Equal(t, httplib.CacheStatusRevalidated, res.Header().Get(headers.CacheStatus))
+		assert.Equal(t, 2, s.Count())
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		etag := `"v1"`
+		s := NewSequenceServer(
+			cacheTestHandler(200, "Cache-Control", "max-age=0", "ETag", etag),
+			cacheTestHandler(200, "Cache-Control", "max-age=60", "ETag", `"v2"`),
+		)
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String()) // The second handler counts from 1.
+
+		res := sendCacheTest(t, c)
+		assert.Equal(t, httplib.CacheStatusHit, res.Header().Get(headers.CacheStatus))
+		assert.Equal(t, `"v2"`, res.Header().Get("ETag"))
+		assert.Equal(t, 2, s.Count())
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+		s := NewSequenceServer(cacheTestHandler(200, "Expires", future))
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+		assert.Equal(t, 1, s.Count())
+	})
+
+	t.Run("expires-past", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+		s := NewSequenceServer(cacheTestHandler(200, "Expires", past))
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+		assert.Equal(t, "body-2", sendCacheTest(t, c).String())
+	})
+
+	t.Run("age", func(t *testing.T) {
+		s := NewSequenceServer(cacheTestHandler(200, "Cache-Control", "max-age=60", "Age", "100"))
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+		assert.Equal(t, "body-2", sendCacheTest(t, c).String())
+	})
+
+	t.Run("heuristic", func(t *testing.T) {
+		lastModified := time.Now().Add(-10 * 24 * time.Hour).UTC().Format(http.TimeFormat)
+		s := NewSequenceServer(cacheTestHandler(200, "Last-Modified", lastModified))
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+		assert.Equal(t, 1, s.Count())
+	})
+
+	t.Run("vary", func(t *testing.T) {
+		s := NewSequenceServer(cacheTestHandler(200, "Cache-Control", "max-age=60", "Vary", "Accept-Language"))
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c, "Accept-Language", "en").String())
+		assert.Equal(t, "body-2", sendCacheTest(t, c, "Accept-Language", "zh").String())
+		assert.Equal(t, "body-1", sendCacheTest(t, c, "Accept-Language", "en").String())
+		assert.Equal(t, "body-2", sendCacheTest(t, c, "Accept-Language", "zh").String())
+		assert.Equal(t, "body-3", sendCacheTest(t, c).String())
+		assert.Equal(t, 3, s.Count())
+	})
+
+	t.Run("vary-star", func(t *testing.T) {
+		s := NewSequenceServer(cacheTestHandler(200, "Cache-Control", "max-age=60", "Vary", "*"))
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+		assert.Equal(t, "body-2", sendCacheTest(t, c).String())
+	})
+
+	t.Run("stale-if-error", func(t *testing.T) {
+		s := NewSequenceServer(
+			cacheTestHandler(200, "Cache-Control", "max-age=0, stale-if-error=60"),
+			cacheTestHandler(503),
+		)
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+
+		res := sendCacheTest(t, c)
+		assert.Equal(t, 200, res.StatusCode())
+		assert.Equal(t, "body-1", res.String())
+		assert.Equal(t, httplib.CacheStatusStale, res.Header().Get(headers.CacheStatus))
+		assert.Equal(t, 2, s.Count())
+	})
+
+	t.Run("stale-if-error-not-allowed", func(t *testing.T) {
+		s := NewSequenceServer(
+			cacheTestHandler(200, "Cache-Control", "max-age=0, must-revalidate, stale-if-error=60"),
+			cacheTestHandler(503),
+		)
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+		assert.Equal(t, 503, sendCacheTest(t, c).StatusCode())
+	})
+
+	t.Run("stale-if-error-request", func(t *testing.T) {
+		s := NewSequenceServer(
+			cacheTestHandler(200, "Cache-Control", "max-age=0", "ETag", `"v1"`),
+			closeConnHandler,
+		)
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+
+		_, err := c.NewBuilder("GET", "/path").Send()
+		assert.Error(t, err)
+
+		res := sendCacheTest(t, c, "Cache-Control", "stale-if-error=60")
+		assert.Equal(t, "body-1", res.String())
+		assert.Equal(t, httplib.CacheStatusStale, res.Header().Get(headers.CacheStatus))
+	})
+
+	t.Run("request-no-cache", func(t *testing.T) {
+		s := NewSequenceServer(cacheTestHandler(200, "Cache-Control", "max-age=60", "ETag", `"v1"`))
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+
+		res := sendCacheTest(t, c, "Cache-Control", "no-cache")
+		assert.Equal(t, httplib.CacheStatusRevalidated, res.Header().Get(headers.CacheStatus))
+
+		res = sendCacheTest(t, c, "Pragma", "no-cache")
+		assert.Equal(t, httplib.CacheStatusRevalidated, res.Header().Get(headers.CacheStatus))
+		assert.Equal(t, 3, s.Count())
+	})
+
+	t.Run("request-max-age", func(t *testing.T) {
+		s := NewSequenceServer(cacheTestHandler(200, "Cache-Control", "max-age=600", "Age", "100"))
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+		assert.Equal(t, "body-1", sendCacheTest(t, c, "Cache-Control", "max-age=200").String())
+		assert.Equal(t, "body-2", sendCacheTest(t, c, "Cache-Control", "max-age=50").String())
+	})
+
+	t.Run("request-max-stale", func(t *testing.T) {
+		s := NewSequenceServer(cacheTestHandler(200, "Cache-Control", "max-age=60", "Age", "100"))
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+		assert.Equal(t, "body-1", sendCacheTest(t, c, "Cache-Control", "max-stale=60").String())
+		assert.Equal(t, "body-1", sendCacheTest(t, c, "Cache-Control", "max-stale").String())
+		assert.Equal(t, "body-2", sendCacheTest(t, c, "Cache-Control", "max-stale=10").String())
+	})
+
+	t.Run("only-if-cached", func(t *testing.T) {
+		s := NewSequenceServer(cacheTestHandler(200, "Cache-Control", "max-age=60"))
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		res := sendCacheTest(t, c, "Cache-Control", "only-if-cached")
+		assert.Equal(t, http.StatusGatewayTimeout, res.StatusCode())
+		assert.Equal(t, 0, s.Count())
+
+		sendCacheTest(t, c)
+		res = sendCacheTest(t, c, "Cache-Control", "only-if-cached")
+		assert.Equal(t, "body-1", res.String())
+	})
+
+	t.Run("invalidate", func(t *testing.T) {
+		s := NewSequenceServer(cacheTestHandler(200, "Cache-Control", "max-age=60"))
+		defer s.Close()
+		c := newCacheTestClient(s)
+
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+
+		_, err := c.NewBuilder("POST", "/path").Send()
+		require.NoError(t, err)
+
+		assert.Equal(t, "body-3", sendCacheTest(t, c).String())
+	})
+
+	t.Run("disk", func(t *testing.T) {
+		s := NewSequenceServer(cacheTestHandler(200, "Cache-Control", "max-age=60"))
+		defer s.Close()
+
+		dir := filepath.Join(t.TempDir(), "cache")
+		storage, err := httplib.NewDiskCacheStorage(dir)
+		require.NoError(t, err)
+
+		c := httplib.NewClient(s.URL).WithCache(httplib.NewCache(storage))
+		assert.Equal(t, "body-1", sendCacheTest(t, c).String())
+
+		// A new Cache with the same directory shares the entries.
+		storage2, _ := httplib.NewDiskCacheStorage(dir)
+		c2 := httplib.NewClient(s.URL).WithCache(httplib.NewCache(storage2))
+		res := sendCacheTest(t, c2)
+		assert.Equal(t, "body-1", res.String())
+		assert.Equal(t, httplib.CacheStatusHit, res.Header().Get(headers.CacheStatus))
+		assert.Equal(t, 1, s.Count())
+	})
+}
+
+func TestMemoryCacheStorage(t *testing.T) {
+	s := httplib.NewMemoryCacheStorage(2)
+
+	_, ok := s.Get("a")
+	assert.False(t, ok)
+
+	s.Set("a", []byte("1"))
+	s.Set("b", []byte("2"))
+	s.Get("a") // a is the most recently used.
+	s.Set("c", []byte("3"))
+	assert.Equal(t, 2, s.Len())
+
+	_, ok = s.Get("b")
+	assert.False(t, ok, "b should be evicted")
+
+	data, ok := s.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", string(data))
+
+	s.Set("a", []byte("11"))
+	data, _ = s.Get("a")
+	assert.Equal(t, "11", string(data))
+
+	s.Delete("a")
+	s.Delete("not-exist")
+	_, ok = s.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, s.Len())
+
+	unlimited := httplib.NewMemoryCacheStorage(0)
+	for i := 0; i < 100; i++ {
+		unlimited.Set(fmt.Sprint(i), nil)
+	}
+	assert.Equal(t, 100, unlimited.Len())
+}
+
+func TestDiskCacheStorage(t *testing.T) {
+	dir := t.TempDir()
+	s, err := httplib.NewDiskCacheStorage(dir)
+	require.NoError(t, err)
+	assert.Equal(t, dir, s.Dir())
+
+	_, ok := s.Get("a")
+	assert.False(t, ok)
+
+	s.Set("a", []byte("1"))
+	s.Set("a", []byte("11"))
+	data, ok := s.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "11", string(data))
+
+	files, _ := os.ReadDir(dir)
+	assert.Len(t, files, 1)
+
+	s.Delete("a")
+	s.Delete("not-exist")
+	_, ok = s.Get("a")
+	assert.False(t, ok)
+
+	files, _ = os.ReadDir(dir)
+	assert.Len(t, files, 0)
+}
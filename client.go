@@ -0,0 +1,182 @@
+package httplib
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// BuilderOption is a function used to setup a RequestBuilder.
+// It is used to give default options to the RequestBuilders created by a Client.
+type BuilderOption func(b *RequestBuilder)
+
+// Client is used to create RequestBuilders which share the same http.Client, base URL and default settings.
+//
+// A Client should be setup before it is used. Once it is used, it is safe for concurrent use by
+// multiple goroutines, but the With* methods must not be called concurrently.
+type Client struct {
+	httpClient *http.Client
+	baseUrl    string
+	basePath   string // The base URL without the query string and the fragment.
+
+	query       url.Values
+	header      http.Header
+	auth        bool // True if the default Authorization header is set by WithAuthScheme() and the like.
+	options     []BuilderOption
+	middlewares []Middleware
+}
+
+// DefaultClient is the Client used by NewBuilder() and the shortcut functions.
+var DefaultClient = NewClient("")
+
+// NewClient creates a new instance of Client.
+//
+// If baseUrl is not empty, the relative paths given to Client.NewBuilder() will be resolved against it.
+// The query string of baseUrl is added to the default query strings, see WithQuery(); the fragment is ignored.
+func NewClient(baseUrl string) *Client {
+	basePath, _, _ := strings.Cut(baseUrl, "#")
+	basePath, rawQuery, _ := strings.Cut(basePath, "?")
+
+	// Keep the values which can be parsed, like url.URL.Query() does.
+	query, _ := url.ParseQuery(rawQuery)
+
+	return &Client{
+		httpClient: new(http.Client),
+		baseUrl:    baseUrl,
+		basePath:   basePath,
+
+		query:  query,
+		header: make(http.Header),
+	}
+}
+
+// BaseURL returns the base URL of the Client.
+func (c *Client) BaseURL() string {
+	return c.baseUrl
+}
+
+// HTTPClient returns the http.Client used to send requests.
+func (c *Client) HTTPClient() *http.Client {
+	return c.httpClient
+}
+
+// WithHTTPClient sets the http.Client used to send requests, it can be used to setup timeouts,
+// transports, proxies and so on. If the given value is nil, a new http.Client is used.
+func (c *Client) WithHTTPClient(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = new(http.Client)
+	}
+	c.httpClient = httpClient
+	return c
+}
+
+// WithQuery appends a default query string. It is applied to every request whose builder
+// does not have a query string with the same name.
+//
+// If the given value is not a string, it will be converted to a string.
+func (c *Client) WithQuery(name string, value any) *Client {
+	c.query.Add(name, toString(value))
+	return c
+}
+
+// WithQueries appends a group of default query strings.
+//
+// If a value in the map is not a string, it will be converted to a string.
+func (c *Client) WithQueries(values map[string]any) *Client {
+	for k, v := range values {
+		c.query.Add(k, toString(v))
+	}
+	return c
+}
+
+// WithHeader appends a default HTTP header. It is applied to every request whose builder
+// does not have a header with the same name. The name will be converted to the Header-Naming-Style.
+//
+// If the given value is not a string, it will be converted to a string.
+func (c *Client) WithHeader(name string, value any) *Client {
+	c.header.Add(name, toString(value))
+	return c
+}
+
+// WithHeaders appends a group of default HTTP headers.
+//
+// If a value in the map is not a string, it will be converted to a string.
+func (c *Client) WithHeaders(values map[string]any) *Client {
+	for k, v := range values {
+		c.header.Add(k, toString(v))
+	}
+	return c
+}
+
+// WithOption appends options which are applied to every RequestBuilder created by Client.NewBuilder(),
+// in the order they are given.
+func (c *Client) WithOption(options ...BuilderOption) *Client {
+	c.options = append(c.options, options...)
+	return c
+}
+
+// NewBuilder creates a new instance of RequestBuilder which sends the request through the Client.
+//
+// If uri is an absolute URL, it is used directly; otherwise it is joined to the base URL of the Client,
+// e.g. the base URL 'http://example.org/api/' and the path '/users' gives 'http://example.org/api/users'.
+func (c *Client) NewBuilder(method string, uri string) *RequestBuilder {
+	b := &RequestBuilder{
+		Method:  method,
+		baseUrl: c.resolveURL(uri),
+		client:  c,
+
+		query:  make(url.Values),
+		header: make(http.Header),
+	}
+
+	for _, option := range c.options {
+		option(b)
+	}
+
+	return b
+}
+
+func (c *Client) resolveURL(uri string) string {
+	if c.basePath == "" {
+		return uri
+	}
+
+	if u, err := url.Parse(uri); err == nil && u.IsAbs() {
+		return uri
+	}
+
+	if uri == "" {
+		return c.basePath
+	}
+
+	return strings.TrimRight(c.basePath, "/") + "/" + strings.TrimLeft(uri, "/")
+}
+
+// mergeQuery adds the default query strings which are not contained in the given values.
+func (c *Client) mergeQuery(query url.Values) url.Values {
+	if len(c.query) == 0 {
+		return query
+	}
+
+	res := make(url.Values, len(query)+len(c.query))
+	for k, v := range query {
+		res[k] = v
+	}
+	for k, v := range c.query {
+		if _, ok := res[k]; !ok {
+			res[k] = append([]string(nil), v...)
+		}
+	}
+	return res
+}
+
+// mergeHeader returns a copy of the given header, with the default headers which are not contained in it.
+func (c *Client) mergeHeader(header http.Header) http.Header {
+	res := header.Clone()
+	for k, v := range c.header {
+		if _, ok := res[k]; !ok {
+			res[k] = append([]string(nil), v...)
+		}
+	}
+	return res
+}
@@ -0,0 +1,112 @@
+package httplib_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_NewBuilder(t *testing.T) {
+	t.Run("resolve-url", func(t *testing.T) {
+		cases := []struct {
+			base, uri, want string
+		}{
+			{"", "http://temp.org/p", "http://temp.org/p"},
+			{"http://temp.org", "", "http://temp.org"},
+			{"http://temp.org", "/p", "http://temp.org/p"},
+			{"http://temp.org/", "p", "http://temp.org/p"},
+			{"http://temp.org/api/", "/users/1", "http://temp.org/api/users/1"},
+			{"http://temp.org/api", "users?a=1", "http://temp.org/api/users?a=1"},
+			{"http://temp.org/api", "https://other.org/x", "https://other.org/x"},
+
+			// The query string of the base URL is a default query string, the fragment is ignored.
+			{"https://temp.org/api?key=1", "/users", "https://temp.org/api/users?key=1"},
+			{"https://temp.org/api?key=1#f", "users?a=1", "https://temp.org/api/users?a=1&key=1"},
+			{"https://temp.org/api/#f", "", "https://temp.org/api/"},
+			{"https://temp.org/api?key=1", "https://other.org/x", "https://other.org/x?key=1"},
+		}
+
+		for _, c := range cases {
+			b := httplib.NewClient(c.base).NewBuilder("GET", c.uri)
+			assert.Equal(t, c.want, b.URL(), "base=%s uri=%s", c.base, c.uri)
+		}
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		s := NewTestServer(http.StatusOK, DefaultBody)
+		defer s.Close()
+
+		c := httplib.NewClient(s.URL+"/api").
+			WithQuery("q1", 1).
+			WithQueries(map[string]any{"q2": "2"}).
+			WithHeader("x-h1", "v1").
+			WithHeaders(map[string]any{"x-h2": "v2"}).
+			WithOption(func(b *httplib.RequestBuilder) {
+				b.WithHeader("x-opt", "o")
+			})
+
+		// Values on the builder take precedence over the defaults.
+		b := c.NewBuilder("GET", "/path").WithQuery("q2", "b").WithHeader("x-h2", "b")
+		content, err := b.ReadString()
+
+		assert := assert.New(t)
+		assert.NoError(err)
+		assert.Equal(string(DefaultBody), content)
+		assert.Equal("/api/path?q1=1&q2=b", s.Request.RequestURI)
+		assert.Equal("v1", s.Request.Header.Get("X-H1"))
+		assert.Equal([]string{"b"}, s.Request.Header.Values("X-H2"))
+		assert.Equal("o", s.Request.Header.Get("X-Opt"))
+
+		// The defaults do not change the builder itself.
+		assert.Equal(s.URL+"/api/path?q1=1&q2=b", b.URL())
+		assert.Same(c, b.Client())
+
+		// The query string of the base URL can be overridden like other defaults.
+		b = httplib.NewClient(s.URL+"/api?key=1&q=1").NewBuilder("GET", "/path").WithQuery("q", 2)
+		assert.Equal(s.URL+"/api/path?key=1&q=2", b.URL())
+		assert.Equal(s.URL+"/api?key=1&q=1", b.Client().BaseURL())
+	})
+}
+
+func TestClient_WithHTTPClient(t *testing.T) {
+	s := NewTestServer(http.StatusOK, DefaultBody)
+	defer s.Close()
+
+	called := 0
+	hc := &http.Client{
+		Timeout: time.Second,
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			called++
+			return http.DefaultTransport.RoundTrip(r)
+		}),
+	}
+
+	c := httplib.NewClient(s.URL).WithHTTPClient(hc)
+	assert.Same(t, hc, c.HTTPClient())
+
+	for i := 0; i < 2; i++ {
+		content, err := c.NewBuilder("GET", "").ReadString()
+		assert.NoError(t, err)
+		assert.Equal(t, string(DefaultBody), content)
+	}
+	assert.Equal(t, 2, called)
+
+	c.WithHTTPClient(nil)
+	assert.NotNil(t, c.HTTPClient())
+	assert.NotSame(t, hc, c.HTTPClient())
+}
+
+func TestNewBuilder_DefaultClient(t *testing.T) {
+	b := httplib.NewBuilder("GET", "http://temp.org")
+	assert.Same(t, httplib.DefaultClient, b.Client())
+	assert.Equal(t, "", httplib.DefaultClient.BaseURL())
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,360 @@
+package httplib
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cmstar/go-httplib/headers"
+)
+
+// RedactedValue replaces the values of the sensitive data in the outputs, such as the curl commands.
+const RedactedValue = "***"
+
+// SensitiveHeaders are the headers redacted by default, such as by CurlRedact() without arguments.
+var SensitiveHeaders = []string{
+	headers.Authorization,
+	headers.ProxyAuthorization,
+	headers.Cookie,
+	headers.SetCookie,
+}
+
+// CurlOption is used to setup the output of Curl() and RequestBuilder.Curl().
+type CurlOption func(o *curlOptions)
+
+type curlOptions struct {
+	redact map[string]bool // The keys are canonical header names.
+}
+
+// CurlRedact replaces the values of the given headers with RedactedValue, the names are case-insensitive.
+// If no name is given, SensitiveHeaders are used. The password in the URL is also redacted.
+func CurlRedact(names ...string) CurlOption {
+	if len(names) == 0 {
+		names = SensitiveHeaders
+	}
+
+	return func(o *curlOptions) {
+		if o.redact == nil {
+			o.redact = make(map[string]bool)
+		}
+		for _, name := range names {
+			o.redact[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// Curl returns a curl command which sends the request, see Curl() for the format.
+// The body is not consumed, the builder can still be used to send the request.
+//
+// The multipart bodies are converted to -F/--form-string arguments, the file parts refer to the file names
+// instead of including the contents.
+//
+// The Authorization header set by WithAuthScheme(), WithBasicAuth() or WithBearerToken() is always redacted.
+//
+// Returns an error if the URL can not be built, e.g. there is an unresolved variable in the URI template.
+func (x *RequestBuilder) Curl(options ...CurlOption) (string, error) {
+	if x.err != nil {
+		return "", x.err
+	}
+
+	uri, err := x.buildURL()
+	if err != nil {
+		return "", err
+	}
+
+	if x.hasCredentials() {
+		// The credentials set by WithAuthScheme() and the like are always redacted.
+		options = append(options[:len(options):len(options)], CurlRedact(headers.Authorization))
+	}
+
+	c := newCurlCommand(x.Method, uri, options)
+	header := x.Client().mergeHeader(x.header)
+
+	switch v := x.body.(type) {
+	case nil:
+		c.writeHeaders(header)
+
+	case string:
+		c.writeHeaders(header)
+		c.writeBody([]byte(v))
+
+	case []byte:
+		c.writeHeaders(header)
+		c.writeBody(v)
+
+	case url.Values:
+		c.writeHeaders(header)
+		c.writeBody([]byte(v.Encode()))
+
+	case *multipartBody:
+		// curl generates the boundary itself.
+		header = header.Clone()
+		header.Del(headers.ContentType)
+		c.writeHeaders(header)
+		c.writeMultipart(v)
+
+	case io.Reader:
+		c.writeHeaders(header)
+		c.hasBody = true
+		c.note("The body is read from an io.Reader, it is not included; pipe it to the stdin.")
+		c.arg("--data-binary", "@-")
+	}
+
+	return c.String(), nil
+}
+
+// Curl returns a curl command which sends the given request. The command is in a single line, such as:
+//
+//	curl -X POST 'http://example.org/path?a=1' -H 'Content-Type: application/json' --data-raw '{"a":1}'
+//
+// If the body is not text, it is not included in the command, the argument '--data-binary @-' is used instead,
+// there is a line of comment before the command to describe it.
+//
+// The body is read from GetBody() if it is available; otherwise the body is read and replaced with
+// an in-memory copy, so that the request can still be sent.
+//
+// The Authorization header set by RequestBuilder.WithAuthScheme() and the like is always redacted.
+func Curl(req *http.Request, options ...CurlOption) (string, error) {
+	if HasCredentials(req) {
+		options = append(options[:len(options):len(options)], CurlRedact(headers.Authorization))
+	}
+
+	c := newCurlCommand(req.Method, req.URL.String(), options)
+
+	header := req.Header
+	if req.Host != "" && req.Host != req.URL.Host {
+		header = header.Clone()
+		header.Set("Host", req.Host)
+	}
+	c.writeHeaders(header)
+
+	body, err := peekRequestBody(req)
+	if err != nil {
+		return "", err
+	}
+
+	if body != nil {
+		c.writeBody(body)
+	}
+
+	return c.String(), nil
+}
+
+// peekRequestBody reads the body of the request without consuming it. Returns nil if there is no body.
+func peekRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if req.GetBody != nil {
+		r, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return data, nil
+}
+
+// redactURLPassword replaces the password in the URL with 'xxxxx' like url.URL.Redacted().
+// Returns the URL as is if there is no password or the URL can not be parsed.
+func redactURLPassword(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.User == nil {
+		return uri
+	}
+
+	if _, ok := u.User.Password(); !ok {
+		return uri
+	}
+	return u.Redacted()
+}
+
+type curlCommand struct {
+	options curlOptions
+	method  string
+	url     string
+	notes   []string
+	args    []string
+	hasBody bool
+}
+
+func newCurlCommand(method, uri string, options []CurlOption) *curlCommand {
+	c := &curlCommand{
+		method: method,
+		url:    uri,
+	}
+
+	for _, opt := range options {
+		opt(&c.options)
+	}
+
+	if c.options.redact != nil {
+		c.url = redactURLPassword(uri)
+	}
+	return c
+}
+
+func (c *curlCommand) note(s string) {
+	c.notes = append(c.notes, s)
+}
+
+func (c *curlCommand) arg(name string, value string) {
+	c.args = append(c.args, name, shellQuote(value))
+}
+
+func (c *curlCommand) writeHeaders(header http.Header) {
+	names := make([]string, 0, len(header))
+	for k := range header {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		// curl computes the length itself.
+		if http.CanonicalHeaderKey(name) == "Content-Length" {
+			continue
+		}
+
+		for _, v := range header[name] {
+			if c.options.redact[http.CanonicalHeaderKey(name)] {
+				v = RedactedValue
+			}
+			c.arg("-H", name+": "+v)
+		}
+	}
+}
+
+func (c *curlCommand) writeBody(body []byte) {
+	c.hasBody = true
+
+	if isPrintableText(body) {
+		c.arg("--data-raw", string(body))
+		return
+	}
+
+	c.note(fmt.Sprintf("The body is binary (%d bytes), it is not included; pipe it to the stdin.", len(body)))
+	c.arg("--data-binary", "@-")
+}
+
+func (c *curlCommand) writeMultipart(body *multipartBody) {
+	c.hasBody = true
+	hasFile := false
+	hasStdin := false
+
+	for _, part := range body.parts {
+		_, params, _ := mime.ParseMediaType(part.header.Get("Content-Disposition"))
+		name := params["name"]
+
+		s, isString := part.content.(string)
+		if isString && params["filename"] == "" {
+			c.arg("--form-string", name+"="+s)
+			continue
+		}
+
+		var v string
+		switch {
+		case params["filename"] != "":
+			hasFile = true
+			v = name + "=@" + params["filename"]
+
+		// A part without a file name is read from an io.Reader, only one part can be read from the stdin.
+		case !hasStdin:
+			hasStdin = true
+			c.note(fmt.Sprintf("The part %q is read from an io.Reader, it is not included; pipe it to the stdin.", name))
+			v = name + "=<-"
+
+		default:
+			c.note(fmt.Sprintf("The part %q is read from an io.Reader, it is not included.", name))
+			c.arg("--form-string", name+"=")
+			continue
+		}
+
+		if ct := part.header.Get(headers.ContentType); ct != "" {
+			v += ";type=" + ct
+		}
+		c.arg("-F", v)
+	}
+
+	if hasFile {
+		c.note("The contents of the files are not included, the file names are used as the paths.")
+	}
+}
+
+func (c *curlCommand) String() string {
+	var b strings.Builder
+
+	for _, n := range c.notes {
+		b.WriteString("# " + n + "\n")
+	}
+
+	b.WriteString("curl")
+	switch {
+	case c.method == http.MethodHead:
+		b.WriteString(" --head")
+	case c.method == http.MethodGet && !c.hasBody:
+	case c.method == "":
+	default:
+		b.WriteString(" -X " + shellQuote(c.method))
+	}
+
+	b.WriteString(" " + shellQuote(c.url))
+	for _, arg := range c.args {
+		b.WriteString(" " + arg)
+	}
+
+	return b.String()
+}
+
+// isPrintableText returns true if data is valid UTF-8 and contains no control characters except tabs and line breaks.
+func isPrintableText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+
+	for _, c := range data {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// shellQuote quotes s for POSIX shells. s is returned as is if it only contains safe characters.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+
+	safe := true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("@%+=:,./-_", c) >= 0) {
+			safe = false
+			break
+		}
+	}
+
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,532 @@
+package httplib
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cmstar/go-httplib/headers"
+)
+
+// FromCurl parses a curl command line and returns a RequestBuilder which sends the same request through DefaultClient.
+// It is the reverse of RequestBuilder.Curl(). The command is split by the quoting rules of POSIX shells,
+// including the line continuations and $'...' strings; the lines starting with '#' are ignored.
+//
+// The supported options are:
+//   - -X/--request, -I/--head, -G/--get, --url and the URL.
+//   - -H/--header, -A/--user-agent, -e/--referer, -b/--cookie (not the cookie files), -u/--user .
+//   - -d/--data/--data-ascii/--data-binary/--data-raw/--data-urlencode, they are joined by '&'.
+//     The Content-Type is 'application/x-www-form-urlencoded' if not given by -H.
+//   - -F/--form/--form-string, the files referred by '@' and '<' are opened.
+//   - -m/--max-time and --connect-timeout, see WithTimeout() and WithConnectTimeout().
+//   - The options which do not change the request are ignored, such as -s, -v, -L, -k and --compressed,
+//     the http.Client negotiates the compression itself.
+//
+// Returns an error if the command is not a curl command, or it contains an unsupported option.
+func FromCurl(cmd string) (*RequestBuilder, error) {
+	args, err := splitShellWords(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("httplib: curl: %w", err)
+	}
+
+	if len(args) == 0 || path.Base(filepath.ToSlash(args[0])) != "curl" {
+		return nil, errors.New("httplib: curl: not a curl command")
+	}
+
+	p := &curlParser{}
+	if err := p.parse(args[1:]); err != nil {
+		return nil, fmt.Errorf("httplib: curl: %w", err)
+	}
+
+	return p.builder()
+}
+
+type curlParser struct {
+	method     string
+	url        string
+	head       bool
+	get        bool
+	header     []string // Each is 'Name: value'.
+	data       []string
+	form       []curlFormField
+	user       string
+	cookies    []string
+	maxTime    time.Duration
+	connectMax time.Duration
+}
+
+type curlFormField struct {
+	value    string
+	isString bool // By --form-string, the value is not interpreted.
+}
+
+// The options which take a value.
+var curlValueOptions = map[string]string{
+	"-X": "--request", "-H": "--header", "-d": "--data", "-F": "--form", "-u": "--user", "-b": "--cookie",
+	"-A": "--user-agent", "-e": "--referer", "-m": "--max-time", "-o": "--output",
+	"--request": "", "--header": "", "--data": "", "--data-ascii": "", "--data-binary": "", "--data-raw": "",
+	"--data-urlencode": "", "--form": "", "--form-string": "", "--user": "", "--cookie": "", "--user-agent": "",
+	"--referer": "", "--max-time": "", "--connect-timeout": "", "--url": "", "--output": "",
+}
+
+// The options without a value which do not change the request.
+var curlIgnoredFlags = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true, "-v": true, "--verbose": true,
+	"-L": true, "--location": true, "-k": true, "--insecure": true, "-i": true, "--include": true,
+	"-f": true, "--fail": true, "-#": true, "--progress-bar": true, "--compressed": true,
+	"--http1.1": true, "--http2": true,
+}
+
+func (p *curlParser) parse(args []string) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if p.url != "" {
+				return fmt.Errorf("multiple URLs are not supported: %s", arg)
+			}
+			p.url = arg
+			continue
+		}
+
+		names := []string{arg}
+		value := ""
+		hasValue := false
+
+		// Short options can be combined, e.g. -sSL, and the value can be attached, e.g. -XPOST .
+		if !strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			names = names[:0]
+			for j := 1; j < len(arg); j++ {
+				name := "-" + arg[j:j+1]
+				names = append(names, name)
+				if _, ok := curlValueOptions[name]; ok {
+					if j+1 < len(arg) {
+						value, hasValue = arg[j+1:], true
+					}
+					break
+				}
+			}
+		}
+
+		for _, name := range names {
+			if curlIgnoredFlags[name] {
+				continue
+			}
+
+			switch name {
+			case "-I", "--head":
+				p.head = true
+				continue
+			case "-G", "--get":
+				p.get = true
+				continue
+			}
+
+			long, ok := curlValueOptions[name]
+			if !ok {
+				return fmt.Errorf("unsupported option %s", name)
+			}
+			if long != "" {
+				name = long
+			}
+
+			if !hasValue {
+				i++
+				if i >= len(args) {
+					return fmt.Errorf("option %s requires a value", name)
+				}
+				value = args[i]
+			}
+
+			if err := p.setOption(name, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	if p.url == "" {
+		return errors.New("no URL")
+	}
+	return nil
+}
+
+func (p *curlParser) setOption(name, value string) error {
+	switch name {
+	case "--request":
+		p.method = value
+	case "--url":
+		p.url = value
+	case "--header":
+		p.header = append(p.header, value)
+	case "--user-agent":
+		p.header = append(p.header, "User-Agent: "+value)
+	case "--referer":
+		p.header = append(p.header, "Referer: "+value)
+	case "--cookie":
+		if !strings.Contains(value, "=") {
+			return fmt.Errorf("cookie files are not supported: %s", value)
+		}
+		p.cookies = append(p.cookies, value)
+	case "--user":
+		p.user = value
+	case "--data", "--data-ascii", "--data-binary":
+		if strings.HasPrefix(value, "@") {
+			return fmt.Errorf("reading data from files is not supported: %s", value)
+		}
+		if name != "--data-binary" {
+			// Like curl, the carriage returns and newlines are stripped, only --data-binary and --data-raw keep them.
+			value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+		}
+		p.data = append(p.data, value)
+	case "--data-raw":
+		p.data = append(p.data, value)
+	case "--data-urlencode":
+		v, err := curlURLEncode(value)
+		if err != nil {
+			return err
+		}
+		p.data = append(p.data, v)
+	case "--form":
+		p.form = append(p.form, curlFormField{value, false})
+	case "--form-string":
+		p.form = append(p.form, curlFormField{value, true})
+	case "--max-time", "--connect-timeout":
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds < 0 {
+			return fmt.Errorf("invalid %s value %q", name, value)
+		}
+		d := time.Duration(seconds * float64(time.Second))
+		if name == "--max-time" {
+			p.maxTime = d
+		} else {
+			p.connectMax = d
+		}
+	case "--output":
+	}
+	return nil
+}
+
+// curlURLEncode handles the value of --data-urlencode: 'content', '=content' or 'name=content'.
+func curlURLEncode(value string) (string, error) {
+	if i := strings.IndexAny(value, "=@"); i >= 0 && value[i] == '@' {
+		return "", fmt.Errorf("reading data from files is not supported: %s", value)
+	}
+
+	name, content, ok := strings.Cut(value, "=")
+	if !ok {
+		return url.QueryEscape(value), nil
+	}
+	if name == "" {
+		return url.QueryEscape(content), nil
+	}
+	return name + "=" + url.QueryEscape(content), nil
+}
+
+func (p *curlParser) builder() (*RequestBuilder, error) {
+	uri := p.url
+	if !strings.Contains(uri, "://") {
+		uri = "http://" + uri
+	}
+
+	data := strings.Join(p.data, "&")
+	if p.get && len(p.data) > 0 {
+		if strings.Contains(uri, "?") {
+			uri += "&" + data
+		} else {
+			uri += "?" + data
+		}
+	}
+
+	method := p.method
+	if method == "" {
+		switch {
+		case p.head:
+			method = http.MethodHead
+		case (len(p.data) > 0 && !p.get) || len(p.form) > 0:
+			method = http.MethodPost
+		default:
+			method = http.MethodGet
+		}
+	}
+
+	b := NewBuilder(method, uri)
+
+	// The values of all -b options are sent in one Cookie header, like curl does.
+	header := p.header
+	if len(p.cookies) > 0 {
+		header = append(header, "Cookie: "+strings.Join(p.cookies, "; "))
+	}
+
+	hasContentType := false
+	for _, h := range header {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok {
+			// 'Name;' sends the header with an empty value.
+			if strings.HasSuffix(h, ";") {
+				b.WithHeader(strings.TrimSpace(h[:len(h)-1]), "")
+			}
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue // 'Name:' removes the header.
+		}
+
+		name = strings.TrimSpace(name)
+		if strings.EqualFold(name, headers.ContentType) {
+			hasContentType = true
+		}
+		b.WithHeader(name, value)
+	}
+
+	if p.user != "" {
+		user, pass, _ := strings.Cut(p.user, ":")
+		b.WithBasicAuth(user, pass)
+	}
+
+	switch {
+	case len(p.form) > 0:
+		if err := p.setForm(b); err != nil {
+			return nil, err
+		}
+	case len(p.data) > 0 && !p.get:
+		b.SetStringBody(data)
+		if !hasContentType {
+			b.setContentType(headers.MediaTypeFormURLEncoded)
+		}
+	}
+
+	if p.maxTime > 0 {
+		b.WithTimeout(p.maxTime)
+	}
+	if p.connectMax > 0 {
+		b.WithConnectTimeout(p.connectMax)
+	}
+
+	return b, nil
+}
+
+// setForm adds the -F fields: 'name=value', 'name=@file;type=...;filename=...' or 'name=<file'.
+func (p *curlParser) setForm(b *RequestBuilder) (err error) {
+	// The files are closed after they are sent; if a field fails, close the files opened so far.
+	var files []*os.File
+	defer func() {
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+		}
+	}()
+
+	for _, f := range p.form {
+		name, value, ok := strings.Cut(f.value, "=")
+		if !ok {
+			return fmt.Errorf("invalid form field %q", f.value)
+		}
+
+		if f.isString || (!strings.HasPrefix(value, "@") && !strings.HasPrefix(value, "<")) {
+			b.WithMultipartField(name, value)
+			continue
+		}
+
+		// Split the modifiers such as ';type=text/plain'.
+		parts := strings.Split(value[1:], ";")
+		file := parts[0]
+		filename := filepath.Base(file)
+		contentType := ""
+		for _, m := range parts[1:] {
+			k, v, _ := strings.Cut(m, "=")
+			switch strings.TrimSpace(k) {
+			case "type":
+				contentType = v
+			case "filename":
+				filename = strings.Trim(v, `"`)
+			}
+		}
+
+		if value[0] == '<' {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			b.WithMultipartField(name, string(data))
+			continue
+		}
+
+		r, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		files = append(files, r)
+
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(name), escapeQuotes(filename)))
+		if contentType == "" {
+			contentType = headers.MediaTypeOctetStream.String()
+		}
+		h.Set(headers.ContentType, contentType)
+		b.WithMultipartPart(h, r)
+	}
+
+	return nil
+}
+
+// splitShellWords splits a command line like a POSIX shell, the variables and the substitutions are not expanded.
+func splitShellWords(s string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		comment bool
+	)
+
+	flush := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if comment {
+			if c == '\n' {
+				comment = false
+			}
+			continue
+		}
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+
+		case c == '#' && !inWord:
+			comment = true
+
+		case c == '\\':
+			if i+1 >= len(s) {
+				return nil, errors.New("unexpected end after '\\'")
+			}
+			i++
+			if s[i] == '\n' {
+				continue // Line continuation.
+			}
+			if s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+				i++
+				continue
+			}
+			word.WriteByte(s[i])
+			inWord = true
+
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			n, err := readANSICString(s[i+2:], &word)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 1 // At the closing quote.
+			inWord = true
+
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inWord = true
+
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+
+	flush()
+	return words, nil
+}
+
+// readANSICString reads the content of $'...' until the closing quote, and returns the number of bytes consumed,
+// including the closing quote.
+func readANSICString(s string, w *strings.Builder) (int, error) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\'' {
+			return i + 1, nil
+		}
+
+		if c != '\\' || i+1 >= len(s) {
+			w.WriteByte(c)
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n':
+			w.WriteByte('\n')
+		case 'r':
+			w.WriteByte('\r')
+		case 't':
+			w.WriteByte('\t')
+		case '0':
+			w.WriteByte(0)
+		case 'x':
+			n := 0
+			for n < 2 && i+1+n < len(s) && isHex(s[i+1+n]) {
+				n++
+			}
+			if n == 0 {
+				w.WriteString(`\x`)
+				continue
+			}
+			v, _ := strconv.ParseUint(s[i+1:i+1+n], 16, 8)
+			w.WriteByte(byte(v))
+			i += n
+		case 'u':
+			n := 0
+			for n < 4 && i+1+n < len(s) && isHex(s[i+1+n]) {
+				n++
+			}
+			if n == 0 {
+				w.WriteString(`\u`)
+				continue
+			}
+			v, _ := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			w.WriteRune(rune(v))
+			i += n
+		case '\\', '\'', '"', '?':
+			w.WriteByte(s[i])
+		default:
+			w.WriteByte('\\')
+			w.WriteByte(s[i])
+		}
+	}
+
+	return 0, errors.New("unterminated $' quote")
+}
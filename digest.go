@@ -0,0 +1,438 @@
+package httplib
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/cmstar/go-httplib/headers"
+)
+
+// DigestAuth answers the challenges of the HTTP Digest Access Authentication defined in [RFC 7616].
+// It is used as a Middleware, see Client.WithDigestAuth(). It can be used concurrently.
+//
+// When a response is 401 with a Digest challenge in the WWW-Authenticate header, the request is sent again
+// with the Authorization header computed from the challenge. The challenge is kept for the origin (scheme and host)
+// of the request, the following requests to the origin are authorized without waiting for a challenge,
+// each with an increased nonce count. A new challenge is answered once for each request, such as when the server
+// considers the nonce stale; the next nonce given by the Authentication-Info header is used for the next request.
+//
+// The algorithms MD5, SHA-256 and SHA-512-256 and their -sess variants are supported, with the qop 'auth' or
+// 'auth-int', or without qop as defined in [RFC 2069]. If there are several Digest challenges, the one with
+// the strongest algorithm is used. If the challenge has 'userhash=true', the username is hashed.
+//
+// To send the request again, its body is read from GetBody(), which is set for the bodies of the RequestBuilder
+// such as strings and forms. Other bodies are read into memory before the request is sent.
+//
+// [RFC 7616]: https://datatracker.ietf.org/doc/html/rfc7616
+// [RFC 2069]: https://datatracker.ietf.org/doc/html/rfc2069
+type DigestAuth struct {
+	username string
+	password string
+
+	mu       sync.Mutex
+	sessions map[string]*digestSession // The keys are the origins.
+}
+
+// NewDigestAuth creates a new instance of DigestAuth with the given credentials.
+func NewDigestAuth(username, password string) *DigestAuth {
+	return &DigestAuth{
+		username: username,
+		password: password,
+		sessions: make(map[string]*digestSession),
+	}
+}
+
+// WithDigestAuth appends the Middleware of a DigestAuth with the given credentials to the Client.
+// The nonce counts are shared between the requests sent through the Client.
+func (c *Client) WithDigestAuth(username, password string) *Client {
+	return c.WithMiddleware(NewDigestAuth(username, password).Middleware())
+}
+
+// WithDigestAuth appends the Middleware of a DigestAuth with the given credentials to the request.
+// The challenge is not shared with other requests; use Client.WithDigestAuth() to reuse the challenge.
+func (x *RequestBuilder) WithDigestAuth(username, password string) *RequestBuilder {
+	return x.WithMiddleware(NewDigestAuth(username, password).Middleware())
+}
+
+// Middleware returns the Middleware which answers the Digest challenges.
+func (d *DigestAuth) Middleware() Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return d.do(next, req)
+		})
+	}
+}
+
+// digestSession is the state of a challenge, the fields except nc are read-only.
+type digestSession struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string // As it appears in the challenge, empty if absent.
+	qop       string // The qop chosen from the challenge, empty if absent.
+	userhash  bool
+	newHash   func() hash.Hash
+	sess      bool
+	cnonce    string
+
+	nc uint32 // The nonce count of the last request.
+}
+
+func (d *DigestAuth) do(next Doer, req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// Make the body rewindable.
+		if _, err := peekRequestBody(req); err != nil {
+			return nil, err
+		}
+	}
+
+	origin := req.URL.Scheme + "://" + req.URL.Host
+	s := d.session(origin)
+
+	sent := req
+	if s != nil {
+		var err error
+		sent, err = d.authorize(req, s, d.nextCount(s), false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := next.Do(sent)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusUnauthorized {
+		if s != nil {
+			d.updateNonce(origin, s, res.Header.Get("Authentication-Info"))
+		}
+		return res, nil
+	}
+
+	c := selectDigestChallenge(res.Header.Values(headers.WWWAuthenticate))
+	if c == nil {
+		return res, nil
+	}
+
+	// The credentials are rejected.
+	if s != nil && c.Params["nonce"] == s.nonce && !strings.EqualFold(c.Params["stale"], "true") {
+		d.dropSession(origin, s)
+		return res, nil
+	}
+
+	s, err = newDigestSession(c)
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	d.setSession(origin, s)
+
+	// Drain the body so that the connection can be reused.
+	drainAndClose(res.Body)
+
+	sent, err = d.authorize(req, s, d.nextCount(s), true)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err = next.Do(sent)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusUnauthorized {
+		// The credentials are rejected, do not send them with the following requests.
+		d.dropSession(origin, s)
+	} else {
+		d.updateNonce(origin, s, res.Header.Get("Authentication-Info"))
+	}
+	return res, nil
+}
+
+func (d *DigestAuth) session(origin string) *digestSession {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.sessions[origin]
+}
+
+func (d *DigestAuth) setSession(origin string, s *digestSession) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sessions[origin] = s
+}
+
+// dropSession removes the session of the origin if it is still the given one.
+func (d *DigestAuth) dropSession(origin string, s *digestSession) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.sessions[origin] == s {
+		delete(d.sessions, origin)
+	}
+}
+
+func (d *DigestAuth) nextCount(s *digestSession) uint32 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s.nc++
+	return s.nc
+}
+
+// updateNonce replaces the session with a new nonce if the Authentication-Info header gives the next nonce.
+func (d *DigestAuth) updateNonce(origin string, s *digestSession, authInfo string) {
+	if authInfo == "" {
+		return
+	}
+
+	next := headers.ParseAuthParams(authInfo)["nextnonce"]
+	if next == "" || next == s.nonce {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.sessions[origin] == s {
+		ns := *s
+		ns.nonce = next
+		ns.nc = 0
+		d.sessions[origin] = &ns
+	}
+}
+
+// authorize returns a copy of the request with the Authorization header. If rewind is true,
+// the body is read from GetBody().
+func (d *DigestAuth) authorize(req *http.Request, s *digestSession, nc uint32, rewind bool) (*http.Request, error) {
+	// Mark the request so that the Authorization header is redacted by Dumper, Curl() and the like.
+	r := req.Clone(withCredentials(req.Context()))
+	if rewind && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+
+	var body []byte
+	if s.qop == "auth-int" && req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		body, err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	r.Header.Set(headers.Authorization, s.credentials(d.username, d.password, method, req.URL.RequestURI(), nc, body))
+	return r, nil
+}
+
+// credentials computes the value of the Authorization header.
+func (s *digestSession) credentials(username, password, method, uri string, nc uint32, body []byte) string {
+	h := func(parts ...string) string {
+		hh := s.newHash()
+		io.WriteString(hh, strings.Join(parts, ":"))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	ha1 := h(username, s.realm, password)
+	if s.sess {
+		ha1 = h(ha1, s.nonce, s.cnonce)
+	}
+
+	ha2 := h(method, uri)
+	if s.qop == "auth-int" {
+		ha2 = h(method, uri, h(string(body)))
+	}
+
+	ncValue := fmt.Sprintf("%08x", nc)
+	var response string
+	if s.qop == "" {
+		response = h(ha1, s.nonce, ha2)
+	} else {
+		response = h(ha1, s.nonce, ncValue, s.cnonce, s.qop, ha2)
+	}
+
+	var b strings.Builder
+	b.WriteString("Digest ")
+	switch {
+	case s.userhash:
+		b.WriteString("username=" + quoteString(h(username, s.realm)))
+	case isASCII(username):
+		b.WriteString("username=" + quoteString(username))
+	default:
+		b.WriteString("username*=UTF-8''" + encodeExtValue(username))
+	}
+
+	b.WriteString(", realm=" + quoteString(s.realm))
+	b.WriteString(", uri=" + quoteString(uri))
+	if s.algorithm != "" {
+		b.WriteString(", algorithm=" + s.algorithm)
+	}
+	b.WriteString(", nonce=" + quoteString(s.nonce))
+	if s.qop != "" {
+		b.WriteString(", nc=" + ncValue)
+	}
+	// The -sess algorithms use the cnonce in A1, the server needs it even without a qop.
+	if s.qop != "" || s.sess {
+		b.WriteString(", cnonce=" + quoteString(s.cnonce))
+	}
+	if s.qop != "" {
+		b.WriteString(", qop=" + s.qop)
+	}
+	b.WriteString(", response=" + quoteString(response))
+	if s.opaque != "" {
+		b.WriteString(", opaque=" + quoteString(s.opaque))
+	}
+	if s.userhash {
+		b.WriteString(", userhash=true")
+	}
+	return b.String()
+}
+
+// digestAlgorithms gives the hash functions of the supported algorithms, the keys are upper-cased.
+var digestAlgorithms = map[string]func() hash.Hash{
+	"MD5":         md5.New,
+	"SHA-256":     sha256.New,
+	"SHA-512-256": sha512.New512_256,
+}
+
+// digestStrength ranks the algorithms, the stronger the larger.
+var digestStrength = map[string]int{
+	"MD5":         1,
+	"SHA-256":     2,
+	"SHA-512-256": 3,
+}
+
+// parseDigestAlgorithm returns the base algorithm and whether it is a -sess variant.
+// An empty algorithm means MD5. Returns an empty string if the algorithm is not supported.
+func parseDigestAlgorithm(algorithm string) (string, bool) {
+	a := strings.ToUpper(algorithm)
+	if a == "" {
+		a = "MD5"
+	}
+
+	sess := strings.HasSuffix(a, "-SESS")
+	a = strings.TrimSuffix(a, "-SESS")
+	if _, ok := digestAlgorithms[a]; !ok {
+		return "", false
+	}
+	return a, sess
+}
+
+// parseDigestQop chooses the qop from the challenge, 'auth' is preferred. Returns false if the challenge
+// requires an unsupported qop.
+func parseDigestQop(qop string) (string, bool) {
+	if qop == "" {
+		return "", true
+	}
+
+	var authInt bool
+	for _, v := range strings.Split(qop, ",") {
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "auth":
+			return "auth", true
+		case "auth-int":
+			authInt = true
+		}
+	}
+
+	if authInt {
+		return "auth-int", true
+	}
+	return "", false
+}
+
+// selectDigestChallenge returns the supported Digest challenge with the strongest algorithm, or nil if there is none.
+func selectDigestChallenge(values []string) *headers.Challenge {
+	var best *headers.Challenge
+	bestStrength := 0
+
+	for _, value := range values {
+		for _, c := range headers.ParseChallenges(value) {
+			if !c.Is("Digest") || c.Params["nonce"] == "" {
+				continue
+			}
+
+			a, _ := parseDigestAlgorithm(c.Params["algorithm"])
+			if _, ok := parseDigestQop(c.Params["qop"]); !ok || a == "" {
+				continue
+			}
+
+			if digestStrength[a] > bestStrength {
+				c := c
+				best, bestStrength = &c, digestStrength[a]
+			}
+		}
+	}
+
+	return best
+}
+
+func newDigestSession(c *headers.Challenge) (*digestSession, error) {
+	a, sess := parseDigestAlgorithm(c.Params["algorithm"])
+	qop, _ := parseDigestQop(c.Params["qop"])
+
+	cnonce := make([]byte, 16)
+	if _, err := rand.Read(cnonce); err != nil {
+		return nil, err
+	}
+
+	return &digestSession{
+		realm:     c.Params["realm"],
+		nonce:     c.Params["nonce"],
+		opaque:    c.Params["opaque"],
+		algorithm: c.Params["algorithm"],
+		qop:       qop,
+		userhash:  strings.EqualFold(c.Params["userhash"], "true"),
+		newHash:   digestAlgorithms[a],
+		sess:      sess,
+		cnonce:    hex.EncodeToString(cnonce),
+	}, nil
+}
+
+// quoteString returns s as a quoted-string.
+func quoteString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeExtValue percent-encodes s as the value-chars defined in RFC 8187.
+func encodeExtValue(s string) string {
+	const attrChars = "!#$&+-.^_`|~"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte(attrChars, c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
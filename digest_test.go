@@ -0,0 +1,360 @@
+package httplib_test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/cmstar/go-httplib/headers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// digestServer is a server which requires the Digest authentication, it responds with the body of the request.
+type digestServer struct {
+	*httptest.Server
+
+	username  string
+	password  string
+	realm     string
+	algorithm string
+	qop       string
+	userhash  bool
+	nextNonce bool // Gives the next nonce in Authentication-Info.
+
+	mu        sync.Mutex
+	nonce     string
+	nonces    int
+	stale     bool     // The current nonce is stale.
+	auth      []string // The Authorization headers received.
+	lastCount map[string]string
+}
+
+func newDigestServer(algorithm, qop string) *digestServer {
+	s := &digestServer{
+		username:  "Mufasa",
+		password:  "Circle of Life",
+		realm:     "http-auth@example.org",
+		algorithm: algorithm,
+		qop:       qop,
+		lastCount: make(map[string]string),
+	}
+	s.newNonce()
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *digestServer) newNonce() {
+	s.nonces++
+	s.nonce = fmt.Sprintf("nonce-%d", s.nonces)
+	s.stale = false
+}
+
+// expireNonce makes the server reject the current nonce as stale.
+func (s *digestServer) expireNonce() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stale = true
+}
+
+func (s *digestServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.auth...)
+}
+
+func (s *digestServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	auth := r.Header.Get("Authorization")
+	s.auth = append(s.auth, auth)
+
+	stale, ok := s.verify(r, auth, body)
+	if !ok {
+		if stale {
+			s.newNonce()
+		}
+
+		challenge := fmt.Sprintf(`Digest realm="%s", nonce="%s", opaque="op"`, s.realm, s.nonce)
+		if s.algorithm != "" {
+			challenge += ", algorithm=" + s.algorithm
+		}
+		if s.qop != "" {
+			challenge += fmt.Sprintf(`, qop="%s"`, s.qop)
+		}
+		if stale {
+			challenge += ", stale=true"
+		}
+		if s.userhash {
+			challenge += ", userhash=true"
+		}
+		w.Header().Add("WWW-Authenticate", `Basic realm="basic"`)
+		w.Header().Add("WWW-Authenticate", challenge)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if s.nextNonce {
+		s.newNonce()
+		w.Header().Set("Authentication-Info", fmt.Sprintf(`nextnonce="%s"`, s.nonce))
+	}
+	w.Write(body)
+}
+
+// verify returns true if the Authorization header is valid, stale is true if the nonce is outdated or reused.
+func (s *digestServer) verify(r *http.Request, auth string, body []byte) (stale, ok bool) {
+	challenges := headers.ParseChallenges(auth)
+	if len(challenges) != 1 || !challenges[0].Is("Digest") {
+		return false, false
+	}
+	p := challenges[0].Params
+
+	if p["nonce"] != s.nonce || s.stale {
+		return true, false
+	}
+
+	// The nonce count must increase.
+	if p["nc"] != "" && p["nc"] <= s.lastCount[p["nonce"]] {
+		return true, false
+	}
+	s.lastCount[p["nonce"]] = p["nc"]
+
+	username := s.username
+	if s.userhash {
+		username = digestHash(s.algorithm, s.username+":"+s.realm)
+	}
+
+	if p["username"] != username && p["username*"] != "UTF-8''"+url.PathEscape(username) {
+		return false, false
+	}
+
+	if p["uri"] != r.URL.RequestURI() || p["realm"] != s.realm || p["opaque"] != "op" || p["algorithm"] != s.algorithm {
+		return false, false
+	}
+
+	want := digestResponse(s.algorithm, p["qop"], s.username, s.realm, s.password, r.Method, p["uri"], p["nonce"], p["nc"], p["cnonce"], body)
+	return false, p["response"] == want
+}
+
+func digestHash(algorithm, s string) string {
+	var h hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		h = md5.New()
+	case "SHA-256":
+		h = sha256.New()
+	case "SHA-512-256":
+		h = sha512.New512_256()
+	}
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// digestResponse computes the response defined in RFC 7616 section 3.4.1 .
+func digestResponse(algorithm, qop, username, realm, password, method, uri, nonce, nc, cnonce string, body []byte) string {
+	ha1 := digestHash(algorithm, username+":"+realm+":"+password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = digestHash(algorithm, ha1+":"+nonce+":"+cnonce)
+	}
+
+	a2 := method + ":" + uri
+	if qop == "auth-int" {
+		a2 += ":" + digestHash(algorithm, string(body))
+	}
+	ha2 := digestHash(algorithm, a2)
+
+	if qop == "" {
+		return digestHash(algorithm, ha1+":"+nonce+":"+ha2)
+	}
+	return digestHash(algorithm, strings.Join([]string{ha1, nonce, nc, cnonce, qop, ha2}, ":"))
+}
+
+func TestDigestResponse_RFC7616(t *testing.T) {
+	// The examples in RFC 7616 section 3.9.1 .
+	args := func(algorithm string) string {
+		return digestResponse(algorithm, "auth", "Mufasa", "http-auth@example.org", "Circle of Life", "GET", "/dir/index.html",
+			"7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", "00000001", "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", nil)
+	}
+	assert.Equal(t, "8ca523f5e9506fed4657c9700eebdbec", args("MD5"))
+	assert.Equal(t, "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1", args("SHA-256"))
+}
+
+func TestClient_WithDigestAuth(t *testing.T) {
+	cases := []struct {
+		algorithm string
+		qop       string
+	}{
+		{"", ""},
+		{"MD5", "auth"},
+		{"MD5-sess", ""},
+		{"MD5-sess", "auth"},
+		{"SHA-256", "auth,auth-int"},
+		{"SHA-256-sess", "auth-int"},
+		{"SHA-512-256", "auth-int"},
+		{"sha-512-256-sess", "auth"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.algorithm+" "+c.qop, func(t *testing.T) {
+			s := newDigestServer(c.algorithm, c.qop)
+			defer s.Close()
+
+			client := httplib.NewClient(s.URL).WithDigestAuth(s.username, s.password)
+			for i := 0; i < 3; i++ {
+				content, err := client.NewBuilder("POST", "/dir/index.html").WithQuery("i", i).SetStringBody("body").ReadString()
+				require.NoError(t, err)
+				assert.Equal(t, "body", content)
+			}
+
+			auth := s.received()
+			require.Len(t, auth, 4)
+			assert.Empty(t, auth[0])
+			for i, v := range auth[1:] {
+				p := headers.ParseChallenges(v)[0].Params
+				if c.qop == "" {
+					assert.NotContains(t, p, "nc")
+					assert.Equal(t, strings.HasSuffix(c.algorithm, "-sess"), p["cnonce"] != "")
+					continue
+				}
+				assert.Equal(t, fmt.Sprintf("%08x", i+1), p["nc"])
+				assert.Equal(t, strings.Split(c.qop, ",")[0], p["qop"])
+			}
+		})
+	}
+}
+
+func TestDigestAuth_Stale(t *testing.T) {
+	s := newDigestServer("SHA-256", "auth")
+	defer s.Close()
+
+	client := httplib.NewClient(s.URL).WithDigestAuth(s.username, s.password)
+	client.NewBuilder("GET", "/").MustReadString()
+
+	s.expireNonce()
+	client.NewBuilder("GET", "/").MustReadString()
+	client.NewBuilder("GET", "/").MustReadString()
+
+	auth := s.received()
+	require.Len(t, auth, 5)
+	assert.Equal(t, "00000002", headers.ParseChallenges(auth[2])[0].Params["nc"]) // Rejected as stale.
+	assert.Equal(t, "00000001", headers.ParseChallenges(auth[3])[0].Params["nc"])
+	assert.Equal(t, "nonce-2", headers.ParseChallenges(auth[3])[0].Params["nonce"])
+	assert.Equal(t, "00000002", headers.ParseChallenges(auth[4])[0].Params["nc"])
+}
+
+func TestDigestAuth_NextNonce(t *testing.T) {
+	s := newDigestServer("MD5", "auth")
+	s.nextNonce = true
+	defer s.Close()
+
+	client := httplib.NewClient(s.URL).WithDigestAuth(s.username, s.password)
+	for i := 0; i < 3; i++ {
+		client.NewBuilder("GET", "/").MustReadString()
+	}
+
+	auth := s.received()
+	require.Len(t, auth, 4)
+	for i, v := range auth[1:] {
+		p := headers.ParseChallenges(v)[0].Params
+		assert.Equal(t, fmt.Sprintf("nonce-%d", i+1), p["nonce"])
+		assert.Equal(t, "00000001", p["nc"])
+	}
+}
+
+func TestDigestAuth_WrongPassword(t *testing.T) {
+	s := newDigestServer("MD5", "auth")
+	defer s.Close()
+
+	client := httplib.NewClient(s.URL).WithDigestAuth(s.username, "wrong")
+	for i := 0; i < 2; i++ {
+		_, err := client.NewBuilder("GET", "/").ReadString()
+		assert.True(t, httplib.IsStatus(err, http.StatusUnauthorized))
+	}
+
+	// The rejected credentials are not sent again: each request is challenged once and then fails.
+	auth := s.received()
+	require.Len(t, auth, 4)
+	assert.Empty(t, auth[0])
+	assert.NotEmpty(t, auth[1])
+	assert.Empty(t, auth[2])
+	assert.NotEmpty(t, auth[3])
+}
+
+func TestDigestAuth_Username(t *testing.T) {
+	t.Run("userhash", func(t *testing.T) {
+		s := newDigestServer("SHA-256", "auth")
+		s.userhash = true
+		defer s.Close()
+
+		content := httplib.NewBuilder("GET", s.URL).WithDigestAuth(s.username, s.password).MustReadString()
+		assert.Empty(t, content)
+
+		p := headers.ParseChallenges(s.received()[1])[0].Params
+		assert.Equal(t, "true", p["userhash"])
+		assert.NotContains(t, p["username"], s.username)
+	})
+
+	t.Run("non-ASCII", func(t *testing.T) {
+		s := newDigestServer("SHA-256", "auth")
+		s.username = "Jäsøn Doe"
+		defer s.Close()
+
+		httplib.NewBuilder("GET", s.URL).WithDigestAuth(s.username, s.password).MustReadString()
+		p := headers.ParseChallenges(s.received()[1])[0].Params
+		assert.Equal(t, "UTF-8''J%C3%A4s%C3%B8n%20Doe", p["username*"])
+	})
+}
+
+func TestDigestAuth_ReaderBody(t *testing.T) {
+	s := newDigestServer("SHA-256", "auth-int")
+	defer s.Close()
+
+	// The body can not be rewound by GetBody(), it is buffered.
+	content, err := httplib.NewBuilder("PUT", s.URL).
+		WithDigestAuth(s.username, s.password).
+		SetReaderBody(iotest.OneByteReader(strings.NewReader("reader body"))).
+		ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "reader body", content)
+}
+
+func TestDigestAuth_NotDigest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="a", Digest realm="b", nonce="n", algorithm=UNKNOWN`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	res, err := httplib.NewBuilder("GET", ts.URL).WithDigestAuth("u", "p").ExpectStatus(http.StatusUnauthorized).Send()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode())
+}
+
+func TestDigestAuth_Redact(t *testing.T) {
+	s := newDigestServer("SHA-256", "auth")
+	defer s.Close()
+
+	var dump strings.Builder
+	client := httplib.NewClient(s.URL).WithDigestAuth(s.username, s.password)
+	client.NewBuilder("GET", "/").WithMiddleware((&httplib.Dumper{}).Middleware(&dump)).MustReadString()
+
+	auth := s.received()
+	require.Len(t, auth, 2)
+	assert.Contains(t, dump.String(), "Authorization: ***\r\n")
+	assert.NotContains(t, dump.String(), headers.ParseChallenges(auth[1])[0].Params["response"])
+}
@@ -0,0 +1,470 @@
+package httplib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/cmstar/go-httplib/headers"
+)
+
+// DefaultDumpBodySize is the MaxBodySize of the Dumper created by NewDumper().
+const DefaultDumpBodySize = 4096
+
+// Dumper writes requests and responses as HTTP/1.1 messages, such as:
+//
+//	POST /path?token=*** HTTP/1.1
+//	Host: example.org
+//	Authorization: ***
+//	Content-Type: application/json
+//	Content-Length: 32
+//
+//	{"user":"u","password":"***"}
+//
+// Unlike httputil.DumpRequestOut(), the bodies are not consumed: the request body is read from GetBody()
+// if it is available, otherwise the part being dumped is read and put back in front of the rest,
+// so does the response body. The lines are separated by CRLF; the bodies are written as they are read
+// by the http.Client, i.e. not chunked and decompressed if the transport decompressed them.
+//
+// A body which is not text is replaced with a line like '[binary data, 100 bytes]'.
+//
+// A Dumper can be used concurrently, its fields should not be modified after it is in use.
+type Dumper struct {
+	// MaxBodySize is the max number of bytes of a body to be written, the rest is replaced with a line like
+	// '[truncated, 10000 bytes in total]'. Zero means no limit. A negative value omits the bodies.
+	MaxBodySize int
+
+	// MaskHeaders gives the headers whose values are replaced with RedactedValue, the names are case-insensitive.
+	MaskHeaders []string
+
+	// MaskQuery gives the query parameters in the request URI whose values are replaced with RedactedValue.
+	// The names are case-sensitive.
+	MaskQuery []string
+
+	// MaskJSONFields gives the fields of JSON bodies whose values are replaced with RedactedValue,
+	// the fields are matched at any depth, the names are case-insensitive. If the value of such a field is
+	// an object or an array, the whole value is replaced. Only bodies with a JSON Content-Type are masked,
+	// including the truncated ones.
+	MaskJSONFields []string
+}
+
+// NewDumper creates a Dumper which truncates the bodies at DefaultDumpBodySize bytes and masks SensitiveHeaders.
+func NewDumper() *Dumper {
+	return &Dumper{
+		MaxBodySize: DefaultDumpBodySize,
+		MaskHeaders: SensitiveHeaders,
+	}
+}
+
+// Middleware returns a Middleware which writes the requests and the responses to w.
+// Each message is written by a single call to w.Write() and is followed by an empty line.
+// The response is not written if the request fails.
+//
+// The requests are dumped as they are given to the next Doer, so the headers added later by
+// the http.Client, such as User-Agent and Accept-Encoding, are not included.
+func (d *Dumper) Middleware(w io.Writer) Middleware {
+	var mu sync.Mutex
+	write := func(data []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(append(data, "\r\n"...))
+	}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			data, err := d.DumpRequest(req)
+			if err != nil {
+				return nil, err
+			}
+			write(data)
+
+			res, err := next.Do(req)
+			if err != nil {
+				return res, err
+			}
+
+			data, err = d.DumpResponse(res)
+			if err != nil {
+				res.Body.Close()
+				return nil, err
+			}
+			write(data)
+			return res, nil
+		})
+	}
+}
+
+// DumpRequest returns the request in the HTTP/1.1 format, the body can still be sent after it.
+// The Authorization header set by RequestBuilder.WithAuthScheme() and the like is always masked.
+func (d *Dumper) DumpRequest(req *http.Request) ([]byte, error) {
+	var b bytes.Buffer
+
+	uri := req.URL.RequestURI()
+	if req.URL.RawQuery != "" && len(d.MaskQuery) > 0 {
+		path, _, _ := strings.Cut(uri, "?")
+		uri = path + "?" + maskQuery(req.URL.RawQuery, d.MaskQuery)
+	}
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\n", valueOrDefault(req.Method, http.MethodGet), uri)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	fmt.Fprintf(&b, "Host: %s\r\n", host)
+
+	header := req.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Del("Host")
+	header.Del("Content-Length")
+	if len(req.TransferEncoding) > 0 {
+		header.Set(headers.TransferEncoding, strings.Join(req.TransferEncoding, ", "))
+	} else if req.ContentLength > 0 {
+		header.Set("Content-Length", strconv.FormatInt(req.ContentLength, 10))
+	}
+	if HasCredentials(req) {
+		// The credentials set by WithAuthScheme() and the like are always masked.
+		d.writeHeader(&b, header, headers.Authorization)
+	} else {
+		d.writeHeader(&b, header)
+	}
+
+	body, total, err := d.peekRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if total < 0 {
+		total = req.ContentLength
+	}
+	d.writeBody(&b, req.Header, body, total)
+
+	return b.Bytes(), nil
+}
+
+// DumpResponse returns the response in the HTTP/1.1 format, the body can still be read after it.
+func (d *Dumper) DumpResponse(res *http.Response) ([]byte, error) {
+	var b bytes.Buffer
+
+	status := res.Status
+	if status == "" {
+		status = strconv.Itoa(res.StatusCode) + " " + http.StatusText(res.StatusCode)
+	}
+	fmt.Fprintf(&b, "%s %s\r\n", valueOrDefault(res.Proto, "HTTP/1.1"), status)
+
+	header := res.Header
+	if len(res.TransferEncoding) > 0 {
+		header = header.Clone()
+		header.Set(headers.TransferEncoding, strings.Join(res.TransferEncoding, ", "))
+	}
+	d.writeHeader(&b, header)
+
+	body, total, err := d.peekBody(&res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if total < 0 {
+		total = res.ContentLength
+	}
+	d.writeBody(&b, res.Header, body, total)
+
+	return b.Bytes(), nil
+}
+
+// writeHeader writes the header, the headers given by MaskHeaders and extraMasks are masked.
+func (d *Dumper) writeHeader(b *bytes.Buffer, header http.Header, extraMasks ...string) {
+	masks := make(map[string]bool, len(d.MaskHeaders)+len(extraMasks))
+	for _, name := range d.MaskHeaders {
+		masks[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, name := range extraMasks {
+		masks[http.CanonicalHeaderKey(name)] = true
+	}
+
+	names := make([]string, 0, len(header))
+	for k := range header {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, v := range header[name] {
+			if masks[http.CanonicalHeaderKey(name)] {
+				v = RedactedValue
+			}
+			fmt.Fprintf(b, "%s: %s\r\n", name, v)
+		}
+	}
+	b.WriteString("\r\n")
+}
+
+// writeBody writes the body which may be truncated. total is the length of the whole body, -1 if unknown.
+func (d *Dumper) writeBody(b *bytes.Buffer, header http.Header, body []byte, total int64) {
+	if len(body) == 0 {
+		return
+	}
+
+	truncated := d.MaxBodySize > 0 && len(body) > d.MaxBodySize
+	if truncated {
+		body = trimIncompleteRune(body[:d.MaxBodySize])
+	}
+
+	if !isPrintableText(body) {
+		if truncated {
+			fmt.Fprintf(b, "[binary data, %s]\r\n", describeBodySize(total))
+		} else {
+			fmt.Fprintf(b, "[binary data, %d bytes]\r\n", len(body))
+		}
+		return
+	}
+
+	if len(d.MaskJSONFields) > 0 && isJSONContentType(header.Get(headers.ContentType)) {
+		body = maskJSON(body, d.MaskJSONFields)
+	}
+	b.Write(body)
+
+	if truncated {
+		fmt.Fprintf(b, "\r\n[truncated, %s]", describeBodySize(total))
+	}
+	b.WriteString("\r\n")
+}
+
+// peekRequestBody reads the part of the request body to be dumped. See peekBody() for the return values.
+func (d *Dumper) peekRequestBody(req *http.Request) ([]byte, int64, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, 0, nil
+	}
+
+	if req.GetBody == nil {
+		return d.peekBody(&req.Body)
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer body.Close()
+	return d.peekBody(&body)
+}
+
+// peekBody reads the part of the body to be dumped, which is MaxBodySize+1 bytes at most, and puts the bytes
+// back in front of the body. Returns the bytes and the total length of the body, which is -1 if the body
+// is not read to the end.
+func (d *Dumper) peekBody(body *io.ReadCloser) ([]byte, int64, error) {
+	if d.MaxBodySize < 0 || *body == nil || *body == http.NoBody {
+		return nil, -1, nil
+	}
+
+	r := io.Reader(*body)
+	if d.MaxBodySize > 0 {
+		r = io.LimitReader(r, int64(d.MaxBodySize)+1)
+	}
+
+	data, err := io.ReadAll(r)
+	*body = &peekedBody{io.MultiReader(bytes.NewReader(data), *body), *body}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if d.MaxBodySize > 0 && len(data) > d.MaxBodySize {
+		return data, -1, nil
+	}
+	return data, int64(len(data)), nil
+}
+
+// peekedBody is a body whose beginning has been read, the Reader reads the bytes already read and then the rest.
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+func describeBodySize(total int64) string {
+	if total < 0 {
+		return "the length is unknown"
+	}
+	return strconv.FormatInt(total, 10) + " bytes in total"
+}
+
+func valueOrDefault(v, defaultValue string) string {
+	if v == "" {
+		return defaultValue
+	}
+	return v
+}
+
+// trimIncompleteRune removes the incomplete UTF-8 sequence at the end of data, which is cut by truncation.
+func trimIncompleteRune(data []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		c := data[len(data)-i]
+		if c < utf8.RuneSelf {
+			break
+		}
+		if utf8.RuneStart(c) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return data[:len(data)-i]
+			}
+			break
+		}
+	}
+	return data
+}
+
+func isJSONContentType(contentType string) bool {
+	mt, err := headers.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mt.Matches(headers.MediaTypeJSON) || mt.Type == "application" && mt.Suffix == "json"
+}
+
+// maskQuery replaces the values of the given parameters in the raw query, the order of the parameters is kept.
+func maskQuery(rawQuery string, names []string) string {
+	pairs := strings.Split(rawQuery, "&")
+	for i, pair := range pairs {
+		k, _, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+
+		name, err := url.QueryUnescape(k)
+		if err != nil {
+			continue
+		}
+
+		for _, n := range names {
+			if n == name {
+				pairs[i] = k + "=" + url.QueryEscape(RedactedValue)
+				break
+			}
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// maskJSON replaces the values of the given fields in the JSON with RedactedValue, the formatting is kept.
+// data can be truncated, the tokens are scanned without validating the document.
+func maskJSON(data []byte, fields []string) []byte {
+	masks := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		masks[strings.ToLower(f)] = true
+	}
+
+	// The stack of the containers, true for objects. expectKey is true if the next string in the object is a key.
+	var stack []bool
+	expectKey := false
+	key := ""
+	redacted, _ := json.Marshal(RedactedValue)
+
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch c {
+		case '"':
+			end := scanJSONString(data, i)
+			if expectKey {
+				key = string(data[i:end])
+				if s, err := strconv.Unquote(key); err == nil {
+					key = s
+				}
+			}
+			out = append(out, data[i:end]...)
+			i = end
+			continue
+
+		case '{':
+			stack = append(stack, true)
+			expectKey = true
+
+		case '[':
+			stack = append(stack, false)
+			expectKey = false
+
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			expectKey = false
+
+		case ',':
+			expectKey = len(stack) > 0 && stack[len(stack)-1]
+
+		case ':':
+			expectKey = false
+			out = append(out, c)
+			i++
+
+			for i < len(data) && isJSONSpace(data[i]) {
+				out = append(out, data[i])
+				i++
+			}
+
+			if masks[strings.ToLower(key)] && i < len(data) {
+				out = append(out, redacted...)
+				i = skipJSONValue(data, i)
+			}
+			continue
+		}
+
+		out = append(out, c)
+		i++
+	}
+	return out
+}
+
+// scanJSONString returns the index after the end of the string starts at data[i], or len(data) if it is not closed.
+func scanJSONString(data []byte, i int) int {
+	for i++; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+// skipJSONValue returns the index after the end of the value starts at data[i], or len(data) if it is not closed.
+func skipJSONValue(data []byte, i int) int {
+	switch data[i] {
+	case '"':
+		return scanJSONString(data, i)
+
+	case '{', '[':
+		depth := 0
+		for i < len(data) {
+			switch data[i] {
+			case '"':
+				i = scanJSONString(data, i)
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+			i++
+		}
+		return len(data)
+
+	default:
+		for i < len(data) && !isJSONSpace(data[i]) && strings.IndexByte(",}]", data[i]) < 0 {
+			i++
+		}
+		return i
+	}
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
@@ -0,0 +1,171 @@
+package httplib_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumper_DumpRequest(t *testing.T) {
+	d := httplib.NewDumper()
+	d.MaskQuery = []string{"token"}
+	d.MaskJSONFields = []string{"password", "card"}
+
+	req, err := httplib.NewBuilder("POST", "http://temp.org/path").
+		WithQuery("token", "abc").
+		WithQuery("a", "1 2").
+		WithHeader("Authorization", "Bearer xx").
+		SetJSONBody(map[string]any{
+			"user":     "u",
+			"Password": "p",
+			"card":     map[string]any{"no": "1234", "cvv": 1},
+			"items":    []any{map[string]any{"password": nil}},
+		}).
+		Build()
+	require.NoError(t, err)
+
+	data, err := d.DumpRequest(req)
+	require.NoError(t, err)
+	assert.Equal(t, "POST /path?a=1+2&token=%2A%2A%2A HTTP/1.1\r\n"+
+		"Host: temp.org\r\n"+
+		"Authorization: ***\r\n"+
+		"Content-Length: 84\r\n"+
+		"Content-Type: application/json\r\n"+
+		"\r\n"+
+		`{"Password":"***","card":"***","items":[{"password":"***"}],"user":"u"}`+"\r\n",
+		string(data))
+
+	// The body is not consumed.
+	body, _ := io.ReadAll(req.Body)
+	assert.Contains(t, string(body), `"cvv":1`)
+}
+
+func TestDumper_DumpRequest_Reader(t *testing.T) {
+	d := &httplib.Dumper{MaxBodySize: 5}
+
+	req, err := http.NewRequest("PUT", "http://temp.org/", io.NopCloser(strings.NewReader("0123456789")))
+	require.NoError(t, err)
+	req.Host = "other.org"
+	req.ContentLength = -1
+	req.TransferEncoding = []string{"chunked"}
+
+	data, err := d.DumpRequest(req)
+	require.NoError(t, err)
+	assert.Equal(t, "PUT / HTTP/1.1\r\n"+
+		"Host: other.org\r\n"+
+		"Transfer-Encoding: chunked\r\n"+
+		"\r\n"+
+		"01234\r\n"+
+		"[truncated, the length is unknown]\r\n",
+		string(data))
+
+	// The body is put back.
+	body, _ := io.ReadAll(req.Body)
+	assert.Equal(t, "0123456789", string(body))
+	assert.NoError(t, req.Body.Close())
+}
+
+func TestDumper_DumpResponse(t *testing.T) {
+	newResponse := func(contentType string, body string) *http.Response {
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    200,
+			Proto:         "HTTP/1.1",
+			Header:        http.Header{"Content-Type": {contentType}, "Set-Cookie": {"a=1", "b=2"}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+		}
+	}
+
+	t.Run("truncated-json", func(t *testing.T) {
+		d := httplib.NewDumper()
+		d.MaxBodySize = 24
+		d.MaskJSONFields = []string{"secret"}
+
+		res := newResponse("application/problem+json", `{"a": "中", "secret": {"b": [1, 2, 3]}}`)
+		data, err := d.DumpResponse(res)
+		require.NoError(t, err)
+		assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+			"Content-Type: application/problem+json\r\n"+
+			"Set-Cookie: ***\r\n"+
+			"Set-Cookie: ***\r\n"+
+			"\r\n"+
+			`{"a": "中", "secret": "***"`+"\r\n"+
+			"[truncated, 40 bytes in total]\r\n",
+			string(data))
+
+		body, _ := io.ReadAll(res.Body)
+		assert.Equal(t, `{"a": "中", "secret": {"b": [1, 2, 3]}}`, string(body))
+	})
+
+	t.Run("truncated-rune", func(t *testing.T) {
+		d := &httplib.Dumper{MaxBodySize: 2}
+		data, err := d.DumpResponse(newResponse("text/plain", "a中"))
+		require.NoError(t, err)
+		assert.True(t, strings.HasSuffix(string(data), "\r\n\r\na\r\n[truncated, 4 bytes in total]\r\n"), string(data))
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		d := &httplib.Dumper{}
+		res := newResponse("application/octet-stream", "\x00\x01\x02")
+		res.Status = ""
+		res.Proto = ""
+
+		data, err := d.DumpResponse(res)
+		require.NoError(t, err)
+		assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+			"Content-Type: application/octet-stream\r\n"+
+			"Set-Cookie: a=1\r\n"+
+			"Set-Cookie: b=2\r\n"+
+			"\r\n"+
+			"[binary data, 3 bytes]\r\n",
+			string(data))
+	})
+
+	t.Run("omit-body", func(t *testing.T) {
+		d := &httplib.Dumper{MaxBodySize: -1}
+		res := newResponse("text/plain", "body")
+		data, err := d.DumpResponse(res)
+		require.NoError(t, err)
+		assert.True(t, strings.HasSuffix(string(data), "\r\n\r\n"))
+
+		body, _ := io.ReadAll(res.Body)
+		assert.Equal(t, "body", string(body))
+	})
+}
+
+func TestDumper_Middleware(t *testing.T) {
+	s := NewTestServer(http.StatusOK, []byte("created"))
+	defer s.Close()
+
+	var buf bytes.Buffer
+	c := httplib.NewClient(s.URL).WithMiddleware(httplib.NewDumper().Middleware(&buf))
+
+	content, err := c.NewBuilder("POST", "/items").
+		WithHeader("Cookie", "a=1").
+		SetReaderBody(strings.NewReader("request body")).
+		ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "created", content)
+	assert.Equal(t, "request body", string(s.Body))
+
+	dump := buf.String()
+	assert.Contains(t, dump, "POST /items HTTP/1.1\r\nHost: "+s.Listener.Addr().String()+"\r\nContent-Length: 12\r\nCookie: ***\r\n\r\nrequest body\r\n\r\n")
+	assert.Contains(t, dump, "HTTP/1.1 200 OK\r\n")
+	assert.True(t, strings.HasSuffix(dump, "\r\n\r\ncreated\r\n\r\n"), dump)
+
+	// The response is not dumped if the request fails.
+	buf.Reset()
+	_, err = httplib.NewBuilder("GET", "http://127.0.0.1:1/").
+		WithMiddleware(httplib.NewDumper().Middleware(&buf)).
+		ReadString()
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(buf.String(), "GET / HTTP/1.1\r\nHost: 127.0.0.1:1\r\n\r\n"))
+	assert.NotContains(t, buf.String(), "HTTP/1.1 ")
+}
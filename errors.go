@@ -0,0 +1,93 @@
+package httplib
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// MaxErrorBodySize is the max number of bytes of the response body kept in HTTPError.Body .
+const MaxErrorBodySize = 4096
+
+// maxDrainSize is the max number of bytes read from a discarded response body, so that the connection can be reused.
+// A longer body is not worth reading, the connection is closed instead.
+const maxDrainSize = 4096
+
+// HTTPError is returned by the Read* methods of RequestBuilder and the shortcut functions
+// when the status code of the response is not accepted.
+// Use errors.As() to get it from an error.
+type HTTPError struct {
+	// Method is the method of the request.
+	Method string
+
+	// URL is the URL of the request.
+	URL string
+
+	// StatusCode is the status code of the response, e.g. 404.
+	StatusCode int
+
+	// Status is the status line of the response, e.g. '404 Not Found'.
+	Status string
+
+	// Header is the header of the response.
+	Header http.Header
+
+	// Body contains the leading bytes of the response body, up to MaxErrorBodySize bytes.
+	Body []byte
+}
+
+// newHTTPError creates an HTTPError from the given response, reads the leading bytes of its body.
+// The body is not closed.
+func newHTTPError(res *http.Response) *HTTPError {
+	e := &HTTPError{
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Header:     res.Header,
+	}
+
+	if res.Request != nil {
+		e.Method = res.Request.Method
+		if res.Request.URL != nil {
+			e.URL = res.Request.URL.String()
+		}
+	}
+
+	if res.Body != nil {
+		// Errors are ignored, the body is only used for diagnosing.
+		e.Body, _ = io.ReadAll(io.LimitReader(res.Body, MaxErrorBodySize))
+	}
+
+	return e
+}
+
+// Error implements the error interface. It gives the status, method and URL, e.g. '404 Not Found (GET http://example.org)'.
+func (e *HTTPError) Error() string {
+	status := e.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	if e.Method == "" && e.URL == "" {
+		return status
+	}
+	return fmt.Sprintf("%s (%s %s)", status, e.Method, e.URL)
+}
+
+// IsClientError returns true if the error is an *HTTPError with a status code in 4xx.
+func IsClientError(err error) bool {
+	var e *HTTPError
+	return errors.As(err, &e) && e.StatusCode >= 400 && e.StatusCode < 500
+}
+
+// IsServerError returns true if the error is an *HTTPError with a status code in 5xx.
+func IsServerError(err error) bool {
+	var e *HTTPError
+	return errors.As(err, &e) && e.StatusCode >= 500 && e.StatusCode < 600
+}
+
+// IsStatus returns true if the error is an *HTTPError with the given status code.
+func IsStatus(err error, statusCode int) bool {
+	var e *HTTPError
+	return errors.As(err, &e) && e.StatusCode == statusCode
+}
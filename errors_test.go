@@ -0,0 +1,75 @@
+package httplib_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPError(t *testing.T) {
+	t.Run("fields", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Reason", "missing")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+		}))
+		defer s.Close()
+
+		_, err := httplib.NewBuilder("GET", s.URL+"/path").WithQuery("a", 1).ReadBinary()
+
+		var e *httplib.HTTPError
+		assert := assert.New(t)
+		assert.True(errors.As(err, &e))
+		assert.Equal("GET", e.Method)
+		assert.Equal(s.URL+"/path?a=1", e.URL)
+		assert.Equal(http.StatusNotFound, e.StatusCode)
+		assert.Equal("404 Not Found", e.Status)
+		assert.Equal("missing", e.Header.Get("X-Reason"))
+		assert.Equal("not found", string(e.Body))
+		assert.Equal("404 Not Found (GET "+s.URL+"/path?a=1)", e.Error())
+	})
+
+	t.Run("body-limit", func(t *testing.T) {
+		body := strings.Repeat("a", httplib.MaxErrorBodySize+10)
+		s := NewTestServer(http.StatusBadGateway, []byte(body))
+		defer s.Close()
+
+		_, err := httplib.Get(s.URL)
+
+		var e *httplib.HTTPError
+		assert.True(t, errors.As(err, &e))
+		assert.Equal(t, body[:httplib.MaxErrorBodySize], string(e.Body))
+	})
+
+	t.Run("error-text", func(t *testing.T) {
+		assert.Equal(t, "500 Internal Server Error", (&httplib.HTTPError{StatusCode: 500}).Error())
+		assert.Equal(t, "500 Oops", (&httplib.HTTPError{StatusCode: 500, Status: "500 Oops"}).Error())
+	})
+}
+
+func TestIsStatus(t *testing.T) {
+	notFound := fmt.Errorf("wrapped: %w", &httplib.HTTPError{StatusCode: 404})
+	unavailable := &httplib.HTTPError{StatusCode: 503}
+	other := errors.New("other")
+
+	assert := assert.New(t)
+	assert.True(httplib.IsClientError(notFound))
+	assert.False(httplib.IsClientError(unavailable))
+	assert.False(httplib.IsClientError(other))
+	assert.False(httplib.IsClientError(nil))
+
+	assert.False(httplib.IsServerError(notFound))
+	assert.True(httplib.IsServerError(unavailable))
+	assert.False(httplib.IsServerError(other))
+
+	assert.True(httplib.IsStatus(notFound, 404))
+	assert.False(httplib.IsStatus(notFound, 400))
+	assert.True(httplib.IsStatus(unavailable, 503))
+	assert.False(httplib.IsStatus(other, 503))
+}
@@ -0,0 +1,231 @@
+// Package har records the HTTP traffic sent through httplib into HAR (HTTP Archive) 1.2 files,
+// which can be viewed in the developer tools of browsers.
+//
+// The format is described at http://www.softwareishard.com/blog/har-12-spec/ .
+package har
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// Version is the version of the HAR format written by this package.
+const Version = "1.2"
+
+// HAR is the root object of a HAR file.
+type HAR struct {
+	Log *Log `json:"log"`
+}
+
+// Log contains the recorded entries.
+type Log struct {
+	Version string   `json:"version"`
+	Creator *Creator `json:"creator"`
+	Browser *Creator `json:"browser,omitempty"`
+	Pages   []*Page  `json:"pages,omitempty"`
+	Entries []*Entry `json:"entries"`
+	Comment string   `json:"comment,omitempty"`
+}
+
+// Creator describes the application which created the log.
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// Page groups entries, it is not used by the Recorder, but kept when reading HAR files from browsers.
+type Page struct {
+	StartedDateTime time.Time       `json:"startedDateTime"`
+	ID              string          `json:"id"`
+	Title           string          `json:"title"`
+	PageTimings     json.RawMessage `json:"pageTimings"`
+	Comment         string          `json:"comment,omitempty"`
+}
+
+// Entry is an exported HTTP request.
+type Entry struct {
+	Pageref         string    `json:"pageref,omitempty"`
+	StartedDateTime time.Time `json:"startedDateTime"`
+
+	// Time is the total elapsed time of the request in milliseconds, it is the sum of the Timings except SSL.
+	Time     float64   `json:"time"`
+	Request  *Request  `json:"request"`
+	Response *Response `json:"response"`
+	Cache    *Cache    `json:"cache"`
+	Timings  *Timings  `json:"timings"`
+
+	ServerIPAddress string `json:"serverIPAddress,omitempty"`
+	Connection      string `json:"connection,omitempty"`
+	Comment         string `json:"comment,omitempty"`
+
+	// Error is the error of the request if it failed, the Response is empty in that case.
+	// It is a custom field, the name starts with an underscore as required by the spec.
+	Error string `json:"_error,omitempty"`
+}
+
+// Request contains the detailed info about the request.
+type Request struct {
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	HTTPVersion string       `json:"httpVersion"`
+	Cookies     []*Cookie    `json:"cookies"`
+	Headers     []*NameValue `json:"headers"`
+	QueryString []*NameValue `json:"queryString"`
+	PostData    *PostData    `json:"postData,omitempty"`
+
+	// HeadersSize is -1 if unknown.
+	HeadersSize int64 `json:"headersSize"`
+
+	// BodySize is -1 if unknown.
+	BodySize int64  `json:"bodySize"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// Response contains the detailed info about the response.
+type Response struct {
+	Status      int          `json:"status"`
+	StatusText  string       `json:"statusText"`
+	HTTPVersion string       `json:"httpVersion"`
+	Cookies     []*Cookie    `json:"cookies"`
+	Headers     []*NameValue `json:"headers"`
+	Content     *Content     `json:"content"`
+	RedirectURL string       `json:"redirectURL"`
+
+	// HeadersSize is -1 if unknown.
+	HeadersSize int64 `json:"headersSize"`
+
+	// BodySize is -1 if unknown.
+	BodySize int64  `json:"bodySize"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// Cookie is a cookie of the request or the response.
+type Cookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	HTTPOnly bool       `json:"httpOnly,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+	Comment  string     `json:"comment,omitempty"`
+}
+
+// NameValue is a header or a query parameter.
+type NameValue struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// PostData describes the request body.
+type PostData struct {
+	MimeType string   `json:"mimeType"`
+	Params   []*Param `json:"params,omitempty"`
+	Text     string   `json:"text"`
+	Comment  string   `json:"comment,omitempty"`
+}
+
+// Param is a parameter of a form body.
+type Param struct {
+	Name        string `json:"name"`
+	Value       string `json:"value,omitempty"`
+	FileName    string `json:"fileName,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Comment     string `json:"comment,omitempty"`
+}
+
+// Content describes the response body.
+type Content struct {
+	// Size is the length of the body in bytes, after decompressed.
+	Size int64 `json:"size"`
+
+	// Compression is the number of bytes saved, it is omitted if unknown.
+	Compression int64  `json:"compression,omitempty"`
+	MimeType    string `json:"mimeType"`
+	Text        string `json:"text,omitempty"`
+
+	// Encoding is 'base64' if the Text is encoded in base64, which is used for the binary bodies.
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// Cache contains the info about the browser cache, it is always empty in the entries recorded by this package.
+type Cache struct {
+	BeforeRequest json.RawMessage `json:"beforeRequest,omitempty"`
+	AfterRequest  json.RawMessage `json:"afterRequest,omitempty"`
+	Comment       string          `json:"comment,omitempty"`
+}
+
+// Timings describes the time elapsed by each phase of the request in milliseconds.
+// -1 means the phase does not apply to the request, e.g. there is no DNS lookup when a connection is reused;
+// it is only used by the optional fields Blocked, DNS, Connect and SSL, the others are never negative.
+type Timings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+
+	// SSL is the time of the TLS handshake, it is included in Connect.
+	SSL     float64 `json:"ssl"`
+	Comment string  `json:"comment,omitempty"`
+}
+
+// Write writes the HAR as indented JSON to w.
+func (h *HAR) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(h)
+}
+
+// WriteFile writes the HAR to the named file, the file is created or truncated.
+func (h *HAR) WriteFile(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	err = h.Write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Read reads a HAR from r.
+func Read(r io.Reader) (*HAR, error) {
+	h := new(HAR)
+	if err := json.NewDecoder(r).Decode(h); err != nil {
+		return nil, err
+	}
+
+	if h.Log == nil {
+		h.Log = newLog()
+	}
+	return h, nil
+}
+
+// ReadFile reads a HAR from the named file.
+func ReadFile(name string) (*HAR, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Read(f)
+}
+
+func newLog() *Log {
+	return &Log{
+		Version: Version,
+		Creator: &Creator{Name: "github.com/cmstar/go-httplib"},
+		Entries: []*Entry{},
+	}
+}
@@ -0,0 +1,129 @@
+package har_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmstar/go-httplib/har"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A HAR exported by the developer tools of Chrome, trimmed.
+const browserHAR = `{
+  "log": {
+    "version": "1.2",
+    "creator": {"name": "WebInspector", "version": "537.36"},
+    "pages": [
+      {
+        "startedDateTime": "2023-05-01T08:00:00.000Z",
+        "id": "page_1",
+        "title": "https://example.org/",
+        "pageTimings": {"onContentLoad": 120.5, "onLoad": 300.1}
+      }
+    ],
+    "entries": [
+      {
+        "_initiator": {"type": "other"},
+        "pageref": "page_1",
+        "startedDateTime": "2023-05-01T08:00:00.010Z",
+        "time": 85.2,
+        "request": {
+          "method": "GET",
+          "url": "https://example.org/?q=1",
+          "httpVersion": "http/2.0",
+          "headers": [{"name": ":authority", "value": "example.org"}],
+          "queryString": [{"name": "q", "value": "1"}],
+          "cookies": [],
+          "headersSize": -1,
+          "bodySize": 0
+        },
+        "response": {
+          "status": 200,
+          "statusText": "",
+          "httpVersion": "http/2.0",
+          "headers": [{"name": "content-type", "value": "text/html"}],
+          "cookies": [],
+          "content": {"size": 5, "mimeType": "text/html", "text": "hello"},
+          "redirectURL": "",
+          "headersSize": -1,
+          "bodySize": 5,
+          "_transferSize": 120
+        },
+        "cache": {},
+        "timings": {"blocked": 1.5, "dns": -1, "ssl": -1, "connect": -1, "send": 0.2, "wait": 80, "receive": 3.5},
+        "serverIPAddress": "93.184.216.34",
+        "connection": "443"
+      }
+    ]
+  }
+}`
+
+func TestRead(t *testing.T) {
+	h, err := har.Read(strings.NewReader(browserHAR))
+	require.NoError(t, err)
+
+	log := h.Log
+	assert.Equal(t, "1.2", log.Version)
+	assert.Equal(t, "WebInspector", log.Creator.Name)
+	require.Len(t, log.Pages, 1)
+	assert.Equal(t, "page_1", log.Pages[0].ID)
+
+	require.Len(t, log.Entries, 1)
+	e := log.Entries[0]
+	assert.Equal(t, "page_1", e.Pageref)
+	assert.Equal(t, time.Date(2023, 5, 1, 8, 0, 0, 10*int(time.Millisecond), time.UTC), e.StartedDateTime.UTC())
+	assert.Equal(t, 85.2, e.Time)
+	assert.Equal(t, "https://example.org/?q=1", e.Request.URL)
+	assert.Equal(t, []*har.NameValue{{Name: "q", Value: "1"}}, e.Request.QueryString)
+	assert.Equal(t, "hello", e.Response.Content.Text)
+	assert.Equal(t, &har.Timings{Blocked: 1.5, DNS: -1, SSL: -1, Connect: -1, Send: 0.2, Wait: 80, Receive: 3.5}, e.Timings)
+
+	// The empty document.
+	h, err = har.Read(strings.NewReader("{}"))
+	require.NoError(t, err)
+	assert.Equal(t, har.Version, h.Log.Version)
+	assert.Empty(t, h.Log.Entries)
+
+	_, err = har.Read(strings.NewReader("{"))
+	assert.Error(t, err)
+}
+
+func TestHAR_Write(t *testing.T) {
+	h, err := har.Read(strings.NewReader(browserHAR))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, h.Write(&buf))
+	assert.Contains(t, buf.String(), "\n  \"log\": {\n")
+	assert.Contains(t, buf.String(), `"pageTimings": {`)
+
+	again, err := har.Read(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, h.Log.Entries, again.Log.Entries)
+
+	// The output is stable.
+	var buf2 bytes.Buffer
+	require.NoError(t, again.Write(&buf2))
+	assert.Equal(t, buf.String(), buf2.String())
+}
+
+func TestHAR_WriteFile(t *testing.T) {
+	rec := har.NewRecorder()
+	name := filepath.Join(t.TempDir(), "a.har")
+	require.NoError(t, rec.HAR().WriteFile(name))
+
+	h, err := har.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, har.Version, h.Log.Version)
+	assert.Equal(t, "github.com/cmstar/go-httplib", h.Log.Creator.Name)
+	assert.Empty(t, h.Log.Entries)
+
+	_, err = har.ReadFile(filepath.Join(t.TempDir(), "not-exist"))
+	assert.Error(t, err)
+
+	assert.Error(t, rec.HAR().WriteFile(filepath.Join(t.TempDir(), "not-exist", "a.har")))
+}
@@ -0,0 +1,512 @@
+package har
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/cmstar/go-httplib/headers"
+)
+
+// Recorder records the requests sent through its Middleware as HAR entries. It can be used concurrently.
+//
+// Attach it to a Client or a RequestBuilder with WithMiddleware():
+//
+//	rec := har.NewRecorder()
+//	client := httplib.NewClient("https://example.org").WithMiddleware(rec.Middleware())
+//	// Send requests ...
+//	err := rec.HAR().WriteFile("session.har")
+//
+// An entry is added when the response body is read to the end or closed, or when the request fails.
+// If a RetryPolicy is used, each attempt is an entry. The redirects followed by the http.Client are not
+// recorded separately, the entry contains the first request and the last response.
+type Recorder struct {
+	// MaxBodySize is the max number of bytes of a request or response body to be recorded, the rest is discarded
+	// and the comment of the PostData or the Content is set to 'truncated'. Zero means no limit.
+	MaxBodySize int
+
+	// MaskHeaders gives the headers whose values are replaced with httplib.RedactedValue, the names are
+	// case-insensitive. If Cookie or Set-Cookie is given, the values of the cookies are also replaced.
+	// If it is not empty, the password in the URL is replaced with 'xxxxx'.
+	MaskHeaders []string
+
+	mu  sync.Mutex
+	log *Log
+}
+
+// NewRecorder creates a Recorder with no entry. The credentials in the Authorization and Proxy-Authorization
+// headers are masked, see MaskHeaders.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		MaskHeaders: []string{headers.Authorization, headers.ProxyAuthorization},
+		log:         newLog(),
+	}
+}
+
+// Entries returns a copy of the recorded entries, in the order they are completed.
+func (r *Recorder) Entries() []*Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Entry(nil), r.log.Entries...)
+}
+
+// HAR returns a HAR which contains the entries recorded so far.
+func (r *Recorder) HAR() *HAR {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	log := *r.log
+	log.Entries = append([]*Entry(nil), r.log.Entries...)
+	return &HAR{Log: &log}
+}
+
+// Reset removes all the recorded entries.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.log.Entries = []*Entry{}
+}
+
+func (r *Recorder) add(e *Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.log.Entries = append(r.log.Entries, e)
+}
+
+// Middleware returns the Middleware which records the requests.
+func (r *Recorder) Middleware() httplib.Middleware {
+	return func(next httplib.Doer) httplib.Doer {
+		return httplib.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return r.do(next, req)
+		})
+	}
+}
+
+func (r *Recorder) do(next httplib.Doer, req *http.Request) (*http.Response, error) {
+	e := &Entry{
+		StartedDateTime: time.Now(),
+		Cache:           &Cache{},
+	}
+
+	var err error
+	e.Request, err = r.newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &timer{start: e.StartedDateTime}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), t.clientTrace(e)))
+
+	res, err := next.Do(req)
+	if err != nil {
+		e.Error = err.Error()
+		e.Response = &Response{
+			Cookies:     []*Cookie{},
+			Headers:     []*NameValue{},
+			Content:     &Content{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		}
+		t.finish(e, time.Now())
+		r.add(e)
+		return nil, err
+	}
+
+	e.Response = newResponse(res)
+	r.mask(e.Response.Headers, e.Response.Cookies, headers.SetCookie)
+	res.Body = &recordingBody{
+		ReadCloser: res.Body,
+		recorder:   r,
+		entry:      e,
+		timer:      t,
+	}
+	return res, nil
+}
+
+func (r *Recorder) newRequest(req *http.Request) (*Request, error) {
+	hr := &Request{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []*Cookie{},
+		Headers:     newHeaders(req.Header),
+		QueryString: newQueryString(req.URL.RawQuery),
+		HeadersSize: -1,
+		BodySize:    0,
+	}
+
+	if hr.Method == "" {
+		hr.Method = http.MethodGet
+	}
+
+	if req.Host != "" && req.Host != req.URL.Host {
+		hr.Headers = append([]*NameValue{{Name: "Host", Value: req.Host}}, hr.Headers...)
+	}
+
+	for _, c := range req.Cookies() {
+		hr.Cookies = append(hr.Cookies, &Cookie{Name: c.Name, Value: c.Value})
+	}
+
+	body, err := readRequestBody(req, r.MaxBodySize)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		hr.BodySize = int64(len(body))
+		if r.MaxBodySize > 0 && len(body) > r.MaxBodySize {
+			// The body is not read to the end.
+			hr.BodySize = req.ContentLength
+			if hr.BodySize <= 0 {
+				hr.BodySize = -1
+			}
+		}
+		hr.PostData = r.newPostData(req.Header.Get(headers.ContentType), body)
+	}
+
+	if len(r.MaskHeaders) > 0 {
+		hr.URL = req.URL.Redacted()
+	}
+	r.mask(hr.Headers, hr.Cookies, headers.Cookie)
+
+	// The credentials set by httplib.RequestBuilder.WithAuthScheme() and the like are always masked.
+	if httplib.HasCredentials(req) {
+		for _, nv := range hr.Headers {
+			if http.CanonicalHeaderKey(nv.Name) == headers.Authorization {
+				nv.Value = httplib.RedactedValue
+			}
+		}
+	}
+	return hr, nil
+}
+
+// mask replaces the values of the headers given by MaskHeaders with httplib.RedactedValue. The values of
+// the cookies are also replaced if cookieHeader, which is Cookie or Set-Cookie, is given by MaskHeaders.
+func (r *Recorder) mask(nvs []*NameValue, cookies []*Cookie, cookieHeader string) {
+	for _, name := range r.MaskHeaders {
+		name = http.CanonicalHeaderKey(name)
+		for _, nv := range nvs {
+			if http.CanonicalHeaderKey(nv.Name) == name {
+				nv.Value = httplib.RedactedValue
+			}
+		}
+
+		if name == cookieHeader {
+			for _, c := range cookies {
+				c.Value = httplib.RedactedValue
+			}
+		}
+	}
+}
+
+func (r *Recorder) newPostData(contentType string, body []byte) *PostData {
+	p := &PostData{MimeType: contentType}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/x-www-form-urlencoded" {
+		p.Params = []*Param{}
+		for _, nv := range newQueryString(string(body)) {
+			p.Params = append(p.Params, &Param{Name: nv.Name, Value: nv.Value})
+		}
+	}
+
+	if r.MaxBodySize > 0 && len(body) > r.MaxBodySize {
+		body = body[:r.MaxBodySize]
+		p.Comment = "truncated"
+	}
+	p.Text = string(body)
+	return p
+}
+
+func newResponse(res *http.Response) *Response {
+	hr := &Response{
+		Status:      res.StatusCode,
+		StatusText:  http.StatusText(res.StatusCode),
+		HTTPVersion: res.Proto,
+		Cookies:     []*Cookie{},
+		Headers:     newHeaders(res.Header),
+		Content:     &Content{MimeType: res.Header.Get(headers.ContentType)},
+		RedirectURL: res.Header.Get(headers.Location),
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+
+	if hr.HTTPVersion == "" {
+		hr.HTTPVersion = "HTTP/1.1"
+	}
+
+	for _, c := range res.Cookies() {
+		hc := &Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			hc.Expires = &expires
+		}
+		hr.Cookies = append(hr.Cookies, hc)
+	}
+	return hr
+}
+
+// newHeaders converts the header to name-value pairs sorted by name, the values of the same name keep their order.
+func newHeaders(header http.Header) []*NameValue {
+	names := make([]string, 0, len(header))
+	for k := range header {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	res := []*NameValue{}
+	for _, name := range names {
+		for _, v := range header[name] {
+			res = append(res, &NameValue{Name: name, Value: v})
+		}
+	}
+	return res
+}
+
+// newQueryString converts the raw query to name-value pairs, the order is kept.
+func newQueryString(rawQuery string) []*NameValue {
+	res := []*NameValue{}
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+
+		k, v, _ := strings.Cut(pair, "=")
+		if s, err := url.QueryUnescape(k); err == nil {
+			k = s
+		}
+		if s, err := url.QueryUnescape(v); err == nil {
+			v = s
+		}
+		res = append(res, &NameValue{Name: k, Value: v})
+	}
+	return res
+}
+
+// readRequestBody reads the request body without consuming it, max+1 bytes at most if max is positive.
+// Returns nil if there is no body.
+func readRequestBody(req *http.Request, max int) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	limit := func(r io.Reader) io.Reader {
+		if max > 0 {
+			return io.LimitReader(r, int64(max)+1)
+		}
+		return r
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(limit(body))
+	}
+
+	// Put the bytes read back in front of the body, the rest is not buffered.
+	data, err := io.ReadAll(limit(req.Body))
+	req.Body = &peekedBody{io.MultiReader(bytes.NewReader(data), req.Body), req.Body}
+	return data, err
+}
+
+// peekedBody is a body whose beginning has been read, the Reader reads the bytes already read and then the rest.
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// recordingBody wraps the response body, it keeps the content read and adds the entry at the end of the body.
+type recordingBody struct {
+	io.ReadCloser
+	recorder *Recorder
+	entry    *Entry
+	timer    *timer
+
+	buf  bytes.Buffer
+	size int64
+	once sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+
+	if max := b.recorder.MaxBodySize; max <= 0 || b.buf.Len() < max {
+		keep := p[:n]
+		if max > 0 && b.buf.Len()+n > max {
+			keep = keep[:max-b.buf.Len()]
+		}
+		b.buf.Write(keep)
+	}
+
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *recordingBody) finish() {
+	b.once.Do(func() {
+		c := b.entry.Response.Content
+		c.Size = b.size
+		b.entry.Response.BodySize = b.size
+
+		data := b.buf.Bytes()
+		if int64(len(data)) < b.size {
+			c.Comment = "truncated"
+		}
+
+		if utf8.Valid(data) {
+			c.Text = string(data)
+		} else {
+			c.Text = base64.StdEncoding.EncodeToString(data)
+			c.Encoding = "base64"
+		}
+
+		b.timer.finish(b.entry, time.Now())
+		b.recorder.add(b.entry)
+	})
+}
+
+// timer collects the time of the phases from the httptrace.ClientTrace.
+type timer struct {
+	mu sync.Mutex
+
+	start, getConn, gotConn         time.Time
+	dnsStart, dnsDone               time.Time
+	connectStart, connectDone       time.Time
+	tlsStart, tlsDone               time.Time
+	wroteRequest, firstResponseByte time.Time
+}
+
+func (t *timer) set(p *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Keep the first one, the hooks may be called more than once, e.g. for each address when connecting.
+	if p.IsZero() {
+		*p = time.Now()
+	}
+}
+
+func (t *timer) clientTrace(e *Entry) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) { t.set(&t.getConn) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.set(&t.gotConn)
+			if info.Reused {
+				e.Connection = "reused"
+			}
+			if host, _, err := net.SplitHostPort(info.Conn.RemoteAddr().String()); err == nil {
+				e.ServerIPAddress = host
+			}
+		},
+		DNSStart:             func(httptrace.DNSStartInfo) { t.set(&t.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.set(&t.dnsDone) },
+		ConnectStart:         func(string, string) { t.set(&t.connectStart) },
+		ConnectDone:          func(string, string, error) { t.set(&t.connectDone) },
+		TLSHandshakeStart:    func() { t.set(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.set(&t.tlsDone) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.set(&t.firstResponseByte) },
+	}
+}
+
+// finish sets the Timings and the Time of the entry, end is the time the response body is read.
+func (t *timer) finish(e *Entry, end time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timings := &Timings{
+		DNS:     span(t.dnsStart, t.dnsDone),
+		Connect: span(t.connectStart, t.connectDone),
+		SSL:     span(t.tlsStart, t.tlsDone),
+		// Send, Wait and Receive are required to be non-negative, the events are not fired if
+		// the response is not from the network, e.g. the Doer is a vcr replay.
+		Send:    nonNegative(span(t.gotConn, t.wroteRequest)),
+		Wait:    nonNegative(span(t.wroteRequest, t.firstResponseByte)),
+		Receive: nonNegative(span(t.firstResponseByte, end)),
+	}
+
+	// The SSL time is included in the connect time.
+	if timings.SSL >= 0 {
+		if timings.Connect < 0 {
+			timings.Connect = 0
+		}
+		timings.Connect += timings.SSL
+	}
+
+	// Blocked is the time waiting for the connection, excludes the DNS lookup and connecting.
+	// If no connection is got, the request failed, the time is counted as blocked.
+	blockedEnd := t.gotConn
+	if blockedEnd.IsZero() {
+		blockedEnd = end
+	}
+
+	timings.Blocked = span(t.start, blockedEnd)
+	for _, v := range []float64{timings.DNS, timings.Connect} {
+		if v > 0 {
+			timings.Blocked -= v
+		}
+	}
+	if timings.Blocked < 0 {
+		timings.Blocked = 0
+	}
+
+	e.Timings = timings
+	e.Time = 0
+	for _, v := range []float64{timings.Blocked, timings.DNS, timings.Connect, timings.Send, timings.Wait, timings.Receive} {
+		if v > 0 {
+			e.Time += v
+		}
+	}
+}
+
+// nonNegative returns 0 if v is negative, otherwise v.
+func nonNegative(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	return v
+}
+
+// span returns the milliseconds from start to end, or -1 if either is not set.
+func span(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() {
+		return -1
+	}
+
+	d := end.Sub(start)
+	if d < 0 {
+		d = 0
+	}
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,264 @@
+package har_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/cmstar/go-httplib/har"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		switch r.URL.Path {
+		case "/binary":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte{0xff, 0xfe, 0x00})
+
+		case "/slow":
+			time.Sleep(20 * time.Millisecond)
+			w.Write([]byte("slow"))
+
+		default:
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "1", Path: "/", HttpOnly: true})
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("got " + string(body)))
+		}
+	}))
+}
+
+func TestRecorder(t *testing.T) {
+	s := newTestServer()
+	defer s.Close()
+
+	rec := har.NewRecorder()
+	c := httplib.NewClient(s.URL).WithMiddleware(rec.Middleware())
+
+	content, err := c.NewBuilder("POST", "/items").
+		WithQuery("b", "2").
+		WithQuery("a", "x y").
+		WithHeader("Cookie", "c1=v1; c2=v2").
+		WithForm("name", "中").
+		ExpectStatus(http.StatusCreated).
+		ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "got name=%E4%B8%AD", content)
+
+	entries := rec.Entries()
+	require.Len(t, entries, 1)
+	e := entries[0]
+
+	req := e.Request
+	assert.Equal(t, "POST", req.Method)
+	assert.Equal(t, s.URL+"/items?a=x+y&b=2", req.URL)
+	assert.Equal(t, "HTTP/1.1", req.HTTPVersion)
+	assert.Equal(t, []*har.NameValue{{Name: "a", Value: "x y"}, {Name: "b", Value: "2"}}, req.QueryString)
+	assert.Equal(t, []*har.Cookie{{Name: "c1", Value: "v1"}, {Name: "c2", Value: "v2"}}, req.Cookies)
+	assert.Contains(t, req.Headers, &har.NameValue{Name: "Content-Type", Value: "application/x-www-form-urlencoded"})
+	assert.Equal(t, int64(14), req.BodySize)
+	assert.Equal(t, &har.PostData{
+		MimeType: "application/x-www-form-urlencoded",
+		Params:   []*har.Param{{Name: "name", Value: "中"}},
+		Text:     "name=%E4%B8%AD",
+	}, req.PostData)
+
+	res := e.Response
+	assert.Equal(t, 201, res.Status)
+	assert.Equal(t, "Created", res.StatusText)
+	assert.Equal(t, "HTTP/1.1", res.HTTPVersion)
+	assert.Equal(t, []*har.Cookie{{Name: "sid", Value: "1", Path: "/", HTTPOnly: true}}, res.Cookies)
+	assert.Contains(t, res.Headers, &har.NameValue{Name: "Content-Type", Value: "text/plain"})
+	assert.Equal(t, &har.Content{Size: 18, MimeType: "text/plain", Text: "got name=%E4%B8%AD"}, res.Content)
+	assert.Equal(t, int64(18), res.BodySize)
+
+	assert.Equal(t, "127.0.0.1", e.ServerIPAddress)
+	assert.Empty(t, e.Error)
+	assert.NotNil(t, e.Cache)
+
+	tm := e.Timings
+	assert.Equal(t, float64(-1), tm.SSL)
+	assert.GreaterOrEqual(t, tm.Connect, float64(0))
+	assert.GreaterOrEqual(t, tm.Send, float64(0))
+	assert.GreaterOrEqual(t, tm.Wait, float64(0))
+	assert.GreaterOrEqual(t, tm.Receive, float64(0))
+	assert.InDelta(t, tm.Blocked+tm.Connect+tm.Send+tm.Wait+tm.Receive, e.Time, 0.001)
+}
+
+func TestRecorder_Timings(t *testing.T) {
+	s := newTestServer()
+	defer s.Close()
+
+	rec := har.NewRecorder()
+	b := httplib.NewBuilder("GET", s.URL+"/slow").WithMiddleware(rec.Middleware())
+	_, err := b.ReadString()
+	require.NoError(t, err)
+
+	// The connection is reused.
+	_, err = httplib.NewBuilder("GET", s.URL+"/slow").WithMiddleware(rec.Middleware()).ReadString()
+	require.NoError(t, err)
+
+	entries := rec.Entries()
+	require.Len(t, entries, 2)
+	assert.GreaterOrEqual(t, entries[0].Timings.Wait, float64(20))
+	assert.GreaterOrEqual(t, entries[0].Time, float64(20))
+	assert.Empty(t, entries[0].Connection)
+
+	assert.Equal(t, "reused", entries[1].Connection)
+	assert.Equal(t, float64(-1), entries[1].Timings.Connect)
+	assert.Equal(t, float64(-1), entries[1].Timings.DNS)
+}
+
+func TestRecorder_Body(t *testing.T) {
+	s := newTestServer()
+	defer s.Close()
+
+	rec := har.NewRecorder()
+	rec.MaxBodySize = 4
+	c := httplib.NewClient(s.URL).WithMiddleware(rec.Middleware())
+
+	t.Run("binary", func(t *testing.T) {
+		data, err := c.NewBuilder("GET", "/binary").ReadBinary()
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0xff, 0xfe, 0x00}, data)
+
+		e := rec.Entries()[0]
+		assert.Nil(t, e.Request.PostData)
+		assert.Equal(t, int64(0), e.Request.BodySize)
+		assert.Equal(t, &har.Content{Size: 3, MimeType: "application/octet-stream", Text: "//4A", Encoding: "base64"}, e.Response.Content)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		rec.Reset()
+
+		// The body without GetBody is still sent, only MaxBodySize+1 bytes are read before sending.
+		src := &countingReader{r: strings.NewReader("abcdef")}
+		content, err := c.NewBuilder("PUT", "/").SetReaderBody(src).
+			WithMiddleware(func(next httplib.Doer) httplib.Doer {
+				return httplib.DoerFunc(func(req *http.Request) (*http.Response, error) {
+					assert.Equal(t, 5, src.n)
+					return next.Do(req)
+				})
+			}).
+			ExpectStatus(http.StatusCreated).
+			ReadString()
+		require.NoError(t, err)
+		assert.Equal(t, "got abcdef", content)
+
+		e := rec.Entries()[0]
+		assert.Equal(t, &har.PostData{Text: "abcd", Comment: "truncated"}, e.Request.PostData)
+		assert.Equal(t, int64(-1), e.Request.BodySize) // The length is unknown.
+		assert.Equal(t, &har.Content{Size: 10, MimeType: "text/plain", Text: "got ", Comment: "truncated"}, e.Response.Content)
+
+		// The length is given by Content-Length.
+		rec.Reset()
+		c.NewBuilder("PUT", "/").SetStringBody("abcdef").ExpectStatus(http.StatusCreated).MustReadString()
+		e = rec.Entries()[0]
+		assert.Equal(t, &har.PostData{Text: "abcd", Comment: "truncated"}, e.Request.PostData)
+		assert.Equal(t, int64(6), e.Request.BodySize)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		rec.Reset()
+
+		res, err := c.NewBuilder("GET", "/").ExpectStatus(http.StatusCreated).Do()
+		require.NoError(t, err)
+		assert.Empty(t, rec.Entries())
+
+		res.Body.Close()
+		res.Body.Close()
+		require.Len(t, rec.Entries(), 1)
+		assert.Equal(t, int64(0), rec.Entries()[0].Response.Content.Size)
+	})
+}
+
+func TestRecorder_Error(t *testing.T) {
+	rec := har.NewRecorder()
+	_, err := httplib.NewBuilder("GET", "http://127.0.0.1:1/").WithMiddleware(rec.Middleware()).ReadString()
+	require.Error(t, err)
+
+	entries := rec.Entries()
+	require.Len(t, entries, 1)
+	assert.Equal(t, err.Error(), entries[0].Error)
+	assert.Equal(t, 0, entries[0].Response.Status)
+	assert.Equal(t, int64(-1), entries[0].Response.BodySize)
+	assert.GreaterOrEqual(t, entries[0].Timings.Blocked, float64(0))
+}
+
+func TestRecorder_NotNetwork(t *testing.T) {
+	// The response is not from the network, the trace events are not fired.
+	rec := har.NewRecorder()
+	_, err := httplib.NewBuilder("GET", "http://temp.org/").
+		WithMiddleware(rec.Middleware(), func(httplib.Doer) httplib.Doer {
+			return httplib.DoerFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("ok")), Request: req}, nil
+			})
+		}).
+		ReadString()
+	require.NoError(t, err)
+
+	tm := rec.Entries()[0].Timings
+	assert.Equal(t, float64(-1), tm.DNS)
+	assert.Equal(t, float64(-1), tm.Connect)
+	assert.Equal(t, float64(-1), tm.SSL)
+	assert.GreaterOrEqual(t, tm.Blocked, float64(0))
+	assert.Equal(t, float64(0), tm.Send)
+	assert.Equal(t, float64(0), tm.Wait)
+	assert.Equal(t, float64(0), tm.Receive)
+}
+
+func TestRecorder_MaskHeaders(t *testing.T) {
+	s := newTestServer()
+	defer s.Close()
+
+	rec := har.NewRecorder()
+	c := httplib.NewClient(strings.Replace(s.URL, "://", "://user:pass@", 1)).WithMiddleware(rec.Middleware())
+	_, err := c.NewBuilder("GET", "/").WithBearerToken("token").WithHeader("Cookie", "c=v").ExpectStatus(http.StatusCreated).ReadString()
+	require.NoError(t, err)
+
+	req := rec.Entries()[0].Request
+	assert.Equal(t, strings.Replace(s.URL, "://", "://user:xxxxx@", 1)+"/", req.URL)
+	assert.Contains(t, req.Headers, &har.NameValue{Name: "Authorization", Value: httplib.RedactedValue})
+	assert.Contains(t, req.Headers, &har.NameValue{Name: "Cookie", Value: "c=v"})
+
+	// Mask the cookies.
+	rec = har.NewRecorder()
+	rec.MaskHeaders = []string{"cookie", "set-cookie"}
+	c = httplib.NewClient(s.URL).WithMiddleware(rec.Middleware())
+	_, err = c.NewBuilder("GET", "/").WithHeader("Authorization", "Bearer token").WithHeader("Cookie", "c=v").ExpectStatus(http.StatusCreated).ReadString()
+	require.NoError(t, err)
+
+	e := rec.Entries()[0]
+	assert.Equal(t, s.URL+"/", e.Request.URL)
+	assert.Contains(t, e.Request.Headers, &har.NameValue{Name: "Authorization", Value: "Bearer token"})
+	assert.Contains(t, e.Request.Headers, &har.NameValue{Name: "Cookie", Value: httplib.RedactedValue})
+	assert.Equal(t, []*har.Cookie{{Name: "c", Value: httplib.RedactedValue}}, e.Request.Cookies)
+	assert.Contains(t, e.Response.Headers, &har.NameValue{Name: "Set-Cookie", Value: httplib.RedactedValue})
+	assert.Equal(t, httplib.RedactedValue, e.Response.Cookies[0].Value)
+
+	// The credentials set by WithBearerToken() are always masked.
+	rec.Reset()
+	_, err = c.NewBuilder("GET", "/").WithBearerToken("token").ExpectStatus(http.StatusCreated).ReadString()
+	require.NoError(t, err)
+	assert.Contains(t, rec.Entries()[0].Request.Headers, &har.NameValue{Name: "Authorization", Value: httplib.RedactedValue})
+}
+
+// countingReader counts the bytes read.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
@@ -0,0 +1,329 @@
+package headers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AcceptItem is an element of the Accept, Accept-Language, Accept-Encoding or Accept-Charset header,
+// defined in [RFC 9110] section 12.5.
+//
+// [RFC 9110]: https://datatracker.ietf.org/doc/html/rfc9110#section-12.5
+type AcceptItem struct {
+	// Value is the lower-cased media range, language range, content coding or charset,
+	// such as 'text/*', 'en-us', 'gzip' or 'utf-8'. It can be '*' or '*/*'.
+	Value string
+
+	// Params contains the parameters of a media range, the names are lower-cased.
+	// The 'q' parameter and the extension parameters after it are not included. It is nil if there is no parameter.
+	Params map[string]string
+
+	// Q is the weight in the range [0, 1], 0 means 'not acceptable'. The default value is 1.
+	Q float64
+}
+
+// String returns the item in the form of the header element, such as 'text/html;level=1;q=0.5'.
+// The parameters are sorted by name.
+func (a AcceptItem) String() string {
+	var b strings.Builder
+	b.WriteString(a.Value)
+
+	names := make([]string, 0, len(a.Params))
+	for k := range a.Params {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		b.WriteString(";" + k + "=" + quote(a.Params[k]))
+	}
+
+	if a.Q != 1 {
+		b.WriteString(";q=" + strconv.FormatFloat(a.Q, 'f', -1, 64))
+	}
+
+	return b.String()
+}
+
+// ParseAccept parses the value of the Accept header. The media ranges are ordered by preference:
+// higher q-value first, then the more specific ones first ('text/html;level=1' > 'text/html' > 'text/*' > '*/*'),
+// then in the order they appear.
+//
+// The parsing is lenient, the elements with an invalid q-value are dropped.
+func ParseAccept(value string) []AcceptItem {
+	return parseAcceptList(value, true, mediaRangeSpecificity)
+}
+
+// ParseAcceptLanguage parses the value of the Accept-Language header. The language ranges are ordered by preference:
+// higher q-value first, then the ranges with more subtags first, then in the order they appear.
+//
+// The parsing is lenient, the elements with an invalid q-value are dropped.
+func ParseAcceptLanguage(value string) []AcceptItem {
+	return parseAcceptList(value, false, languageRangeSpecificity)
+}
+
+// ParseAcceptEncoding parses the value of the Accept-Encoding header. The codings are ordered by preference:
+// higher q-value first, then '*' after the others, then in the order they appear.
+//
+// The parsing is lenient, the elements with an invalid q-value are dropped.
+func ParseAcceptEncoding(value string) []AcceptItem {
+	return parseAcceptList(value, false, tokenSpecificity)
+}
+
+// ParseAcceptCharset parses the value of the Accept-Charset header. The charsets are ordered like ParseAcceptEncoding().
+//
+// The parsing is lenient, the elements with an invalid q-value are dropped.
+func ParseAcceptCharset(value string) []AcceptItem {
+	return parseAcceptList(value, false, tokenSpecificity)
+}
+
+func parseAcceptList(value string, withParams bool, specificity func(AcceptItem) int) []AcceptItem {
+	var res []AcceptItem
+
+	for _, element := range splitList(value) {
+		item, ok := parseAcceptItem(element, withParams)
+		if ok {
+			res = append(res, item)
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Q != res[j].Q {
+			return res[i].Q > res[j].Q
+		}
+		return specificity(res[i]) > specificity(res[j])
+	})
+
+	return res
+}
+
+func parseAcceptItem(element string, withParams bool) (AcceptItem, bool) {
+	// An element without a value, such as ';q=0.5'.
+	parts := splitParams(element)
+	if len(parts) == 0 || strings.HasPrefix(element, ";") {
+		return AcceptItem{}, false
+	}
+
+	item := AcceptItem{
+		Value: strings.ToLower(parts[0]),
+		Q:     1,
+	}
+
+	for _, p := range parts[1:] {
+		name, v, _ := splitPair(p)
+		if name == "q" {
+			q, ok := parseQValue(v)
+			if !ok {
+				return item, false
+			}
+			item.Q = q
+			break // The parameters after q are accept-ext, ignore them.
+		}
+
+		if withParams {
+			if item.Params == nil {
+				item.Params = make(map[string]string)
+			}
+			item.Params[name] = v
+		}
+	}
+
+	return item, true
+}
+
+// parseQValue parses a qvalue: ( "0" [ "." 0*3DIGIT ] ) / ( "1" [ "." 0*3("0") ] ).
+func parseQValue(s string) (float64, bool) {
+	if s == "" || len(s) > 5 || (s[0] != '0' && s[0] != '1') {
+		return 0, false
+	}
+
+	if len(s) > 1 && s[1] != '.' {
+		return 0, false
+	}
+
+	for i := 2; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+	}
+
+	q, err := strconv.ParseFloat(s, 64)
+	if err != nil || q > 1 {
+		return 0, false
+	}
+	return q, true
+}
+
+func mediaRangeSpecificity(a AcceptItem) int {
+	typ, sub := splitMediaType(a.Value)
+	switch {
+	case typ == "*":
+		return 0
+	case sub == "*":
+		return 1
+	default:
+		return 2 + len(a.Params)
+	}
+}
+
+func languageRangeSpecificity(a AcceptItem) int {
+	if a.Value == "*" {
+		return 0
+	}
+	return 1 + strings.Count(a.Value, "-")
+}
+
+func tokenSpecificity(a AcceptItem) int {
+	if a.Value == "*" {
+		return 0
+	}
+	return 1
+}
+
+// splitMediaType splits 'type/subtype' into the type and the subtype.
+// The subtype is empty if there is no '/'.
+func splitMediaType(s string) (typ, sub string) {
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i+1:]
+}
+
+// Negotiate selects the best offer for the value of an Accept, Accept-Language, Accept-Encoding or Accept-Charset header.
+// If any offer contains '/', the offers are matched as media types like NegotiateMediaType();
+// otherwise they are matched as language tags like NegotiateLanguage(), which also works for content codings and charsets.
+// Use NegotiateEncoding() for the special rules of the 'identity' coding.
+func Negotiate(offers []string, header string) string {
+	for _, offer := range offers {
+		if strings.IndexByte(offer, '/') >= 0 {
+			return NegotiateMediaType(offers, header)
+		}
+	}
+	return NegotiateLanguage(offers, header)
+}
+
+// NegotiateMediaType selects the best media type from the offers for the value of the Accept header,
+// as described in RFC 9110 section 12.5.1.
+//
+// The quality of an offer is given by the most specific media range matching it. The offer with the highest quality
+// is returned; if there is a tie, the one appears first in offers wins. Returns an empty string if no offer is acceptable.
+// If the header is empty, any media type is acceptable and the first offer is returned.
+//
+// An offer can have parameters, such as 'text/html;level=1'. A media range with parameters matches an offer only if
+// the offer has all the parameters with the same values.
+func NegotiateMediaType(offers []string, header string) string {
+	return negotiate(offers, ParseAccept(header), func(r AcceptItem, offer string) (int, bool) {
+		o, ok := parseAcceptItem(offer, true)
+		if !ok {
+			return 0, false
+		}
+
+		rType, rSub := splitMediaType(r.Value)
+		oType, oSub := splitMediaType(o.Value)
+		if rType != "*" && rType != oType {
+			return 0, false
+		}
+		if rSub != "*" && rSub != oSub {
+			return 0, false
+		}
+
+		for k, v := range r.Params {
+			if ov, ok := o.Params[k]; !ok || !strings.EqualFold(ov, v) {
+				return 0, false
+			}
+		}
+
+		return mediaRangeSpecificity(r), true
+	})
+}
+
+// NegotiateLanguage selects the best language tag from the offers for the value of the Accept-Language header.
+// A language range matches a tag if it equals to the tag or it is a prefix of the tag followed by '-',
+// e.g. 'en' matches 'en-US', which is the basic filtering of RFC 4647. The comparison is case-insensitive.
+//
+// The rules of selecting the offer are the same as NegotiateMediaType().
+func NegotiateLanguage(offers []string, header string) string {
+	return negotiate(offers, ParseAcceptLanguage(header), func(r AcceptItem, offer string) (int, bool) {
+		offer = strings.ToLower(offer)
+		if r.Value == "*" || r.Value == offer || strings.HasPrefix(offer, r.Value+"-") {
+			return languageRangeSpecificity(r), true
+		}
+		return 0, false
+	})
+}
+
+// NegotiateEncoding selects the best content coding from the offers for the value of the Accept-Encoding header,
+// as described in RFC 9110 section 12.5.3. The 'identity' coding is acceptable unless it is excluded explicitly
+// by 'identity;q=0' or by '*;q=0' without an 'identity' element.
+//
+// The rules of selecting the offer are the same as NegotiateMediaType().
+func NegotiateEncoding(offers []string, header string) string {
+	ranges := ParseAcceptEncoding(header)
+
+	mentioned := false
+	for _, r := range ranges {
+		if r.Value == "identity" || r.Value == "*" {
+			mentioned = true
+			break
+		}
+	}
+
+	if len(ranges) > 0 && !mentioned {
+		// The implicit 'identity' with the lowest preference.
+		ranges = append(ranges, AcceptItem{Value: "identity", Q: 0.001})
+	}
+
+	return negotiateTokens(offers, ranges)
+}
+
+// NegotiateCharset selects the best charset from the offers for the value of the Accept-Charset header.
+// The comparison is case-insensitive.
+//
+// The rules of selecting the offer are the same as NegotiateMediaType().
+func NegotiateCharset(offers []string, header string) string {
+	return negotiateTokens(offers, ParseAcceptCharset(header))
+}
+
+func negotiateTokens(offers []string, ranges []AcceptItem) string {
+	return negotiate(offers, ranges, func(r AcceptItem, offer string) (int, bool) {
+		if r.Value == "*" || strings.EqualFold(r.Value, offer) {
+			return tokenSpecificity(r), true
+		}
+		return 0, false
+	})
+}
+
+// negotiate returns the offer with the highest quality. The quality of an offer is the q-value of
+// the most specific range matching it, the first one wins if there are several of them.
+// match returns the specificity of the range if it matches the offer.
+func negotiate(offers []string, ranges []AcceptItem, match func(r AcceptItem, offer string) (int, bool)) string {
+	if len(offers) == 0 {
+		return ""
+	}
+
+	if len(ranges) == 0 {
+		return offers[0]
+	}
+
+	best := ""
+	bestQ := 0.0
+	for _, offer := range offers {
+		q := 0.0
+		specificity := -1
+		for _, r := range ranges {
+			if s, ok := match(r, offer); ok && s > specificity {
+				q = r.Q
+				specificity = s
+			}
+		}
+
+		if q > bestQ {
+			best = offer
+			bestQ = q
+		}
+	}
+
+	return best
+}
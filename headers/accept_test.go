@@ -0,0 +1,217 @@
+package headers_test
+
+import (
+	"testing"
+
+	"github.com/cmstar/go-httplib/headers"
+	"github.com/stretchr/testify/assert"
+)
+
+func acceptStrings(items []headers.AcceptItem) []string {
+	res := make([]string, 0, len(items))
+	for _, v := range items {
+		res = append(res, v.String())
+	}
+	return res
+}
+
+func TestParseAccept(t *testing.T) {
+	cases := []struct {
+		value string
+		want  []string
+	}{
+		{"", []string{}},
+		{"text/html", []string{"text/html"}},
+		{
+			"text/*, text/plain, text/plain;format=flowed, */*",
+			[]string{"text/plain;format=flowed", "text/plain", "text/*", "*/*"},
+		},
+		{
+			// Example from RFC 9110 section 12.5.1.
+			"text/*;q=0.3, text/plain;q=0.7, text/plain;format=flowed, text/plain;format=fixed;q=0.4, */*;q=0.5",
+			[]string{"text/plain;format=flowed", "text/plain;q=0.7", "*/*;q=0.5", "text/plain;format=fixed;q=0.4", "text/*;q=0.3"},
+		},
+		{
+			"Text/HTML;Level=1;q=0.5;ext=1, application/json",
+			[]string{"application/json", "text/html;level=1;q=0.5"},
+		},
+		{
+			`text/html;charset="utf-8", text/xml;q=0`,
+			[]string{"text/html;charset=utf-8", "text/xml;q=0"},
+		},
+		{
+			// Invalid q-values are dropped.
+			"a/a;q=2, b/b;q=0.1234, c/c;q=x, d/d;q=1.000, e/e;q=0.001, f/f;q=-1, g/g;q=1e0",
+			[]string{"d/d", "e/e;q=0.001"},
+		},
+		{
+			// Keeps the order for the same q-value and specificity.
+			"b/b, a/a;q=0.8, c/c, a/*",
+			[]string{"b/b", "c/c", "a/*", "a/a;q=0.8"},
+		},
+		{";", []string{}},
+		{";q=0.5", []string{}},
+		{"text/html, ;, ;q=0.5", []string{"text/html"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.value, func(t *testing.T) {
+			assert.Equal(t, c.want, acceptStrings(headers.ParseAccept(c.value)))
+		})
+	}
+}
+
+func TestParseAcceptItem(t *testing.T) {
+	items := headers.ParseAccept(`text/html;level=1;q=0.5;ext=1`)
+	assert.Equal(t, []headers.AcceptItem{
+		{Value: "text/html", Params: map[string]string{"level": "1"}, Q: 0.5},
+	}, items)
+
+	// The parameters of other headers are ignored.
+	items = headers.ParseAcceptLanguage(`en;x=1;q=0.5`)
+	assert.Equal(t, []headers.AcceptItem{{Value: "en", Q: 0.5}}, items)
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	assert.Equal(t,
+		[]string{"da", "en-gb;q=0.8", "en;q=0.7"},
+		acceptStrings(headers.ParseAcceptLanguage("da, en-gb;q=0.8, en;q=0.7")))
+
+	assert.Equal(t,
+		[]string{"zh-hant-tw", "zh-cn", "zh", "*", "en;q=0.5"},
+		acceptStrings(headers.ParseAcceptLanguage("*, zh, en;q=0.5, zh-CN, zh-Hant-TW")))
+
+	// The elements without a value are dropped.
+	assert.Equal(t, []string{"en"}, acceptStrings(headers.ParseAcceptLanguage("en, ;")))
+	assert.Equal(t, []string{"en"}, acceptStrings(headers.ParseAcceptLanguage(";q=0.5, en")))
+}
+
+func TestParseAcceptEncoding(t *testing.T) {
+	assert.Equal(t,
+		[]string{"gzip", "identity;q=0.5", "*;q=0"},
+		acceptStrings(headers.ParseAcceptEncoding("gzip;q=1.0, identity; q=0.5, *;q=0")))
+
+	assert.Equal(t,
+		[]string{"br", "gzip", "*"},
+		acceptStrings(headers.ParseAcceptEncoding("*, br, gzip")))
+}
+
+func TestParseAcceptCharset(t *testing.T) {
+	assert.Equal(t,
+		[]string{"iso-8859-5", "utf-8", "unicode-1-1;q=0.8"},
+		acceptStrings(headers.ParseAcceptCharset("iso-8859-5, unicode-1-1;q=0.8, UTF-8;q=1")))
+}
+
+func TestNegotiateMediaType(t *testing.T) {
+	cases := []struct {
+		offers []string
+		header string
+		want   string
+	}{
+		{nil, "*/*", ""},
+		{[]string{"text/html", "application/json"}, "", "text/html"},
+		{[]string{"text/html", "application/json"}, "application/json", "application/json"},
+		{[]string{"text/html", "application/json"}, "application/*", "application/json"},
+		{[]string{"text/html", "application/json"}, "*/*", "text/html"},
+		{[]string{"text/html", "application/json"}, "image/png", ""},
+		{[]string{"text/html", "application/json"}, "text/html;q=0.5, application/json", "application/json"},
+		{[]string{"text/html", "application/json"}, "*/*, text/html;q=0", "application/json"},
+		{[]string{"text/html", "application/json"}, "text/*;q=0.5, */*;q=0.1", "text/html"},
+		{[]string{"Application/JSON"}, "application/json", "Application/JSON"},
+		{[]string{"text/html", "application/json"}, ";, application/json", "application/json"},
+
+		// The most specific range determines the quality.
+		{[]string{"text/plain", "text/html"}, "text/*;q=0.5, text/html", "text/html"},
+		{[]string{"text/plain", "text/html"}, "text/*, text/plain;q=0.2", "text/html"},
+
+		// Parameters.
+		{[]string{"text/plain;format=fixed"}, "text/plain;format=flowed", ""},
+		{[]string{"text/plain;format=flowed"}, "text/plain;format=flowed", "text/plain;format=flowed"},
+		{[]string{"text/plain", "text/plain;format=flowed"}, "text/plain;format=flowed", "text/plain;format=flowed"},
+		{[]string{"text/plain;format=fixed", "text/plain;format=flowed"}, "text/plain;format=fixed;q=0.4, text/plain", "text/plain;format=flowed"},
+
+		// Example from RFC 9110 section 12.5.1.
+		{
+			[]string{"text/plain;format=fixed", "text/html;level=1", "image/jpeg"},
+			"text/*;q=0.3, text/plain;q=0.7, text/plain;format=flowed, text/plain;format=fixed;q=0.4, */*;q=0.5",
+			"image/jpeg",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.header, func(t *testing.T) {
+			assert.Equal(t, c.want, headers.NegotiateMediaType(c.offers, c.header))
+			assert.Equal(t, c.want, headers.Negotiate(c.offers, c.header))
+		})
+	}
+}
+
+func TestNegotiateLanguage(t *testing.T) {
+	cases := []struct {
+		offers []string
+		header string
+		want   string
+	}{
+		{[]string{"en", "zh"}, "", "en"},
+		{[]string{"en", "zh"}, "zh", "zh"},
+		{[]string{"en-US", "zh-CN"}, "zh", "zh-CN"},
+		{[]string{"en-US", "zh-CN"}, "ZH-cn", "zh-CN"},
+		{[]string{"en", "zh"}, "zh-CN", ""},
+		{[]string{"en", "zh"}, "fr", ""},
+		{[]string{"en", "zh"}, "*", "en"},
+		{[]string{"en", "zh"}, "*, en;q=0", "zh"},
+		{[]string{"en-GB", "en-US"}, "en;q=0.5, en-US", "en-US"},
+		{[]string{"da", "en-GB", "en"}, "da;q=0.1, en-gb;q=0.8, en;q=0.7", "en-GB"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.header, func(t *testing.T) {
+			assert.Equal(t, c.want, headers.NegotiateLanguage(c.offers, c.header))
+			assert.Equal(t, c.want, headers.Negotiate(c.offers, c.header))
+		})
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := []struct {
+		offers []string
+		header string
+		want   string
+	}{
+		{[]string{"gzip", "identity"}, "", "gzip"},
+		{[]string{"br", "gzip", "identity"}, "gzip, br", "br"},
+		{[]string{"br", "gzip", "identity"}, "gzip, br;q=0.5", "gzip"},
+		{[]string{"br", "identity"}, "gzip", "identity"},
+		{[]string{"br", "identity"}, "gzip, identity;q=0", ""},
+		{[]string{"br", "identity"}, "gzip, *;q=0", ""},
+		{[]string{"br", "identity"}, "gzip, *;q=0, identity", "identity"},
+		{[]string{"br", "identity"}, "*", "br"},
+		{[]string{"br", "identity"}, "GZIP;q=0.5, BR", "br"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.header, func(t *testing.T) {
+			assert.Equal(t, c.want, headers.NegotiateEncoding(c.offers, c.header))
+		})
+	}
+}
+
+func TestNegotiateCharset(t *testing.T) {
+	cases := []struct {
+		offers []string
+		header string
+		want   string
+	}{
+		{[]string{"utf-8", "gbk"}, "", "utf-8"},
+		{[]string{"utf-8", "gbk"}, "GBK, utf-8;q=0.8", "gbk"},
+		{[]string{"utf-8", "gbk"}, "iso-8859-1", ""},
+		{[]string{"utf-8", "gbk"}, "*, utf-8;q=0", "gbk"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.header, func(t *testing.T) {
+			assert.Equal(t, c.want, headers.NegotiateCharset(c.offers, c.header))
+			assert.Equal(t, c.want, headers.Negotiate(c.offers, c.header))
+		})
+	}
+}
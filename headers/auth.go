@@ -0,0 +1,104 @@
+package headers
+
+import "strings"
+
+// Challenge is an authentication challenge in the WWW-Authenticate or Proxy-Authenticate header,
+// defined in [RFC 9110] section 11.
+//
+// [RFC 9110]: https://datatracker.ietf.org/doc/html/rfc9110#section-11
+type Challenge struct {
+	// Scheme is the authentication scheme as it appears, such as 'Basic' or 'Digest'. It is case-insensitive.
+	Scheme string
+
+	// Token68 is the token68 form of the challenge data, it is empty if the challenge has auth-params.
+	Token68 string
+
+	// Params contains the auth-params, the names are lower-cased, the values are unquoted.
+	// It is nil if there is no auth-param.
+	Params map[string]string
+}
+
+// Is returns true if the scheme of the challenge is the given one, case-insensitively.
+func (c Challenge) Is(scheme string) bool {
+	return strings.EqualFold(c.Scheme, scheme)
+}
+
+// ParseChallenges parses the value of the WWW-Authenticate or Proxy-Authenticate header, which can contain
+// several challenges, such as 'Digest realm="a", qop="auth", Basic realm="b"'.
+// The challenges are in the order they appear.
+//
+// The parsing is lenient, the auth-params before the first scheme are dropped.
+func ParseChallenges(value string) []Challenge {
+	var res []Challenge
+
+	for _, element := range splitList(value) {
+		scheme, rest := element, ""
+		if i := strings.IndexAny(element, " \t"); i > 0 {
+			scheme, rest = element[:i], strings.TrimSpace(element[i+1:])
+		}
+
+		// A new challenge starts with a scheme, which is a token not followed by '='.
+		if isToken(scheme) && !strings.HasPrefix(rest, "=") {
+			res = append(res, Challenge{Scheme: scheme})
+			if rest == "" {
+				continue
+			}
+
+			if isToken68(rest) {
+				res[len(res)-1].Token68 = rest
+				continue
+			}
+			element = rest
+		}
+
+		if len(res) == 0 {
+			continue
+		}
+
+		c := &res[len(res)-1]
+		if name, v, ok := splitPair(element); ok && name != "" {
+			if c.Params == nil {
+				c.Params = make(map[string]string)
+			}
+			c.Params[name] = v
+		}
+	}
+
+	return res
+}
+
+// ParseAuthParams parses a comma-separated list of auth-params, such as the value of the Authentication-Info header
+// defined in [RFC 9110] section 11.6.3. The names are lower-cased, the values are unquoted.
+//
+// [RFC 9110]: https://datatracker.ietf.org/doc/html/rfc9110#section-11.6.3
+func ParseAuthParams(value string) map[string]string {
+	res := make(map[string]string)
+	for _, element := range splitList(value) {
+		if name, v, ok := splitPair(element); ok && name != "" {
+			res[name] = v
+		}
+	}
+	return res
+}
+
+// isToken68 returns true if s is a token68: 1*( ALPHA / DIGIT / "-" / "." / "_" / "~" / "+" / "/" ) *"=" .
+func isToken68(s string) bool {
+	i := 0
+	for ; i < len(s); i++ {
+		c := s[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-._~+/", c) >= 0) {
+			break
+		}
+	}
+
+	if i == 0 {
+		return false
+	}
+
+	for ; i < len(s); i++ {
+		if s[i] != '=' {
+			return false
+		}
+	}
+	return true
+}
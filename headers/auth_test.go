@@ -0,0 +1,46 @@
+package headers_test
+
+import (
+	"testing"
+
+	"github.com/cmstar/go-httplib/headers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChallenges(t *testing.T) {
+	cases := []struct {
+		value string
+		want  []headers.Challenge
+	}{
+		{"", nil},
+		{"Basic", []headers.Challenge{{Scheme: "Basic"}}},
+		{`Basic realm="a b", charset="UTF-8"`, []headers.Challenge{
+			{Scheme: "Basic", Params: map[string]string{"realm": "a b", "charset": "UTF-8"}},
+		}},
+		{`Digest realm="x, y", QOP="auth,auth-int", algorithm=SHA-256, nonce="n\"1", Basic realm=b, Newauth abc==`, []headers.Challenge{
+			{Scheme: "Digest", Params: map[string]string{"realm": "x, y", "qop": "auth,auth-int", "algorithm": "SHA-256", "nonce": `n"1`}},
+			{Scheme: "Basic", Params: map[string]string{"realm": "b"}},
+			{Scheme: "Newauth", Token68: "abc=="},
+		}},
+		{`Bearer realm = "r" , error="invalid_token"`, []headers.Challenge{
+			{Scheme: "Bearer", Params: map[string]string{"realm": "r", "error": "invalid_token"}},
+		}},
+		{`realm="orphan", Negotiate`, []headers.Challenge{{Scheme: "Negotiate"}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.value, func(t *testing.T) {
+			assert.Equal(t, c.want, headers.ParseChallenges(c.value))
+		})
+	}
+
+	assert.True(t, headers.Challenge{Scheme: "DIGEST"}.Is("Digest"))
+	assert.False(t, headers.Challenge{Scheme: "Basic"}.Is("Digest"))
+}
+
+func TestParseAuthParams(t *testing.T) {
+	assert.Equal(t, map[string]string{}, headers.ParseAuthParams(""))
+	assert.Equal(t,
+		map[string]string{"nextnonce": "a,b", "qop": "auth", "nc": "00000001"},
+		headers.ParseAuthParams(`nextnonce="a,b", qop=auth, NC=00000001, invalid`))
+}
@@ -0,0 +1,235 @@
+package headers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CacheControlDirectives is the parsed value of the Cache-Control header, defined in [RFC 9111] section 5.2,
+// with the extensions stale-while-revalidate, stale-if-error ([RFC 5861]) and immutable ([RFC 8246]).
+//
+// The directives with a delta-seconds value are pointers, nil means the directive is absent.
+// The delta-seconds values greater than math.MaxInt32 are clamped.
+//
+// [RFC 9111]: https://datatracker.ietf.org/doc/html/rfc9111
+// [RFC 5861]: https://datatracker.ietf.org/doc/html/rfc5861
+// [RFC 8246]: https://datatracker.ietf.org/doc/html/rfc8246
+type CacheControlDirectives struct {
+	// max-age=<seconds> (request, response)
+	MaxAge *int
+
+	// s-maxage=<seconds> (response)
+	SMaxAge *int
+
+	// max-stale[=<seconds>] (request). A negative value means max-stale without a value,
+	// i.e. the client accepts a stale response of any age.
+	MaxStale *int
+
+	// min-fresh=<seconds> (request)
+	MinFresh *int
+
+	// no-cache[="field-name, ..."] (request, response)
+	NoCache bool
+
+	// The field names given by no-cache (response).
+	NoCacheFields []string
+
+	// no-store (request, response)
+	NoStore bool
+
+	// no-transform (request, response)
+	NoTransform bool
+
+	// only-if-cached (request)
+	OnlyIfCached bool
+
+	// must-revalidate (response)
+	MustRevalidate bool
+
+	// proxy-revalidate (response)
+	ProxyRevalidate bool
+
+	// must-understand (response)
+	MustUnderstand bool
+
+	// private[="field-name, ..."] (response)
+	Private bool
+
+	// The field names given by private (response).
+	PrivateFields []string
+
+	// public (response)
+	Public bool
+
+	// immutable (response)
+	Immutable bool
+
+	// stale-while-revalidate=<seconds> (response)
+	StaleWhileRevalidate *int
+
+	// stale-if-error=<seconds> (request, response)
+	StaleIfError *int
+
+	// Extensions contains the directives which are not listed above, in the order they appear.
+	Extensions []Directive
+}
+
+// Directive is a directive in a header value, such as an extension of Cache-Control.
+type Directive struct {
+	// Name is the lower-cased name of the directive.
+	Name string
+
+	// Value is the unquoted value of the directive.
+	Value string
+
+	// HasValue is true if the directive has a value, even if it is empty.
+	HasValue bool
+}
+
+// ParseCacheControl parses the value of the Cache-Control header.
+// If there are multiple Cache-Control headers, join them with ', ' before parsing.
+//
+// The directive names are case-insensitive. The delta-seconds values can be quoted.
+// Returns an error if a delta-seconds value is invalid.
+func ParseCacheControl(value string) (*CacheControlDirectives, error) {
+	cc := &CacheControlDirectives{}
+
+	for _, item := range splitList(value) {
+		name, v, hasValue := splitPair(item)
+
+		var err error
+		switch name {
+		case "max-age":
+			cc.MaxAge, err = parseDeltaSeconds(name, v, hasValue)
+		case "s-maxage":
+			cc.SMaxAge, err = parseDeltaSeconds(name, v, hasValue)
+		case "max-stale":
+			if hasValue {
+				cc.MaxStale, err = parseDeltaSeconds(name, v, hasValue)
+			} else {
+				anyAge := -1
+				cc.MaxStale = &anyAge
+			}
+		case "min-fresh":
+			cc.MinFresh, err = parseDeltaSeconds(name, v, hasValue)
+		case "stale-while-revalidate":
+			cc.StaleWhileRevalidate, err = parseDeltaSeconds(name, v, hasValue)
+		case "stale-if-error":
+			cc.StaleIfError, err = parseDeltaSeconds(name, v, hasValue)
+		case "no-cache":
+			cc.NoCache = true
+			cc.NoCacheFields = append(cc.NoCacheFields, splitList(v)...)
+		case "private":
+			cc.Private = true
+			cc.PrivateFields = append(cc.PrivateFields, splitList(v)...)
+		case "no-store":
+			cc.NoStore = true
+		case "no-transform":
+			cc.NoTransform = true
+		case "only-if-cached":
+			cc.OnlyIfCached = true
+		case "must-revalidate":
+			cc.MustRevalidate = true
+		case "proxy-revalidate":
+			cc.ProxyRevalidate = true
+		case "must-understand":
+			cc.MustUnderstand = true
+		case "public":
+			cc.Public = true
+		case "immutable":
+			cc.Immutable = true
+		default:
+			cc.Extensions = append(cc.Extensions, Directive{name, v, hasValue})
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cc, nil
+}
+
+func parseDeltaSeconds(name, value string, hasValue bool) (*int, error) {
+	if !hasValue || value == "" {
+		return nil, fmt.Errorf("Cache-Control: %s requires a value", name)
+	}
+
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return nil, fmt.Errorf("Cache-Control: invalid %s value %q", name, value)
+		}
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n > math.MaxInt32 {
+		n = math.MaxInt32
+	}
+
+	v := int(n)
+	return &v, nil
+}
+
+// String returns the value of the Cache-Control header. The directives are in a fixed order,
+// the extensions are at the end.
+func (cc *CacheControlDirectives) String() string {
+	var list []string
+
+	addSeconds := func(name string, v *int) {
+		if v != nil {
+			list = append(list, name+"="+strconv.Itoa(*v))
+		}
+	}
+	addFlag := func(name string, v bool) {
+		if v {
+			list = append(list, name)
+		}
+	}
+	addFields := func(name string, v bool, fields []string) {
+		if !v {
+			return
+		}
+		if len(fields) == 0 {
+			list = append(list, name)
+			return
+		}
+		list = append(list, name+"="+quoteAlways(strings.Join(fields, ", ")))
+	}
+
+	addSeconds("max-age", cc.MaxAge)
+	addSeconds("s-maxage", cc.SMaxAge)
+	if cc.MaxStale != nil && *cc.MaxStale < 0 {
+		list = append(list, "max-stale")
+	} else {
+		addSeconds("max-stale", cc.MaxStale)
+	}
+	addSeconds("min-fresh", cc.MinFresh)
+	addFields("no-cache", cc.NoCache, cc.NoCacheFields)
+	addFlag("no-store", cc.NoStore)
+	addFlag("no-transform", cc.NoTransform)
+	addFlag("only-if-cached", cc.OnlyIfCached)
+	addFlag("must-revalidate", cc.MustRevalidate)
+	addFlag("proxy-revalidate", cc.ProxyRevalidate)
+	addFlag("must-understand", cc.MustUnderstand)
+	addFields("private", cc.Private, cc.PrivateFields)
+	addFlag("public", cc.Public)
+	addFlag("immutable", cc.Immutable)
+	addSeconds("stale-while-revalidate", cc.StaleWhileRevalidate)
+	addSeconds("stale-if-error", cc.StaleIfError)
+
+	for _, d := range cc.Extensions {
+		list = append(list, d.String())
+	}
+
+	return strings.Join(list, ", ")
+}
+
+// String returns the directive in the form 'name' or 'name=value', the value is quoted if it is not a token.
+func (d Directive) String() string {
+	if !d.HasValue {
+		return d.Name
+	}
+	return d.Name + "=" + quote(d.Value)
+}
@@ -0,0 +1,130 @@
+package headers_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cmstar/go-httplib/headers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seconds(v int) *int {
+	return &v
+}
+
+func TestParseCacheControl(t *testing.T) {
+	cases := []struct {
+		value string
+		want  headers.CacheControlDirectives
+	}{
+		// Examples from RFC 9111.
+		{"max-age=3600", headers.CacheControlDirectives{MaxAge: seconds(3600)}},
+		{"no-cache", headers.CacheControlDirectives{NoCache: true}},
+		{"no-store", headers.CacheControlDirectives{NoStore: true}},
+		{"max-stale", headers.CacheControlDirectives{MaxStale: seconds(-1)}},
+		{"max-stale=60, min-fresh=10", headers.CacheControlDirectives{MaxStale: seconds(60), MinFresh: seconds(10)}},
+		{"only-if-cached", headers.CacheControlDirectives{OnlyIfCached: true}},
+		{`private, community="UCI"`, headers.CacheControlDirectives{
+			Private:    true,
+			Extensions: []headers.Directive{{Name: "community", Value: "UCI", HasValue: true}},
+		}},
+		{`no-cache="Set-Cookie, X-Custom"`, headers.CacheControlDirectives{
+			NoCache:       true,
+			NoCacheFields: []string{"Set-Cookie", "X-Custom"},
+		}},
+		{`private="Authorization"`, headers.CacheControlDirectives{
+			Private:       true,
+			PrivateFields: []string{"Authorization"},
+		}},
+		{"s-maxage=60, must-revalidate, proxy-revalidate", headers.CacheControlDirectives{
+			SMaxAge:         seconds(60),
+			MustRevalidate:  true,
+			ProxyRevalidate: true,
+		}},
+		{"no-transform, must-understand, no-store", headers.CacheControlDirectives{
+			NoStore:        true,
+			NoTransform:    true,
+			MustUnderstand: true,
+		}},
+
+		// Examples from RFC 5861 and RFC 8246.
+		{"max-age=600, stale-while-revalidate=30", headers.CacheControlDirectives{
+			MaxAge:               seconds(600),
+			StaleWhileRevalidate: seconds(30),
+		}},
+		{"max-age=600, stale-if-error=1200", headers.CacheControlDirectives{
+			MaxAge:       seconds(600),
+			StaleIfError: seconds(1200),
+		}},
+		{"max-age=31536000, public, immutable", headers.CacheControlDirectives{
+			MaxAge:    seconds(31536000),
+			Public:    true,
+			Immutable: true,
+		}},
+
+		// Extensions.
+		{`ext1, ext2=token, ext3="a, \"b\""`, headers.CacheControlDirectives{
+			Extensions: []headers.Directive{
+				{Name: "ext1"},
+				{Name: "ext2", Value: "token", HasValue: true},
+				{Name: "ext3", Value: `a, "b"`, HasValue: true},
+			},
+		}},
+	}
+
+	// The expected String() which differs from the value.
+	strings := map[string]string{
+		`private, community="UCI"`:                "private, community=UCI",
+		"no-transform, must-understand, no-store": "no-store, no-transform, must-understand",
+	}
+
+	for _, c := range cases {
+		t.Run(c.value, func(t *testing.T) {
+			cc, err := headers.ParseCacheControl(c.value)
+			require.NoError(t, err)
+			assert.Equal(t, c.want, *cc)
+
+			// Round trip.
+			str, ok := strings[c.value]
+			if !ok {
+				str = c.value
+			}
+			assert.Equal(t, str, cc.String())
+			cc2, err := headers.ParseCacheControl(cc.String())
+			require.NoError(t, err)
+			assert.Equal(t, cc, cc2)
+		})
+	}
+}
+
+func TestParseCacheControl_Lenient(t *testing.T) {
+	cases := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{"MAX-AGE=10 ,, No-Cache", "max-age=10, no-cache"},
+		{`max-age="10"`, "max-age=10"},
+		{"max-age=99999999999999999999", "max-age=2147483647"},
+		{"public, max-age=1, max-age=2", "max-age=2, public"},
+		{`no-cache="a", no-cache="b"`, `no-cache="a, b"`},
+	}
+
+	for _, c := range cases {
+		cc, err := headers.ParseCacheControl(c.value)
+		require.NoError(t, err, c.value)
+		assert.Equal(t, c.want, cc.String(), c.value)
+	}
+
+	cc, _ := headers.ParseCacheControl("max-age=99999999999999999999")
+	assert.Equal(t, math.MaxInt32, *cc.MaxAge)
+}
+
+func TestParseCacheControl_Error(t *testing.T) {
+	for _, value := range []string{"max-age", "max-age=", "max-age=-1", "s-maxage=1.5", "min-fresh=abc", "stale-if-error"} {
+		cc, err := headers.ParseCacheControl(value)
+		assert.Error(t, err, value)
+		assert.Nil(t, cc, value)
+	}
+}
@@ -1,4 +1,5 @@
-// Package headers package provides constants of HTTP headers.
+// Package headers package provides constants of HTTP headers, and parsers of some header values
+// such as Cache-Control, Content-Type and Accept.
 //
 // The list of HTTP headers is taken from https://en.wikipedia.org/wiki/List_of_HTTP_header_fields .
 package headers
@@ -336,6 +337,22 @@ const Authorization = "Authorization"
 // [RFC 9111]: https://datatracker.ietf.org/doc/html/rfc9111
 const CacheControl = "Cache-Control"
 
+// Cache-Status
+//
+// Indicates how the caches have handled the request.
+//
+// Class: Response field, Standard
+//
+// Example:
+//
+//	Cache-Status: ExampleCache; hit; ttl=30
+//
+// Standard:
+//   - [RFC 9211]
+//
+// [RFC 9211]: https://datatracker.ietf.org/doc/html/rfc9211
+const CacheStatus = "Cache-Status"
+
 // Connection
 //
 // Control options for the current connection and list of hop-by-hop request fields.
@@ -0,0 +1,188 @@
+package headers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MediaType is a media type used in the Content-Type and Accept headers, defined in [RFC 9110] section 8.3.1,
+// with the structured syntax suffix defined in [RFC 6838] section 4.2.8, e.g. 'application/problem+json; charset=utf-8'.
+//
+// The type, the subtype, the suffix and the parameter names are case-insensitive,
+// they are lower-cased by ParseMediaType().
+//
+// [RFC 9110]: https://datatracker.ietf.org/doc/html/rfc9110#section-8.3.1
+// [RFC 6838]: https://datatracker.ietf.org/doc/html/rfc6838#section-4.2.8
+type MediaType struct {
+	// Type is the top-level type, such as 'application'. It can be '*' for a media range.
+	Type string
+
+	// Subtype is the subtype without the suffix, such as 'problem' of 'application/problem+json'.
+	// It can be '*' for a media range.
+	Subtype string
+
+	// Suffix is the structured syntax suffix without the '+', such as 'json' of 'application/problem+json'.
+	// It is empty if there is no suffix.
+	Suffix string
+
+	// Params contains the parameters, the names are lower-cased. It can be nil.
+	Params map[string]string
+}
+
+// Some commonly used media types.
+var (
+	MediaTypeJSON           = MediaType{Type: "application", Subtype: "json"}
+	MediaTypeXML            = MediaType{Type: "application", Subtype: "xml"}
+	MediaTypeFormURLEncoded = MediaType{Type: "application", Subtype: "x-www-form-urlencoded"}
+	MediaTypeOctetStream    = MediaType{Type: "application", Subtype: "octet-stream"}
+	MediaTypeMultipartForm  = MediaType{Type: "multipart", Subtype: "form-data"}
+	MediaTypeTextPlain      = MediaType{Type: "text", Subtype: "plain"}
+	MediaTypeTextHTML       = MediaType{Type: "text", Subtype: "html"}
+)
+
+// ParseMediaType parses a media type such as 'text/html; charset=utf-8'.
+// The parameter values can be quoted-strings, they are unquoted.
+// Returns an error if the type or the subtype is missing or is not a token.
+func ParseMediaType(value string) (MediaType, error) {
+	parts := splitParams(value)
+	if len(parts) == 0 {
+		return MediaType{}, errors.New("media type: empty value")
+	}
+
+	typ, sub := splitMediaType(strings.ToLower(parts[0]))
+	if typ == "" || sub == "" || !isToken(typ) || !isToken(sub) {
+		return MediaType{}, fmt.Errorf("media type: invalid type/subtype %q", parts[0])
+	}
+
+	mt := MediaType{Type: typ, Subtype: sub}
+	if i := strings.LastIndexByte(sub, '+'); i > 0 && i < len(sub)-1 {
+		mt.Subtype = sub[:i]
+		mt.Suffix = sub[i+1:]
+	}
+
+	for _, p := range parts[1:] {
+		name, v, hasValue := splitPair(p)
+		if name == "" || !hasValue || !isToken(name) {
+			return MediaType{}, fmt.Errorf("media type: invalid parameter %q", p)
+		}
+
+		if mt.Params == nil {
+			mt.Params = make(map[string]string)
+		}
+		mt.Params[name] = v
+	}
+
+	return mt, nil
+}
+
+// MustParseMediaType is like ParseMediaType() but panics if the value can not be parsed.
+func MustParseMediaType(value string) MediaType {
+	mt, err := ParseMediaType(value)
+	if err != nil {
+		panic(err)
+	}
+	return mt
+}
+
+// Essence returns the media type without the parameters, such as 'application/problem+json'.
+func (m MediaType) Essence() string {
+	if m.Suffix == "" {
+		return m.Type + "/" + m.Subtype
+	}
+	return m.Type + "/" + m.Subtype + "+" + m.Suffix
+}
+
+// String returns the value used in headers, such as 'text/html; charset=utf-8'.
+// The parameters are sorted by name, the values are quoted if they are not tokens.
+func (m MediaType) String() string {
+	if len(m.Params) == 0 {
+		return m.Essence()
+	}
+
+	names := make([]string, 0, len(m.Params))
+	for k := range m.Params {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(m.Essence())
+	for _, k := range names {
+		b.WriteString("; " + k + "=" + quote(m.Params[k]))
+	}
+	return b.String()
+}
+
+// Param returns the value of the parameter, the name is case-insensitive.
+// Returns an empty string if the parameter is absent.
+func (m MediaType) Param(name string) string {
+	return m.Params[strings.ToLower(name)]
+}
+
+// WithParam returns a copy of the media type with the parameter set. The original one is not changed.
+func (m MediaType) WithParam(name, value string) MediaType {
+	params := make(map[string]string, len(m.Params)+1)
+	for k, v := range m.Params {
+		params[k] = v
+	}
+	params[strings.ToLower(name)] = value
+
+	m.Params = params
+	return m
+}
+
+// Matches reports whether the given media type matches m, m can be a media range with wildcards.
+// The comparison is case-insensitive.
+//
+//   - '*/*' matches any media type; 'text/*' matches any media type of the type 'text'.
+//   - A subtype without a suffix also matches the media types with the same structured syntax suffix,
+//     e.g. 'application/json' matches 'application/problem+json'.
+//   - 'application/*+json' matches any media type of the type 'application' with the suffix 'json',
+//     and 'application/json' itself.
+//   - If m has parameters, the given media type must have all of them with the same values.
+//     The values are compared case-insensitively.
+func (m MediaType) Matches(other MediaType) bool {
+	if m.Type != "*" && !strings.EqualFold(m.Type, other.Type) {
+		return false
+	}
+
+	if !m.matchesSubtype(other) {
+		return false
+	}
+
+	for k, v := range m.Params {
+		if ov, ok := other.Params[strings.ToLower(k)]; !ok || !strings.EqualFold(ov, v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m MediaType) matchesSubtype(other MediaType) bool {
+	switch {
+	case m.Subtype == "*" && m.Suffix == "":
+		return true
+
+	case m.Subtype == "*":
+		return strings.EqualFold(m.Suffix, other.Suffix) ||
+			(other.Suffix == "" && strings.EqualFold(m.Suffix, other.Subtype))
+
+	case m.Suffix != "":
+		return strings.EqualFold(m.Subtype, other.Subtype) && strings.EqualFold(m.Suffix, other.Suffix)
+
+	default:
+		if other.Suffix == "" {
+			return strings.EqualFold(m.Subtype, other.Subtype)
+		}
+		return strings.EqualFold(m.Subtype, other.Suffix)
+	}
+}
+
+// MatchesString is like Matches() but parses the given value first. Returns false if the value can not be parsed.
+func (m MediaType) MatchesString(value string) bool {
+	other, err := ParseMediaType(value)
+	return err == nil && m.Matches(other)
+}
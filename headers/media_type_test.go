@@ -0,0 +1,124 @@
+package headers_test
+
+import (
+	"testing"
+
+	"github.com/cmstar/go-httplib/headers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMediaType(t *testing.T) {
+	cases := []struct {
+		value string
+		want  headers.MediaType
+		str   string
+	}{
+		{"text/plain", headers.MediaType{Type: "text", Subtype: "plain"}, "text/plain"},
+		{"Text/HTML; Charset=UTF-8", headers.MediaType{
+			Type: "text", Subtype: "html", Params: map[string]string{"charset": "UTF-8"},
+		}, "text/html; charset=UTF-8"},
+		{"application/problem+json", headers.MediaType{
+			Type: "application", Subtype: "problem", Suffix: "json",
+		}, "application/problem+json"},
+		{"application/vnd.api+json;v=1;a=b", headers.MediaType{
+			Type: "application", Subtype: "vnd.api", Suffix: "json", Params: map[string]string{"v": "1", "a": "b"},
+		}, "application/vnd.api+json; a=b; v=1"},
+		{`multipart/form-data; boundary="a b;c"`, headers.MediaType{
+			Type: "multipart", Subtype: "form-data", Params: map[string]string{"boundary": "a b;c"},
+		}, `multipart/form-data; boundary="a b;c"`},
+		{"a/b; x=\"\"", headers.MediaType{
+			Type: "a", Subtype: "b", Params: map[string]string{"x": ""},
+		}, `a/b; x=""`},
+		{"*/*", headers.MediaType{Type: "*", Subtype: "*"}, "*/*"},
+		{"application/*+xml", headers.MediaType{Type: "application", Subtype: "*", Suffix: "xml"}, "application/*+xml"},
+
+		// '+' at the edges is not a suffix.
+		{"a/+b", headers.MediaType{Type: "a", Subtype: "+b"}, "a/+b"},
+		{"a/b+", headers.MediaType{Type: "a", Subtype: "b+"}, "a/b+"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.value, func(t *testing.T) {
+			mt, err := headers.ParseMediaType(c.value)
+			require.NoError(t, err)
+			assert.Equal(t, c.want, mt)
+			assert.Equal(t, c.str, mt.String())
+
+			again, err := headers.ParseMediaType(mt.String())
+			require.NoError(t, err)
+			assert.Equal(t, mt, again)
+		})
+	}
+}
+
+func TestParseMediaType_Error(t *testing.T) {
+	for _, v := range []string{"", " ; ", "text", "text/", "/plain", "te xt/plain", "text/pl@in", "text/plain; a", "text/plain; =b", "text/plain; a b=c"} {
+		_, err := headers.ParseMediaType(v)
+		assert.Error(t, err, v)
+	}
+
+	assert.Panics(t, func() { headers.MustParseMediaType("text") })
+	assert.Equal(t, headers.MediaTypeJSON, headers.MustParseMediaType("application/json"))
+}
+
+func TestMediaType_Param(t *testing.T) {
+	mt := headers.MustParseMediaType("text/plain; charset=utf-8")
+	assert.Equal(t, "utf-8", mt.Param("Charset"))
+	assert.Equal(t, "", mt.Param("boundary"))
+
+	m2 := mt.WithParam("Format", "flowed")
+	assert.Equal(t, "text/plain; charset=utf-8; format=flowed", m2.String())
+	assert.Equal(t, "text/plain; charset=utf-8", mt.String()) // Not changed.
+
+	assert.Equal(t, "application/json; charset=utf-8", headers.MediaTypeJSON.WithParam("charset", "utf-8").String())
+	assert.Equal(t, "application/json", headers.MediaTypeJSON.String())
+	assert.Equal(t, "application/problem+json", headers.MustParseMediaType("application/problem+json; a=1").Essence())
+}
+
+func TestMediaType_Matches(t *testing.T) {
+	cases := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{"text/plain", "text/plain", true},
+		{"text/plain", "TEXT/Plain; charset=utf-8", true},
+		{"text/plain", "text/html", false},
+		{"text/plain", "application/plain", false},
+		{"text/*", "text/html", true},
+		{"text/*", "application/json", false},
+		{"*/*", "application/json", true},
+		{"*/*", "application/problem+json", true},
+
+		// Suffix.
+		{"application/json", "application/problem+json", true},
+		{"application/json", "application/problem+xml", false},
+		{"application/json", "text/problem+json", false},
+		{"application/problem+json", "application/problem+json", true},
+		{"application/problem+json", "application/json", false},
+		{"application/problem+json", "application/other+json", false},
+		{"application/*+json", "application/problem+json", true},
+		{"application/*+json", "application/json", true},
+		{"application/*+json", "application/xml", false},
+		{"application/*+json", "application/problem+xml", false},
+		{"*/*+xml", "image/svg+xml", true},
+
+		// Parameters.
+		{"text/plain; charset=utf-8", "text/plain; charset=UTF-8", true},
+		{"text/plain; charset=utf-8", "text/plain", false},
+		{"text/plain; charset=utf-8", "text/plain; charset=gbk", false},
+		{"text/plain; charset=utf-8", "text/plain; format=flowed; charset=utf-8", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.pattern+" "+c.value, func(t *testing.T) {
+			pattern := headers.MustParseMediaType(c.pattern)
+			assert.Equal(t, c.want, pattern.Matches(headers.MustParseMediaType(c.value)))
+			assert.Equal(t, c.want, pattern.MatchesString(c.value))
+		})
+	}
+
+	assert.False(t, headers.MediaTypeJSON.MatchesString("invalid"))
+	assert.True(t, headers.MediaTypeJSON.MatchesString("application/merge-patch+json"))
+}
@@ -0,0 +1,122 @@
+package headers
+
+import "strings"
+
+/* Helpers for parsing header values, see RFC 9110 section 5.6 . */
+
+// splitList splits a comma-separated list, the commas in quoted-strings are ignored.
+// The elements are trimmed, empty elements are dropped.
+func splitList(s string) []string {
+	return splitQuoted(s, ',')
+}
+
+// splitParams splits a list separated by semicolons, like splitList().
+func splitParams(s string) []string {
+	return splitQuoted(s, ';')
+}
+
+func splitQuoted(s string, sep byte) []string {
+	var res []string
+	start := 0
+	inQuote := false
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if inQuote {
+				i++ // Skip the escaped character.
+			}
+		case '"':
+			inQuote = !inQuote
+		case sep:
+			if !inQuote {
+				res = appendNonEmpty(res, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return appendNonEmpty(res, s[start:])
+}
+
+func appendNonEmpty(list []string, s string) []string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return list
+	}
+	return append(list, s)
+}
+
+// splitPair splits 'name=value' into the lower-cased name and the unquoted value.
+// hasValue is false if there is no '='.
+func splitPair(s string) (name, value string, hasValue bool) {
+	i := strings.IndexByte(s, '=')
+	if i < 0 {
+		return strings.ToLower(strings.TrimSpace(s)), "", false
+	}
+
+	name = strings.ToLower(strings.TrimSpace(s[:i]))
+	value = unquote(strings.TrimSpace(s[i+1:]))
+	return name, value, true
+}
+
+// unquote removes the quotes of a quoted-string and unescapes the quoted-pairs.
+// If s is not a quoted-string, it is returned as is.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+
+	s = s[1 : len(s)-1]
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// quote returns s if it is a token; otherwise returns it as a quoted-string.
+func quote(s string) string {
+	if s != "" && isToken(s) {
+		return s
+	}
+	return quoteAlways(s)
+}
+
+// quoteAlways returns s as a quoted-string.
+func quoteAlways(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// isToken returns true if s is a token defined in RFC 9110.
+func isToken(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isTokenChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTokenChar(c byte) bool {
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
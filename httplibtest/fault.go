@@ -0,0 +1,377 @@
+package httplibtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cmstar/go-httplib"
+)
+
+// Fault is a failure injected instead of, or into, a normal response.
+type Fault string
+
+const (
+	// FaultConnectionReset resets the connection without a response, the client gets an error like 'connection reset by peer'.
+	FaultConnectionReset Fault = "connection reset"
+
+	// FaultEmptyResponse closes the connection without a response, the client gets an error like 'EOF'.
+	FaultEmptyResponse Fault = "empty response"
+
+	// FaultTruncatedBody sends the header and the first half of the body, then closes the connection,
+	// the client gets an error like 'unexpected EOF' when reading the body.
+	FaultTruncatedBody Fault = "truncated body"
+
+	// FaultLatency delays the request before it is sent.
+	FaultLatency Fault = "latency"
+
+	// FaultStatus responds with a status code without sending the request.
+	FaultStatus Fault = "status"
+
+	// FaultSlowBody sends the response body in small chunks with intervals.
+	FaultSlowBody Fault = "slow body"
+
+	// FaultTimeout hangs without a response, then fails with a timeout error.
+	FaultTimeout Fault = "timeout"
+)
+
+// FaultTransport injects faults into the requests. It is an http.RoundTripper wrapping another one,
+// and can also be used as a httplib.Middleware by Middleware(). It can be used concurrently.
+//
+// The faults are described by FaultRules, which are added by the methods named after the faults, such as Latency()
+// and Status(). For each request, the rules are checked in the order they are added. A rule fires if the request
+// matches it and the random number is less than its probability. Latency is added up with the other faults,
+// the other faults which respond without sending the request stop the check, the first fired one is used.
+//
+// The random numbers are generated by a RNG with the given seed, the faults are deterministic if the requests
+// are sent sequentially. OnRequests() makes a rule fire on the given requests only.
+//
+//	ft := httplibtest.NewFaultTransport(nil, 1)
+//	ft.Latency(100 * time.Millisecond).Probability(0.5)
+//	ft.Status(503).OnRequests(1, 2)
+//	client := httplib.NewClient(baseURL).WithHTTPClient(&http.Client{Transport: ft})
+type FaultTransport struct {
+	transport http.RoundTripper
+
+	mu     sync.Mutex
+	rng    *rand.Rand
+	rules  []*FaultRule
+	count  int
+	counts map[Fault]int
+}
+
+// NewFaultTransport creates a FaultTransport which sends the requests by the given transport, or
+// http.DefaultTransport if it is nil. seed is the seed of the RNG.
+func NewFaultTransport(transport http.RoundTripper, seed int64) *FaultTransport {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &FaultTransport{
+		transport: transport,
+		rng:       rand.New(rand.NewSource(seed)),
+		counts:    make(map[Fault]int),
+	}
+}
+
+// FaultRule describes when a fault is injected. It is created by the methods of FaultTransport.
+// The methods return the FaultRule itself for chaining, they should be called before the requests are sent.
+type FaultRule struct {
+	fault       Fault
+	probability float64
+	requests    map[int]bool
+	match       func(req *http.Request) bool
+
+	duration  time.Duration
+	status    int
+	chunkSize int
+}
+
+// Fault returns the fault of the rule.
+func (r *FaultRule) Fault() Fault {
+	return r.fault
+}
+
+// Probability sets the probability of the fault in [0, 1]. The default is 1.
+func (r *FaultRule) Probability(p float64) *FaultRule {
+	r.probability = p
+	return r
+}
+
+// OnRequests makes the rule fire on the n-th requests sent through the FaultTransport only, n starts from 1.
+// The probability still applies.
+func (r *FaultRule) OnRequests(n ...int) *FaultRule {
+	r.requests = make(map[int]bool, len(n))
+	for _, v := range n {
+		r.requests[v] = true
+	}
+	return r
+}
+
+// Matching makes the rule fire on the requests which the function returns true for only.
+// The function must not read the body of the request.
+func (r *FaultRule) Matching(fn func(req *http.Request) bool) *FaultRule {
+	r.match = fn
+	return r
+}
+
+// Latency adds a rule which delays the request for the duration before sending it.
+func (t *FaultTransport) Latency(d time.Duration) *FaultRule {
+	return t.addRule(&FaultRule{fault: FaultLatency, duration: d})
+}
+
+// ConnectionReset adds a rule which fails the request with an error wrapping syscall.ECONNRESET.
+func (t *FaultTransport) ConnectionReset() *FaultRule {
+	return t.addRule(&FaultRule{fault: FaultConnectionReset})
+}
+
+// EmptyResponse adds a rule which fails the request with io.EOF, as the server closes the connection without a response.
+func (t *FaultTransport) EmptyResponse() *FaultRule {
+	return t.addRule(&FaultRule{fault: FaultEmptyResponse})
+}
+
+// TruncatedBody adds a rule which cuts the response body in half, reading the body fails with io.ErrUnexpectedEOF
+// after the first half.
+func (t *FaultTransport) TruncatedBody() *FaultRule {
+	return t.addRule(&FaultRule{fault: FaultTruncatedBody})
+}
+
+// Status adds a rule which responds with the status code without sending the request.
+func (t *FaultTransport) Status(code int) *FaultRule {
+	return t.addRule(&FaultRule{fault: FaultStatus, status: code})
+}
+
+// SlowBody adds a rule which makes the response body readable by chunks of the given size, each chunk is
+// delayed for the interval.
+func (t *FaultTransport) SlowBody(chunkSize int, interval time.Duration) *FaultRule {
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+	return t.addRule(&FaultRule{fault: FaultSlowBody, chunkSize: chunkSize, duration: interval})
+}
+
+// Timeout adds a rule which hangs the request for the duration without sending it, then fails with an error
+// whose Timeout() returns true. If the request is canceled before that, fails with the error of the context.
+// Zero duration hangs until the request is canceled.
+func (t *FaultTransport) Timeout(d time.Duration) *FaultRule {
+	return t.addRule(&FaultRule{fault: FaultTimeout, duration: d})
+}
+
+func (t *FaultTransport) addRule(r *FaultRule) *FaultRule {
+	r.probability = 1
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rules = append(t.rules, r)
+	return r
+}
+
+// Count returns the number of times the fault was injected.
+func (t *FaultTransport) Count(f Fault) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.counts[f]
+}
+
+// RoundTrip implements http.RoundTripper .
+func (t *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.do(req, t.transport.RoundTrip)
+}
+
+// Middleware returns a httplib.Middleware which injects the faults around the next Doer instead of the transport.
+func (t *FaultTransport) Middleware() httplib.Middleware {
+	return func(next httplib.Doer) httplib.Doer {
+		return httplib.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return t.do(req, next.Do)
+		})
+	}
+}
+
+// fire returns the rules fired for the request.
+func (t *FaultTransport) fire(req *http.Request) []*FaultRule {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.count++
+	var fired []*FaultRule
+	for _, r := range t.rules {
+		if r.requests != nil && !r.requests[t.count] {
+			continue
+		}
+
+		if r.match != nil && !r.match(req) {
+			continue
+		}
+
+		if r.probability < 1 && t.rng.Float64() >= r.probability {
+			continue
+		}
+
+		fired = append(fired, r)
+		t.counts[r.fault]++
+
+		if r.fault != FaultLatency && r.fault != FaultTruncatedBody && r.fault != FaultSlowBody {
+			break
+		}
+	}
+	return fired
+}
+
+func (t *FaultTransport) do(req *http.Request, send func(req *http.Request) (*http.Response, error)) (*http.Response, error) {
+	ctx := req.Context()
+	var bodyFaults []*FaultRule
+
+	for _, r := range t.fire(req) {
+		switch r.fault {
+		case FaultLatency:
+			if err := sleep(ctx, r.duration); err != nil {
+				closeRequestBody(req)
+				return nil, err
+			}
+
+		case FaultConnectionReset:
+			closeRequestBody(req)
+			return nil, &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+
+		case FaultEmptyResponse:
+			closeRequestBody(req)
+			return nil, io.EOF
+
+		case FaultTimeout:
+			closeRequestBody(req)
+			if r.duration <= 0 {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			if err := sleep(ctx, r.duration); err != nil {
+				return nil, err
+			}
+			return nil, &timeoutError{r.duration}
+
+		case FaultStatus:
+			closeRequestBody(req)
+			return newStatusResponse(req, r.status), nil
+
+		default:
+			bodyFaults = append(bodyFaults, r)
+		}
+	}
+
+	res, err := send(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range bodyFaults {
+		switch r.fault {
+		case FaultTruncatedBody:
+			data, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			res.Body = &truncatedBody{Reader: bytes.NewReader(data[:len(data)/2])}
+
+		case FaultSlowBody:
+			res.Body = &slowBody{ReadCloser: res.Body, ctx: ctx, chunkSize: r.chunkSize, interval: r.duration}
+		}
+	}
+	return res, nil
+}
+
+func newStatusResponse(req *http.Request, code int) *http.Response {
+	body := fmt.Sprintf("httplibtest: injected status %d", code)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// closeRequestBody closes the body of the request which is not sent, an http.RoundTripper must always close the body.
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// sleep waits for the duration, returns the error of the context if it is done before that.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// timeoutError is returned by FaultTimeout, it implements net.Error .
+type timeoutError struct {
+	d time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("httplibtest: injected timeout after %s", e.d)
+}
+
+func (e *timeoutError) Timeout() bool {
+	return true
+}
+
+func (e *timeoutError) Temporary() bool {
+	return true
+}
+
+// truncatedBody returns io.ErrUnexpectedEOF at the end of the Reader.
+type truncatedBody struct {
+	*bytes.Reader
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (b *truncatedBody) Close() error {
+	return nil
+}
+
+// slowBody reads the body by chunks, each chunk is delayed for the interval.
+type slowBody struct {
+	io.ReadCloser
+	ctx       context.Context
+	chunkSize int
+	interval  time.Duration
+}
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	if err := sleep(b.ctx, b.interval); err != nil {
+		return 0, err
+	}
+
+	if len(p) > b.chunkSize {
+		p = p[:b.chunkSize]
+	}
+	return b.ReadCloser.Read(p)
+}
@@ -0,0 +1,221 @@
+package httplibtest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/cmstar/go-httplib/httplibtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// okTransport responds 200 with the body for any request.
+type okTransport string
+
+func (t okTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(string(t))),
+		Request:    req,
+	}, nil
+}
+
+func newFaultClient(ft *httplibtest.FaultTransport) *httplib.Client {
+	return httplib.NewClient("http://fault.test").WithHTTPClient(&http.Client{Transport: ft})
+}
+
+func TestFaultTransport_Errors(t *testing.T) {
+	ft := httplibtest.NewFaultTransport(okTransport("ok"), 1)
+	ft.ConnectionReset().OnRequests(1)
+	ft.EmptyResponse().OnRequests(2)
+	ft.Status(http.StatusServiceUnavailable).OnRequests(3)
+	ft.Timeout(10 * time.Millisecond).OnRequests(4)
+
+	c := newFaultClient(ft)
+
+	_, err := c.NewBuilder("GET", "/").ReadString()
+	assert.True(t, errors.Is(err, syscall.ECONNRESET), "%v", err)
+	assert.True(t, httplib.IsRetryableError(err))
+
+	_, err = c.NewBuilder("GET", "/").ReadString()
+	assert.True(t, errors.Is(err, io.EOF), "%v", err)
+
+	res, err := c.NewBuilder("GET", "/").ExpectStatus(http.StatusServiceUnavailable).Send()
+	require.NoError(t, err)
+	assert.Equal(t, "httplibtest: injected status 503", res.String())
+
+	_, err = c.NewBuilder("GET", "/").ReadString()
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr), "%v", err)
+	assert.True(t, netErr.Timeout())
+	assert.True(t, httplib.IsRetryableError(err))
+
+	assert.Equal(t, "ok", c.NewBuilder("GET", "/").MustReadString())
+
+	for _, f := range []httplibtest.Fault{
+		httplibtest.FaultConnectionReset,
+		httplibtest.FaultEmptyResponse,
+		httplibtest.FaultStatus,
+		httplibtest.FaultTimeout,
+	} {
+		assert.Equal(t, 1, ft.Count(f), f)
+	}
+}
+
+func TestFaultTransport_Timeout(t *testing.T) {
+	ft := httplibtest.NewFaultTransport(okTransport("ok"), 1)
+	ft.Timeout(0)
+
+	start := time.Now()
+	_, err := newFaultClient(ft).NewBuilder("GET", "/").WithTimeout(20 * time.Millisecond).ReadString()
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
+
+func TestFaultTransport_Latency(t *testing.T) {
+	ft := httplibtest.NewFaultTransport(okTransport("ok"), 1)
+	ft.Latency(20 * time.Millisecond)
+	ft.Latency(20 * time.Millisecond)
+
+	c := newFaultClient(ft)
+
+	start := time.Now()
+	assert.Equal(t, "ok", c.NewBuilder("GET", "/").MustReadString())
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	assert.Equal(t, 2, ft.Count(httplibtest.FaultLatency))
+
+	// The latency ends when the request is canceled.
+	_, err := c.NewBuilder("GET", "/").WithTimeout(10 * time.Millisecond).ReadString()
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+}
+
+func TestFaultTransport_Body(t *testing.T) {
+	ft := httplibtest.NewFaultTransport(okTransport("0123456789"), 1)
+	ft.TruncatedBody().OnRequests(1)
+	ft.SlowBody(4, 10*time.Millisecond).OnRequests(2)
+
+	c := newFaultClient(ft)
+
+	res, err := c.NewBuilder("GET", "/").Do()
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), "%v", err)
+	assert.Equal(t, "01234", string(body))
+
+	start := time.Now()
+	res, err = c.NewBuilder("GET", "/").Do()
+	require.NoError(t, err)
+	buf := make([]byte, 100)
+	n, err := res.Body.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "0123", string(buf[:n]))
+	body, err = io.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "456789", string(body))
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+}
+
+func TestFaultTransport_Probability(t *testing.T) {
+	run := func(seed int64) []int {
+		ft := httplibtest.NewFaultTransport(okTransport("ok"), seed)
+		ft.Status(http.StatusInternalServerError).Probability(0.5)
+
+		c := newFaultClient(ft)
+		codes := make([]int, 50)
+		for i := range codes {
+			res, err := c.NewBuilder("GET", "/").ExpectStatus(200, 500).Send()
+			require.NoError(t, err)
+			codes[i] = res.StatusCode()
+		}
+
+		assert.Equal(t, ft.Count(httplibtest.FaultStatus), countOf(codes, 500))
+		return codes
+	}
+
+	codes := run(42)
+	assert.Equal(t, codes, run(42), "the same seed produces the same faults")
+
+	n := countOf(codes, 500)
+	assert.Greater(t, n, 10)
+	assert.Less(t, n, 40)
+
+	// Probability 0 never fires.
+	ft := httplibtest.NewFaultTransport(okTransport("ok"), 1)
+	ft.ConnectionReset().Probability(0)
+	assert.Equal(t, "ok", newFaultClient(ft).NewBuilder("GET", "/").MustReadString())
+}
+
+func TestFaultTransport_Matching(t *testing.T) {
+	ft := httplibtest.NewFaultTransport(okTransport("ok"), 1)
+	ft.Status(http.StatusNotFound).Matching(func(req *http.Request) bool {
+		return req.URL.Path == "/missing"
+	})
+
+	c := newFaultClient(ft)
+	assert.Equal(t, "ok", c.NewBuilder("GET", "/").MustReadString())
+
+	_, err := c.NewBuilder("GET", "/missing").ReadString()
+	assert.True(t, httplib.IsStatus(err, http.StatusNotFound))
+}
+
+func TestFaultTransport_Retry(t *testing.T) {
+	s := httplibtest.NewServer()
+	defer s.Close()
+	stub := s.On("POST", "/").WithBody("data").Reply(200, "ok").Once()
+
+	ft := httplibtest.NewFaultTransport(nil, 1)
+	ft.ConnectionReset().OnRequests(1)
+	ft.Status(http.StatusServiceUnavailable).OnRequests(2)
+
+	p := httplib.NewRetryPolicy(3)
+	p.InitialBackoff = time.Millisecond
+	p.RetryNonIdempotent = true
+
+	content, err := httplib.NewClient(s.URL).
+		WithHTTPClient(&http.Client{Transport: ft}).
+		NewBuilder("POST", "/").
+		SetStringBody("data").
+		WithRetry(p).
+		ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "ok", content)
+	assert.Equal(t, 1, stub.Calls())
+	s.AssertExpectations(t)
+}
+
+func TestFaultTransport_Middleware(t *testing.T) {
+	s := httplibtest.NewServer()
+	defer s.Close()
+	s.On("GET", "/").Reply(200, "ok")
+
+	ft := httplibtest.NewFaultTransport(nil, 1)
+	ft.Status(http.StatusTeapot).OnRequests(1)
+
+	c := httplib.NewClient(s.URL).WithMiddleware(ft.Middleware())
+
+	_, err := c.NewBuilder("GET", "/").ReadString()
+	assert.True(t, httplib.IsStatus(err, http.StatusTeapot))
+	assert.Equal(t, "ok", c.NewBuilder("GET", "/").MustReadString())
+	assert.Len(t, s.Requests(), 1)
+}
+
+func countOf(values []int, v int) int {
+	n := 0
+	for _, x := range values {
+		if x == v {
+			n++
+		}
+	}
+	return n
+}
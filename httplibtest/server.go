@@ -0,0 +1,193 @@
+// Package httplibtest provides utilities for testing the code which sends HTTP requests:
+// a programmable mock server and a fault-injection transport.
+//
+// A mock server responds to the requests with stubs:
+//
+//	s := httplibtest.NewServer()
+//	defer s.Close()
+//
+//	s.On("GET", "/users/*").WithQuery("fields", "name").ReplyJSON(200, map[string]any{"name": "Ann"}).Once()
+//	s.On("POST", "/users").WithJSONBody(`{"name":"Bob"}`).Reply(201, "").Reply(409, "conflict")
+//
+//	// Send requests to s.URL ...
+//
+//	s.AssertExpectations(t)
+//
+// A FaultTransport injects faults into the requests sent by a client:
+//
+//	ft := httplibtest.NewFaultTransport(nil, 1)
+//	ft.ConnectionReset().OnRequests(1)
+//	ft.Latency(time.Second).Probability(0.1)
+//	client := httplib.NewClient(s.URL).WithHTTPClient(&http.Client{Transport: ft})
+package httplibtest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+)
+
+// TestingT is the subset of testing.T used by this package.
+type TestingT interface {
+	Errorf(format string, args ...any)
+}
+
+// Request is a request received by the Server, its body has been read.
+type Request struct {
+	Method string
+	URL    *url.URL
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   string
+}
+
+func newRequest(r *http.Request) (*Request, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Request{
+		Method: r.Method,
+		URL:    r.URL,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header,
+		Body:   string(body),
+	}, nil
+}
+
+// String returns the method and the URI of the request, e.g. 'GET /path?a=1'.
+func (r *Request) String() string {
+	return r.Method + " " + r.URL.RequestURI()
+}
+
+// Server is a mock HTTP server which responds with the registered stubs. It can be used concurrently.
+//
+// The stubs are checked in the order they are registered, the first stub which matches the request and
+// is not exhausted is used. If no stub is found, the server responds 404 and the request is reported as
+// unexpected by AssertExpectations().
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	stubs      []*Stub
+	requests   []*Request
+	unexpected []*Request
+}
+
+// NewServer starts a Server, it should be closed by Close() at the end of the test.
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// NewTLSServer starts a Server with TLS. Use Client() of the Server to get an http.Client which trusts it.
+func NewTLSServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// On registers a stub for the requests with the given method and path, and returns it to setup the matchers
+// and the responses. The method is case-sensitive, an empty method matches any method. The path is matched
+// by path.Match(), so it can contain patterns such as '/users/*'.
+func (s *Server) On(method, path string) *Stub {
+	stub := newStub(method, path)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stubs = append(s.stubs, stub)
+	return stub
+}
+
+// Requests returns all the requests received, in the order they are received.
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request(nil), s.requests...)
+}
+
+// LastRequest returns the last request received, or nil if no request is received.
+func (s *Server) LastRequest() *Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.requests) == 0 {
+		return nil
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+// Reset removes all the stubs and the received requests.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stubs = nil
+	s.requests = nil
+	s.unexpected = nil
+}
+
+// AssertExpectations reports an error to t for each stub whose expectation on the number of calls is not met,
+// and for each request which no stub matches. Returns true if there is no error.
+func (s *Server) AssertExpectations(t TestingT) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ok := true
+	for _, stub := range s.stubs {
+		if msg := stub.checkCalls(); msg != "" {
+			t.Errorf("httplibtest: %s: %s", stub, msg)
+			ok = false
+		}
+	}
+
+	for _, req := range s.unexpected {
+		t.Errorf("httplibtest: unexpected request: %s", req)
+		ok = false
+	}
+	return ok
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := newRequest(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("httplibtest: read body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	res := s.match(req)
+	if res == nil {
+		http.Error(w, "httplibtest: no stub matches "+req.String(), http.StatusNotFound)
+		return
+	}
+
+	res.write(w, r, req)
+}
+
+// match finds the stub for the request, records the call and returns the response to write.
+// Returns nil if no stub matches.
+func (s *Server) match(req *Request) *response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, req)
+	for _, stub := range s.stubs {
+		if stub.matches(req) {
+			return stub.call(req)
+		}
+	}
+
+	s.unexpected = append(s.unexpected, req)
+	return nil
+}
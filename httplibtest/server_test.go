@@ -0,0 +1,100 @@
+package httplibtest_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/cmstar/go-httplib/httplibtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingT records the errors reported to it.
+type recordingT struct {
+	errors []string
+}
+
+func (t *recordingT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestServer(t *testing.T) {
+	s := httplibtest.NewServer()
+	defer s.Close()
+
+	s.On("GET", "/users/*").Reply(200, "user")
+	s.On("", "/any").Reply(200, "any")
+
+	c := httplib.NewClient(s.URL)
+	assert.Equal(t, "user", c.NewBuilder("GET", "/users/1").MustReadString())
+	assert.Equal(t, "any", c.NewBuilder("DELETE", "/any").MustReadString())
+
+	// Unmatched.
+	res, err := c.NewBuilder("GET", "/users/1/posts").WithQuery("a", 1).ExpectStatus(http.StatusNotFound).Send()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode())
+	assert.Equal(t, "httplibtest: no stub matches GET /users/1/posts?a=1\n", res.String())
+
+	requests := s.Requests()
+	require.Len(t, requests, 3)
+	assert.Equal(t, "DELETE", requests[1].Method)
+	assert.Equal(t, "/users/1/posts", s.LastRequest().Path)
+	assert.Equal(t, "1", s.LastRequest().Query.Get("a"))
+
+	rt := &recordingT{}
+	assert.False(t, s.AssertExpectations(rt))
+	assert.Equal(t, []string{"httplibtest: unexpected request: GET /users/1/posts?a=1"}, rt.errors)
+
+	s.Reset()
+	assert.Nil(t, s.LastRequest())
+	assert.True(t, s.AssertExpectations(t))
+}
+
+func TestServer_AssertExpectations(t *testing.T) {
+	s := httplibtest.NewServer()
+	defer s.Close()
+
+	s.On("GET", "/default")
+	s.On("GET", "/once").Once()
+	s.On("GET", "/twice").WithQuery("a", "1").Times(2)
+	s.On("GET", "/at-least").AtLeast(2)
+	s.On("GET", "/maybe").Maybe()
+
+	c := httplib.NewClient(s.URL)
+	c.NewBuilder("GET", "/twice").WithQuery("a", 1).MustReadString()
+	c.NewBuilder("GET", "/at-least").MustReadString()
+
+	rt := &recordingT{}
+	assert.False(t, s.AssertExpectations(rt))
+	assert.Equal(t, []string{
+		"httplibtest: GET /default: expected at least 1 call(s), got 0",
+		"httplibtest: GET /once: expected 1 call(s), got 0",
+		"httplibtest: GET /twice (query a=1): expected 2 call(s), got 1",
+		"httplibtest: GET /at-least: expected at least 2 call(s), got 1",
+	}, rt.errors)
+
+	c.NewBuilder("GET", "/default").MustReadString()
+	c.NewBuilder("GET", "/once").MustReadString()
+	c.NewBuilder("GET", "/twice").WithQuery("a", 1).MustReadString()
+	c.NewBuilder("GET", "/at-least").MustReadString()
+	assert.True(t, s.AssertExpectations(t))
+
+	// The stub is exhausted.
+	_, err := c.NewBuilder("GET", "/once").ReadString()
+	assert.True(t, httplib.IsStatus(err, http.StatusNotFound))
+	assert.False(t, s.AssertExpectations(&recordingT{}))
+}
+
+func TestNewTLSServer(t *testing.T) {
+	s := httplibtest.NewTLSServer()
+	defer s.Close()
+
+	s.On("GET", "/").Reply(200, "tls")
+
+	content, err := httplib.NewClient(s.URL).WithHTTPClient(s.Client()).NewBuilder("GET", "/").ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "tls", content)
+	s.AssertExpectations(t)
+}
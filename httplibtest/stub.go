@@ -0,0 +1,367 @@
+package httplibtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Stub describes the requests to match, and the responses to them. It is created by Server.On().
+// The methods return the Stub itself for chaining, they should be called before the requests are sent.
+//
+// A stub can have several responses, which are used in the order they are added, one for each call;
+// the last one is used for the rest calls. If no response is added, it responds 200 with an empty body.
+//
+// By default, a stub is expected to be called at least once, see Times(), AtLeast() and Maybe().
+type Stub struct {
+	method   string
+	path     string
+	matchers []func(r *Request) bool
+	desc     []string
+
+	responses []*response
+	min, max  int // max < 0 means no limit.
+
+	mu       sync.Mutex
+	requests []*Request
+}
+
+func newStub(method, path string) *Stub {
+	return &Stub{
+		method: method,
+		path:   path,
+		min:    1,
+		max:    -1,
+	}
+}
+
+// String returns the description of the Stub, e.g. 'GET /path?a=1'.
+func (s *Stub) String() string {
+	method := s.method
+	if method == "" {
+		method = "*"
+	}
+
+	str := method + " " + s.path
+	if len(s.desc) > 0 {
+		str += " (" + strings.Join(s.desc, ", ") + ")"
+	}
+	return str
+}
+
+// WithQuery requires the request has the query parameter with the given value, it can have other values of the parameter.
+func (s *Stub) WithQuery(name, value string) *Stub {
+	return s.matching(fmt.Sprintf("query %s=%s", name, value), func(r *Request) bool {
+		return contains(r.Query[name], value)
+	})
+}
+
+// WithHeader requires the request has the header with the given value, the name is case-insensitive.
+func (s *Stub) WithHeader(name, value string) *Stub {
+	return s.matching(fmt.Sprintf("header %s: %s", name, value), func(r *Request) bool {
+		return contains(r.Header.Values(name), value)
+	})
+}
+
+// WithBody requires the body of the request equals to the given body.
+func (s *Stub) WithBody(body string) *Stub {
+	return s.matching("body "+strconv.Quote(body), func(r *Request) bool {
+		return r.Body == body
+	})
+}
+
+// WithJSONBody requires the body of the request is JSON equivalent to the given one, the formatting and
+// the order of the object fields are ignored. Panics if the given body is not valid JSON.
+func (s *Stub) WithJSONBody(body string) *Stub {
+	var want any
+	if err := json.Unmarshal([]byte(body), &want); err != nil {
+		panic(fmt.Sprintf("httplibtest: invalid JSON body: %v", err))
+	}
+
+	return s.matching("JSON body "+body, func(r *Request) bool {
+		var got any
+		if err := json.Unmarshal([]byte(r.Body), &got); err != nil {
+			return false
+		}
+		return reflect.DeepEqual(want, got)
+	})
+}
+
+// Matching requires the function returns true for the request.
+func (s *Stub) Matching(fn func(r *Request) bool) *Stub {
+	return s.matching("custom matcher", fn)
+}
+
+func (s *Stub) matching(desc string, fn func(r *Request) bool) *Stub {
+	s.matchers = append(s.matchers, fn)
+	s.desc = append(s.desc, desc)
+	return s
+}
+
+// Reply adds a response with the status code and the body.
+func (s *Stub) Reply(status int, body string) *Stub {
+	return s.addResponse(&response{status: status, body: body})
+}
+
+// ReplyJSON adds a response with the status code and the value encoded in JSON, the Content-Type is 'application/json'.
+// Panics if the value can not be encoded.
+func (s *Stub) ReplyJSON(status int, v any) *Stub {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("httplibtest: encode JSON: %v", err))
+	}
+
+	return s.Reply(status, string(data)).WithResponseHeader("Content-Type", "application/json")
+}
+
+// ReplyTemplate adds a response with the status code and the body generated by the text/template with the *Request,
+// e.g. 'Hello {{.Query.Get "name"}}'. The template can use the function 'json' to encode a value in JSON.
+// If the template fails, the server responds 500 with the error. Panics if the template is invalid.
+func (s *Stub) ReplyTemplate(status int, text string) *Stub {
+	tpl := template.Must(template.New("").Funcs(templateFuncs).Parse(text))
+	return s.addResponse(&response{status: status, template: tpl})
+}
+
+// ReplyFunc adds a response written by the handler, the body of the request has been read and
+// is available in the Request given to the handler.
+func (s *Stub) ReplyFunc(handler func(w http.ResponseWriter, r *Request)) *Stub {
+	return s.addResponse(&response{handler: handler})
+}
+
+// WithResponseHeader sets a header of the last added response.
+func (s *Stub) WithResponseHeader(name, value string) *Stub {
+	s.lastResponse().header.Add(name, value)
+	return s
+}
+
+// Delay delays the last added response. The delay ends early if the request is canceled.
+func (s *Stub) Delay(d time.Duration) *Stub {
+	s.lastResponse().delay = d
+	return s
+}
+
+// Fault replaces the last added response with the fault, the status code and the body are used by FaultTruncatedBody.
+// Only FaultConnectionReset, FaultEmptyResponse and FaultTruncatedBody are supported, the others are ignored;
+// use Delay() for latency.
+func (s *Stub) Fault(f Fault) *Stub {
+	s.lastResponse().fault = f
+	return s
+}
+
+func (s *Stub) addResponse(res *response) *Stub {
+	if res.header == nil {
+		res.header = make(http.Header)
+	}
+	s.responses = append(s.responses, res)
+	return s
+}
+
+// lastResponse returns the last added response, adds a 200 response if there is none.
+func (s *Stub) lastResponse() *response {
+	if len(s.responses) == 0 {
+		s.Reply(http.StatusOK, "")
+	}
+	return s.responses[len(s.responses)-1]
+}
+
+// Times expects the stub to be called exactly n times. After n calls, the stub no longer matches requests.
+func (s *Stub) Times(n int) *Stub {
+	s.min, s.max = n, n
+	return s
+}
+
+// Once is the same as Times(1).
+func (s *Stub) Once() *Stub {
+	return s.Times(1)
+}
+
+// AtLeast expects the stub to be called at least n times.
+func (s *Stub) AtLeast(n int) *Stub {
+	s.min, s.max = n, -1
+	return s
+}
+
+// Maybe allows the stub not to be called. The limit set by Times() is kept.
+func (s *Stub) Maybe() *Stub {
+	s.min = 0
+	return s
+}
+
+// Calls returns the number of the requests the stub responded to.
+func (s *Stub) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+// Requests returns the requests the stub responded to.
+func (s *Stub) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request(nil), s.requests...)
+}
+
+func (s *Stub) matches(r *Request) bool {
+	if s.method != "" && s.method != r.Method {
+		return false
+	}
+
+	if ok, _ := path.Match(s.path, r.Path); !ok {
+		return false
+	}
+
+	if s.max >= 0 && s.Calls() >= s.max {
+		return false
+	}
+
+	for _, m := range s.matchers {
+		if !m(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// call records the request and returns the response for it.
+func (s *Stub) call(r *Request) *response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r)
+	if len(s.responses) == 0 {
+		return &response{status: http.StatusOK}
+	}
+
+	i := len(s.requests) - 1
+	if i >= len(s.responses) {
+		i = len(s.responses) - 1
+	}
+	return s.responses[i]
+}
+
+// checkCalls returns a message if the expectation on the number of calls is not met, or an empty string.
+func (s *Stub) checkCalls() string {
+	n := s.Calls()
+	switch {
+	case s.min == s.max && n != s.min:
+		return fmt.Sprintf("expected %d call(s), got %d", s.min, n)
+	case n < s.min:
+		return fmt.Sprintf("expected at least %d call(s), got %d", s.min, n)
+	}
+	return ""
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+type response struct {
+	status   int
+	header   http.Header
+	body     string
+	template *template.Template
+	handler  func(w http.ResponseWriter, r *Request)
+	delay    time.Duration
+	fault    Fault
+}
+
+func (res *response) write(w http.ResponseWriter, r *http.Request, req *Request) {
+	if res.delay > 0 {
+		timer := time.NewTimer(res.delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch res.fault {
+	case FaultConnectionReset:
+		closeConnection(w, true)
+		return
+
+	case FaultEmptyResponse:
+		closeConnection(w, false)
+		return
+	}
+
+	if res.handler != nil {
+		res.handler(w, req)
+		return
+	}
+
+	body := res.body
+	if res.template != nil {
+		var buf bytes.Buffer
+		if err := res.template.Execute(&buf, req); err != nil {
+			http.Error(w, fmt.Sprintf("httplibtest: execute template: %v", err), http.StatusInternalServerError)
+			return
+		}
+		body = buf.String()
+	}
+
+	for k, v := range res.header {
+		w.Header()[k] = v
+	}
+
+	status := res.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if res.fault == FaultTruncatedBody {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)+1))
+		w.WriteHeader(status)
+		w.Write([]byte(body[:len(body)/2]))
+		w.(http.Flusher).Flush()
+
+		// The server closes the connection.
+		panic(http.ErrAbortHandler)
+	}
+
+	w.WriteHeader(status)
+	w.Write([]byte(body))
+}
+
+// closeConnection closes the connection of the request without writing a response.
+// If reset is true, the connection is reset by setting SO_LINGER to 0.
+func closeConnection(w http.ResponseWriter, reset bool) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+
+	if reset {
+		c := conn
+		if tc, ok := c.(interface{ NetConn() net.Conn }); ok {
+			c = tc.NetConn()
+		}
+		if tcp, ok := c.(*net.TCPConn); ok {
+			tcp.SetLinger(0)
+		}
+	}
+	conn.Close()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
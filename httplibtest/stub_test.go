@@ -0,0 +1,164 @@
+package httplibtest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/cmstar/go-httplib/httplibtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStub_Matchers(t *testing.T) {
+	s := httplibtest.NewServer()
+	defer s.Close()
+
+	query := s.On("GET", "/").WithQuery("a", "2").Reply(200, "query")
+	header := s.On("GET", "/").WithHeader("x-token", "t").Reply(200, "header")
+	body := s.On("POST", "/").WithBody("a=1").Reply(200, "body")
+	jsonBody := s.On("POST", "/").WithJSONBody(`{"a": 1, "b": [true, null]}`).Reply(200, "json")
+	custom := s.On("PUT", "/").Matching(func(r *httplibtest.Request) bool {
+		return strings.HasPrefix(r.Body, "custom")
+	}).Reply(200, "custom")
+
+	c := httplib.NewClient(s.URL)
+	assert.Equal(t, "query", c.NewBuilder("GET", "/").WithQuery("a", 1).WithQuery("a", 2).MustReadString())
+	assert.Equal(t, "header", c.NewBuilder("GET", "/").WithHeader("X-Token", "t").MustReadString())
+	assert.Equal(t, "body", c.NewBuilder("POST", "/").WithForm("a", 1).MustReadString())
+	assert.Equal(t, "json", c.NewBuilder("POST", "/").SetStringBody(`{"b":[true,null],"a":1.0}`).MustReadString())
+	assert.Equal(t, "custom", c.NewBuilder("PUT", "/").SetStringBody("custom body").MustReadString())
+
+	for _, b := range []*httplib.RequestBuilder{
+		c.NewBuilder("GET", "/").WithQuery("a", 1),
+		c.NewBuilder("GET", "/").WithHeader("X-Token", "x"),
+		c.NewBuilder("POST", "/").WithForm("a", 2),
+		c.NewBuilder("POST", "/").SetStringBody(`{"a":1}`),
+		c.NewBuilder("PUT", "/").SetStringBody("other"),
+	} {
+		_, err := b.ReadString()
+		assert.True(t, httplib.IsStatus(err, http.StatusNotFound))
+	}
+
+	for _, stub := range []*httplibtest.Stub{query, header, body, jsonBody, custom} {
+		assert.Equal(t, 1, stub.Calls())
+	}
+	assert.Equal(t, "custom body", custom.Requests()[0].Body)
+	assert.Equal(t, `POST / (JSON body {"a": 1, "b": [true, null]})`, jsonBody.String())
+
+	assert.Panics(t, func() { s.On("POST", "/").WithJSONBody("{") })
+}
+
+func TestStub_Responses(t *testing.T) {
+	s := httplibtest.NewServer()
+	defer s.Close()
+
+	s.On("GET", "/seq").
+		Reply(503, "busy").WithResponseHeader("Retry-After", "0").
+		Reply(200, "ok")
+
+	s.On("GET", "/json").ReplyJSON(201, map[string]any{"a": 1})
+	s.On("POST", "/template").ReplyTemplate(200, `{{.Method}} {{.Path}} {{.Query.Get "name"}} {{.Header.Get "X-A"}} {{json .Body}}`)
+	s.On("GET", "/bad-template").ReplyTemplate(200, `{{.NotExist}}`)
+	s.On("GET", "/func").ReplyFunc(func(w http.ResponseWriter, r *httplibtest.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, "func "+r.Method)
+	})
+	s.On("GET", "/default")
+
+	c := httplib.NewClient(s.URL)
+
+	t.Run("sequential", func(t *testing.T) {
+		res := c.NewBuilder("GET", "/seq").ExpectStatus(503).MustSend()
+		assert.Equal(t, "busy", res.String())
+		assert.Equal(t, "0", res.Header().Get("Retry-After"))
+
+		for i := 0; i < 2; i++ {
+			res = c.NewBuilder("GET", "/seq").MustSend()
+			assert.Equal(t, "ok", res.String())
+			assert.Empty(t, res.Header().Get("Retry-After"))
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		res := c.NewBuilder("GET", "/json").ExpectStatus(201).MustSend()
+		assert.Equal(t, `{"a":1}`, res.String())
+		assert.Equal(t, "application/json", res.Header().Get("Content-Type"))
+	})
+
+	t.Run("template", func(t *testing.T) {
+		content := c.NewBuilder("POST", "/template").
+			WithQuery("name", "n").
+			WithHeader("X-A", "h").
+			SetStringBody(`b"`).
+			MustReadString()
+		assert.Equal(t, `POST /template n h "b\""`, content)
+
+		res := c.NewBuilder("GET", "/bad-template").ExpectStatus(500).MustSend()
+		assert.Contains(t, res.String(), "httplibtest: execute template:")
+
+		assert.Panics(t, func() { s.On("GET", "/").ReplyTemplate(200, "{{") })
+	})
+
+	t.Run("func", func(t *testing.T) {
+		res := c.NewBuilder("GET", "/func").ExpectStatus(202).MustSend()
+		assert.Equal(t, "func GET", res.String())
+	})
+
+	t.Run("default", func(t *testing.T) {
+		res := c.NewBuilder("GET", "/default").MustSend()
+		assert.Equal(t, 200, res.StatusCode())
+		assert.Empty(t, res.String())
+	})
+}
+
+func TestStub_Delay(t *testing.T) {
+	s := httplibtest.NewServer()
+	defer s.Close()
+
+	s.On("GET", "/").Delay(50 * time.Millisecond)
+	c := httplib.NewClient(s.URL)
+
+	start := time.Now()
+	c.NewBuilder("GET", "/").MustReadString()
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// The delay ends when the request is canceled.
+	s.On("GET", "/long").Reply(200, "").Delay(time.Minute)
+	_, err := c.NewBuilder("GET", "/long").WithTimeout(20 * time.Millisecond).ReadString()
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestStub_Fault(t *testing.T) {
+	s := httplibtest.NewServer()
+	defer s.Close()
+
+	s.On("GET", "/reset").Fault(httplibtest.FaultConnectionReset)
+	s.On("GET", "/empty").Fault(httplibtest.FaultEmptyResponse)
+	s.On("GET", "/truncated").Reply(200, "0123456789").Fault(httplibtest.FaultTruncatedBody)
+	s.On("GET", "/recover").Fault(httplibtest.FaultEmptyResponse).Reply(200, "ok")
+
+	c := httplib.NewClient(s.URL)
+
+	_, err := c.NewBuilder("GET", "/reset").ReadString()
+	assert.Error(t, err)
+
+	_, err = c.NewBuilder("GET", "/empty").ReadString()
+	assert.Error(t, err)
+
+	res, err := c.NewBuilder("GET", "/truncated").Do()
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), "%v", err)
+	assert.Equal(t, "01234", string(body))
+
+	_, err = c.NewBuilder("GET", "/recover").ReadString()
+	assert.Error(t, err)
+	assert.Equal(t, "ok", c.NewBuilder("GET", "/recover").MustReadString())
+}
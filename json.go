@@ -0,0 +1,68 @@
+package httplib
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/cmstar/go-httplib/headers"
+)
+
+// JSONOption is used to setup the json.Decoder used by ReadJSON().
+type JSONOption func(d *json.Decoder)
+
+// JSONDisallowUnknownFields makes ReadJSON() return an error when the JSON object contains keys
+// which do not match any non-ignored, exported fields in the destination.
+func JSONDisallowUnknownFields(d *json.Decoder) {
+	d.DisallowUnknownFields()
+}
+
+// JSONUseNumber makes ReadJSON() unmarshal a number into an interface{} as a json.Number instead of as a float64.
+func JSONUseNumber(d *json.Decoder) {
+	d.UseNumber()
+}
+
+// SetJSONBody encodes the given value as JSON and set it as the request body,
+// and sets the header Content-Type to 'application/json'. If another body was set, it will be replaced.
+//
+// If the value can not be encoded, the error is returned by Build() and the methods which send the request.
+func (x *RequestBuilder) SetJSONBody(v any) *RequestBuilder {
+	data, err := json.Marshal(v)
+	if err != nil {
+		x.setErr(err)
+		return x
+	}
+
+	x.setContentType(headers.MediaTypeJSON)
+	x.body = data
+	return x
+}
+
+// ReadJSON executes the HTTP request with ReadBinary(), and decodes the response body as JSON into a value of type T.
+func ReadJSON[T any](b *RequestBuilder, options ...JSONOption) (T, error) {
+	var res T
+
+	data, err := b.ReadBinary()
+	if err != nil {
+		return res, err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	for _, option := range options {
+		option(d)
+	}
+
+	if err := d.Decode(&res); err != nil {
+		var zero T
+		return zero, err
+	}
+	return res, nil
+}
+
+// MustReadJSON is the panic version of ReadJSON().
+func MustReadJSON[T any](b *RequestBuilder, options ...JSONOption) T {
+	res, err := ReadJSON[T](b, options...)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
@@ -0,0 +1,139 @@
+package httplib_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/stretchr/testify/assert"
+)
+
+type jsonTestData struct {
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+}
+
+func TestRequestBuilder_SetJSONBody(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		s := NewTestServer(http.StatusOK, DefaultBody)
+		defer s.Close()
+
+		b := httplib.NewBuilder("POST", s.URL).
+			WithForm("a", 1).
+			SetJSONBody(jsonTestData{"n", 1}) // Override others.
+
+		content, err := b.ReadString()
+		assert := assert.New(t)
+		assert.NoError(err)
+		assert.Equal(string(DefaultBody), content)
+		assert.Equal("application/json", s.Request.Header.Get("Content-Type"))
+		assert.Equal(`{"name":"n","value":1}`, string(s.Body))
+
+		// The body is reusable.
+		_, err = b.ReadString()
+		assert.NoError(err)
+		assert.Equal(`{"name":"n","value":1}`, string(s.Body))
+	})
+
+	t.Run("content-type", func(t *testing.T) {
+		// The parameters of the same media type are kept.
+		req, err := httplib.NewBuilder("POST", "http://temp.org").
+			WithHeader("Content-Type", "Application/JSON; Charset=utf-8").
+			SetJSONBody(1).
+			Build()
+		assert.NoError(t, err)
+		assert.Equal(t, "application/json; charset=utf-8", req.Header.Get("Content-Type"))
+
+		// Another media type is replaced.
+		req, err = httplib.NewBuilder("POST", "http://temp.org").
+			WithHeader("Content-Type", "text/plain; charset=utf-8").
+			SetJSONBody(1).
+			Build()
+		assert.NoError(t, err)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	})
+
+	t.Run("err", func(t *testing.T) {
+		b := httplib.NewBuilder("POST", "http://temp.org").SetJSONBody(math.Inf(1))
+
+		req, err := b.Build()
+		assert.Nil(t, req)
+		var e *json.UnsupportedValueError
+		assert.ErrorAs(t, err, &e)
+
+		_, err = b.ReadBinary()
+		assert.ErrorAs(t, err, &e)
+	})
+}
+
+func TestReadJSON(t *testing.T) {
+	t.Run("struct", func(t *testing.T) {
+		s := NewTestServer(http.StatusOK, []byte(`{"name":"n","value":2,"other":3}`))
+		defer s.Close()
+
+		v, err := httplib.ReadJSON[jsonTestData](httplib.NewBuilder("GET", s.URL))
+		assert.NoError(t, err)
+		assert.Equal(t, jsonTestData{"n", 2}, v)
+
+		v, err = httplib.ReadJSON[jsonTestData](httplib.NewBuilder("GET", s.URL), httplib.JSONDisallowUnknownFields)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown field "other"`)
+		assert.Equal(t, jsonTestData{}, v)
+	})
+
+	t.Run("use-number", func(t *testing.T) {
+		s := NewTestServer(http.StatusOK, []byte(`{"v":12345678901234567890}`))
+		defer s.Close()
+
+		m, err := httplib.ReadJSON[map[string]any](httplib.NewBuilder("GET", s.URL))
+		assert.NoError(t, err)
+		assert.IsType(t, float64(0), m["v"])
+
+		m, err = httplib.ReadJSON[map[string]any](httplib.NewBuilder("GET", s.URL), httplib.JSONUseNumber)
+		assert.NoError(t, err)
+		assert.Equal(t, json.Number("12345678901234567890"), m["v"])
+	})
+
+	t.Run("bad-status", func(t *testing.T) {
+		s := NewTestServer(http.StatusBadRequest, []byte(`{}`))
+		defer s.Close()
+
+		_, err := httplib.ReadJSON[jsonTestData](httplib.NewBuilder("GET", s.URL))
+		assert.True(t, httplib.IsStatus(err, http.StatusBadRequest))
+	})
+
+	t.Run("bad-json", func(t *testing.T) {
+		s := NewTestServer(http.StatusOK, []byte(`{`))
+		defer s.Close()
+
+		_, err := httplib.ReadJSON[jsonTestData](httplib.NewBuilder("GET", s.URL))
+		assert.Error(t, err)
+	})
+}
+
+func TestMustReadJSON(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		s := NewTestServer(http.StatusOK, []byte(`[1,2]`))
+		defer s.Close()
+
+		v := httplib.MustReadJSON[[]int](httplib.NewBuilder("GET", s.URL))
+		assert.Equal(t, []int{1, 2}, v)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		defer func() {
+			err := recover()
+			assert.NotNil(t, err)
+			assert.Contains(t, fmt.Sprint(err), "400 Bad Request")
+		}()
+
+		s := NewTestServer(http.StatusBadRequest, DefaultBody)
+		defer s.Close()
+
+		httplib.MustReadJSON[[]int](httplib.NewBuilder("GET", s.URL))
+		assert.Fail(t, "should panic")
+	})
+}
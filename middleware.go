@@ -0,0 +1,60 @@
+package httplib
+
+import "net/http"
+
+// Doer sends an HTTP request and returns the response. *http.Client implements it.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc is an adapter to allow the use of ordinary functions as Doer.
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do calls f(req).
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps a Doer with another Doer, it is used to run code around sending the request,
+// such as logging, injecting authentication, collecting metrics and tracing.
+//
+// A Middleware can modify the request before calling next.Do(), modify the response or the error after it,
+// or return without calling next.Do() to short-circuit the request.
+//
+// The middlewares are registered by Client.WithMiddleware() and RequestBuilder.WithMiddleware().
+// They are called in this order: the middlewares of the Client, in the order they are registered;
+// then the middlewares of the RequestBuilder, in the order they are registered; and at last
+// the http.Client of the Client. The responses are returned in the reverse order.
+//
+// If a RetryPolicy is used, each attempt runs through all the middlewares.
+type Middleware func(next Doer) Doer
+
+// WithMiddleware appends middlewares which are applied to every request sent through the Client.
+// See Middleware for the order of the middlewares.
+func (c *Client) WithMiddleware(middlewares ...Middleware) *Client {
+	c.middlewares = append(c.middlewares, middlewares...)
+	return c
+}
+
+// WithMiddleware appends middlewares which are applied to the request.
+// See Middleware for the order of the middlewares.
+func (x *RequestBuilder) WithMiddleware(middlewares ...Middleware) *RequestBuilder {
+	x.middlewares = append(x.middlewares, middlewares...)
+	return x
+}
+
+// doer returns the Doer which sends the request through all the middlewares and the http.Client.
+func (x *RequestBuilder) doer() Doer {
+	c := x.Client()
+	var d Doer = c.HTTPClient()
+	d = chainMiddlewares(d, x.middlewares)
+	d = chainMiddlewares(d, c.middlewares)
+	return d
+}
+
+func chainMiddlewares(d Doer, middlewares []Middleware) Doer {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		d = middlewares[i](d)
+	}
+	return d
+}
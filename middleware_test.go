@@ -0,0 +1,126 @@
+package httplib_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordMiddleware appends '<name>-before' and '<name>-after' to the logs around calling next.Do().
+func recordMiddleware(name string, logs *[]string) httplib.Middleware {
+	return func(next httplib.Doer) httplib.Doer {
+		return httplib.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			*logs = append(*logs, name+"-before")
+			res, err := next.Do(req)
+			*logs = append(*logs, name+"-after")
+			return res, err
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Run("order", func(t *testing.T) {
+		s := NewTestServer(http.StatusOK, DefaultBody)
+		defer s.Close()
+
+		var logs []string
+		c := httplib.NewClient(s.URL).WithMiddleware(
+			recordMiddleware("c1", &logs),
+			recordMiddleware("c2", &logs),
+		)
+		b := c.NewBuilder("GET", "").WithMiddleware(
+			recordMiddleware("b1", &logs),
+			recordMiddleware("b2", &logs),
+		)
+
+		content, err := b.ReadString()
+		assert.NoError(t, err)
+		assert.Equal(t, string(DefaultBody), content)
+		assert.Equal(t, []string{
+			"c1-before", "c2-before", "b1-before", "b2-before",
+			"b2-after", "b1-after", "c2-after", "c1-after",
+		}, logs)
+	})
+
+	t.Run("modify-request", func(t *testing.T) {
+		s := NewTestServer(http.StatusOK, DefaultBody)
+		defer s.Close()
+
+		auth := func(next httplib.Doer) httplib.Doer {
+			return httplib.DoerFunc(func(req *http.Request) (*http.Response, error) {
+				req.Header.Set("Authorization", "token")
+				return next.Do(req)
+			})
+		}
+
+		_, err := httplib.NewClient(s.URL).WithMiddleware(auth).NewBuilder("GET", "").ReadString()
+		assert.NoError(t, err)
+		assert.Equal(t, "token", s.Request.Header.Get("Authorization"))
+	})
+
+	t.Run("short-circuit", func(t *testing.T) {
+		var logs []string
+		cached := func(next httplib.Doer) httplib.Doer {
+			return httplib.DoerFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Status:     "200 OK",
+					Header:     make(http.Header),
+					Body:       io.NopCloser(strings.NewReader("cached")),
+					Request:    req,
+				}, nil
+			})
+		}
+
+		b := httplib.NewBuilder("GET", "http://temp.invalid").WithMiddleware(
+			cached,
+			recordMiddleware("unreachable", &logs),
+		)
+
+		content, err := b.ReadString()
+		assert.NoError(t, err)
+		assert.Equal(t, "cached", content)
+		assert.Empty(t, logs)
+	})
+
+	t.Run("map-error", func(t *testing.T) {
+		s := NewTestServer(http.StatusNotFound, DefaultBody)
+		defer s.Close()
+
+		errNotFound := errors.New("not found")
+		mapError := func(next httplib.Doer) httplib.Doer {
+			return httplib.DoerFunc(func(req *http.Request) (*http.Response, error) {
+				res, err := next.Do(req)
+				if err == nil && res.StatusCode == http.StatusNotFound {
+					res.Body.Close()
+					return nil, fmt.Errorf("%s: %w", req.URL.Path, errNotFound)
+				}
+				return res, err
+			})
+		}
+
+		_, err := httplib.NewBuilder("GET", s.URL+"/item").WithMiddleware(mapError).Do()
+		assert.ErrorIs(t, err, errNotFound)
+		assert.Equal(t, "/item: not found", err.Error())
+	})
+
+	t.Run("with-retry", func(t *testing.T) {
+		s := NewSequenceServer(statusHandler(503), statusHandler(200))
+		defer s.Close()
+
+		var logs []string
+		b := httplib.NewBuilder("GET", s.URL).
+			WithRetry(fastRetry(2)).
+			WithMiddleware(recordMiddleware("m", &logs))
+
+		_, err := b.ReadString()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"m-before", "m-after", "m-before", "m-after"}, logs)
+	})
+}
@@ -0,0 +1,171 @@
+package httplib
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"sync"
+
+	"github.com/cmstar/go-httplib/headers"
+)
+
+// multipartBody is the body set by the WithMultipart* methods.
+type multipartBody struct {
+	boundary string
+	parts    []multipartPart
+}
+
+type multipartPart struct {
+	header textproto.MIMEHeader
+
+	// Can be string/io.Reader .
+	content any
+}
+
+// WithMultipartField appends a form field to the multipart body,
+// and sets the header Content-Type to 'multipart/form-data' with the boundary.
+//
+// If the given value is not a string, it will be converted to a string.
+//
+// If the current body set is not a multipart body, it will be replaced.
+func (x *RequestBuilder) WithMultipartField(name string, value any) *RequestBuilder {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(name)))
+	return x.appendPart(h, toString(value))
+}
+
+// WithMultipartFile appends a file to the multipart body, the Content-Type of the part is 'application/octet-stream'.
+// It sets the header Content-Type to 'multipart/form-data' with the boundary.
+//
+// The file is read while the request is being sent, it is not buffered in memory.
+// The reader will be closed after it is read if it implements io.ReadCloser, or if the request body is closed
+// before the reader is read, e.g. the request fails to connect.
+//
+// If the current body set is not a multipart body, it will be replaced.
+func (x *RequestBuilder) WithMultipartFile(field string, filename string, r io.Reader) *RequestBuilder {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(field), escapeQuotes(filename)))
+	h.Set("Content-Type", "application/octet-stream")
+	return x.appendPart(h, r)
+}
+
+// WithMultipartPart appends a part with the given header to the multipart body,
+// and sets the header Content-Type to 'multipart/form-data' with the boundary.
+//
+// The reader is read while the request is being sent, it is not buffered in memory.
+// The reader will be closed after it is read if it implements io.ReadCloser, or if the request body is closed
+// before the reader is read, e.g. the request fails to connect.
+//
+// If the current body set is not a multipart body, it will be replaced.
+func (x *RequestBuilder) WithMultipartPart(header textproto.MIMEHeader, r io.Reader) *RequestBuilder {
+	return x.appendPart(header, r)
+}
+
+func (x *RequestBuilder) appendPart(header textproto.MIMEHeader, content any) *RequestBuilder {
+	body, ok := x.body.(*multipartBody)
+	if !ok {
+		body = &multipartBody{
+			boundary: multipart.NewWriter(io.Discard).Boundary(),
+		}
+		x.body = body
+	}
+
+	x.setContentType(headers.MediaTypeMultipartForm.WithParam("boundary", body.boundary))
+	body.parts = append(body.parts, multipartPart{header, content})
+	return x
+}
+
+// reader returns a reader which streams the multipart body. The parts are written through an io.Pipe
+// in a new goroutine, which starts when the reader is read for the first time.
+//
+// If the body only contains strings, it can be read multiple times;
+// otherwise the readers of the parts reach the end after the first time.
+//
+// If the reader is closed before it is fully read, e.g. the request fails before the body is sent,
+// the parts which are not read are closed if they implement io.Closer.
+func (b *multipartBody) reader() io.ReadCloser {
+	pr, pw := io.Pipe()
+	return &lazyPipeReader{
+		PipeReader: pr,
+		start: func() {
+			go func() {
+				pw.CloseWithError(b.write(pw))
+			}()
+		},
+		cancel: func() {
+			b.closeParts(0)
+		},
+	}
+}
+
+func (b *multipartBody) write(w io.Writer) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(b.boundary); err != nil {
+		return err
+	}
+
+	for i, part := range b.parts {
+		pw, err := mw.CreatePart(part.header)
+		if err != nil {
+			b.closeParts(i)
+			return err
+		}
+
+		switch v := part.content.(type) {
+		case string:
+			_, err = io.WriteString(pw, v)
+
+		case io.Reader:
+			_, err = io.Copy(pw, v)
+			if c, ok := v.(io.Closer); ok {
+				e := c.Close()
+				if err == nil {
+					err = e
+				}
+			}
+		}
+
+		if err != nil {
+			b.closeParts(i + 1)
+			return err
+		}
+	}
+
+	return mw.Close()
+}
+
+// closeParts closes the parts from the given index which implement io.Closer.
+func (b *multipartBody) closeParts(from int) {
+	for _, part := range b.parts[from:] {
+		if c, ok := part.content.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
+
+// lazyPipeReader calls start() before the first Read(), or cancel() if it is closed before the first Read().
+type lazyPipeReader struct {
+	*io.PipeReader
+	once   sync.Once
+	start  func()
+	cancel func()
+}
+
+func (r *lazyPipeReader) Read(p []byte) (int, error) {
+	r.once.Do(r.start)
+	return r.PipeReader.Read(p)
+}
+
+// Close closes the pipe. If the writing has started, it fails and closes the remaining parts.
+func (r *lazyPipeReader) Close() error {
+	r.once.Do(r.cancel)
+	return r.PipeReader.Close()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -0,0 +1,179 @@
+package httplib_test
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPart struct {
+	Header  textproto.MIMEHeader
+	Content string
+}
+
+func readTestParts(t *testing.T, contentType string, body []byte) []testPart {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	require.NoError(t, err)
+	require.Equal(t, "multipart/form-data", mediaType)
+
+	var parts []testPart
+	r := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		require.NoError(t, err)
+
+		content, err := io.ReadAll(p)
+		require.NoError(t, err)
+		parts = append(parts, testPart{p.Header, string(content)})
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestRequestBuilder_WithMultipart(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		s := NewTestServer(http.StatusOK, DefaultBody)
+		defer s.Close()
+
+		file := &closeRecorder{Reader: strings.NewReader("file content")}
+		partHeader := textproto.MIMEHeader{
+			"Content-Disposition": {`form-data; name="raw"`},
+			"Content-Type":        {"text/plain"},
+		}
+
+		b := httplib.NewBuilder("POST", s.URL).
+			SetStringBody("override").
+			WithMultipartField("a", 1).
+			WithMultipartField(`q"b`, "中文").
+			WithMultipartFile("f", "a.txt", file).
+			WithMultipartPart(partHeader, strings.NewReader("raw content"))
+
+		content, err := b.ReadString()
+		assert := assert.New(t)
+		assert.NoError(err)
+		assert.Equal(string(DefaultBody), content)
+		assert.True(file.closed)
+
+		parts := readTestParts(t, s.Request.Header.Get("Content-Type"), s.Body)
+		assert.Len(parts, 4)
+		assert.Equal(`form-data; name="a"`, parts[0].Header.Get("Content-Disposition"))
+		assert.Equal("1", parts[0].Content)
+		assert.Equal(`form-data; name="q\"b"`, parts[1].Header.Get("Content-Disposition"))
+		assert.Equal("中文", parts[1].Content)
+		assert.Equal(`form-data; name="f"; filename="a.txt"`, parts[2].Header.Get("Content-Disposition"))
+		assert.Equal("application/octet-stream", parts[2].Header.Get("Content-Type"))
+		assert.Equal("file content", parts[2].Content)
+		assert.Equal("text/plain", parts[3].Header.Get("Content-Type"))
+		assert.Equal("raw content", parts[3].Content)
+
+		// The request is sent with chunked encoding, the body is not buffered.
+		assert.Equal([]string{"chunked"}, s.Request.TransferEncoding)
+	})
+
+	t.Run("parse-form", func(t *testing.T) {
+		var values map[string][]string
+		var fileContent string
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseMultipartForm(1 << 20)
+			values = r.MultipartForm.Value
+			f, _, _ := r.FormFile("f")
+			data, _ := io.ReadAll(f)
+			fileContent = string(data)
+		}))
+		defer s.Close()
+
+		_, err := httplib.NewBuilder("POST", s.URL).
+			WithMultipartField("a", "1").
+			WithMultipartField("a", "2").
+			WithMultipartFile("f", "a.bin", bytes.NewReader([]byte{1, 2, 3})).
+			ReadBinary()
+		assert.NoError(t, err)
+		assert.Equal(t, map[string][]string{"a": {"1", "2"}}, values)
+		assert.Equal(t, string([]byte{1, 2, 3}), fileContent)
+	})
+
+	t.Run("reusable-fields", func(t *testing.T) {
+		s := NewTestServer(http.StatusOK, DefaultBody)
+		defer s.Close()
+
+		b := httplib.NewBuilder("POST", s.URL).WithMultipartField("a", "1")
+		for i := 0; i < 2; i++ {
+			_, err := b.ReadBinary()
+			assert.NoError(t, err)
+
+			parts := readTestParts(t, s.Request.Header.Get("Content-Type"), s.Body)
+			assert.Equal(t, "1", parts[0].Content)
+		}
+	})
+
+	t.Run("lazy", func(t *testing.T) {
+		file := &closeRecorder{Reader: strings.NewReader("file content")}
+		b := httplib.NewBuilder("POST", "http://temp.org").WithMultipartFile("f", "a.txt", file)
+
+		req, err := b.Build()
+		assert.NoError(t, err)
+		assert.False(t, file.closed)
+
+		// Once read, the parts are written.
+		data, err := io.ReadAll(req.Body)
+		assert.NoError(t, err)
+		assert.True(t, file.closed)
+		assert.Contains(t, string(data), "file content")
+	})
+
+	t.Run("closed-unread", func(t *testing.T) {
+		file := &closeRecorder{Reader: strings.NewReader("file content")}
+		req, err := httplib.NewBuilder("POST", "http://temp.org").WithMultipartFile("f", "a.txt", file).Build()
+		assert.NoError(t, err)
+
+		// The parts are closed if the body is closed before it is read.
+		assert.NoError(t, req.Body.Close())
+		assert.True(t, file.closed)
+		_, err = req.Body.Read(make([]byte, 1))
+		assert.Error(t, err)
+	})
+
+	t.Run("dial-error", func(t *testing.T) {
+		a := &closeRecorder{Reader: strings.NewReader("a")}
+		b := &closeRecorder{Reader: strings.NewReader("b")}
+		_, err := httplib.NewBuilder("POST", "http://127.0.0.1:1/").
+			WithMultipartField("x", 1).
+			WithMultipartFile("a", "a.txt", a).
+			WithMultipartPart(textproto.MIMEHeader{"Content-Disposition": {`form-data; name="b"`}}, b).
+			Send()
+		require.Error(t, err)
+		assert.True(t, a.closed)
+		assert.True(t, b.closed)
+	})
+
+	t.Run("replaced-by-form", func(t *testing.T) {
+		b := httplib.NewBuilder("POST", "http://temp.org").WithMultipartField("a", 1).WithForm("b", 2)
+		req, err := b.Build()
+		assert.NoError(t, err)
+		assert.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
+
+		data, _ := io.ReadAll(req.Body)
+		assert.Equal(t, "b=2", string(data))
+	})
+}
@@ -2,41 +2,106 @@ package httplib
 
 import (
 	"bytes"
-	"errors"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+
+	"github.com/cmstar/go-httplib/headers"
 )
 
 // RequestBuilder is used to simply build HTTP request.
 type RequestBuilder struct {
 	Method  string
 	baseUrl string
+	client  *Client
+	ctx     context.Context
+
+	// The URI template given by NewBuilderTemplate() and the values of its variables.
+	template   *uriTemplate
+	pathParams map[string]any
 
 	query  url.Values
 	header http.Header
 
-	// Can be string/[]byte/io.Reader/url.Values .
+	// True if the Authorization header is set by WithAuthScheme(), WithBasicAuth() or WithBearerToken().
+	auth bool
+
+	// Can be string/[]byte/io.Reader/url.Values/*multipartBody .
 	body any
+
+	// The first error occurred while setting up the builder, it is returned by Build().
+	err error
+
+	// The status codes accepted by the Read* methods. If empty, only 200 OK is accepted.
+	expectStatus []statusRange
+
+	retry       *RetryPolicy
+	middlewares []Middleware
+	timeouts    timeouts
+}
+
+// statusRange is a closed interval of status codes.
+type statusRange struct {
+	min, max int
 }
 
-// NewBuilder creates a new instance of RequestBuilder.
+// NewBuilder creates a new instance of RequestBuilder which sends the request through DefaultClient.
 func NewBuilder(method string, baseUrl string) *RequestBuilder {
-	return &RequestBuilder{
-		Method:  method,
-		baseUrl: baseUrl,
+	return DefaultClient.NewBuilder(method, baseUrl)
+}
+
+// Client returns the Client which the request is sent through.
+func (x *RequestBuilder) Client() *Client {
+	if x.client == nil {
+		return DefaultClient
+	}
+	return x.client
+}
+
+// Context returns the context given by WithContext(). If no context was given, returns context.Background().
+func (x *RequestBuilder) Context() context.Context {
+	if x.ctx == nil {
+		return context.Background()
+	}
+	return x.ctx
+}
 
-		query:  make(url.Values),
-		header: make(http.Header),
+// WithContext sets the context of the request. It is used by Build(), Do() and the Read* methods,
+// the request is canceled once the context is done.
+//
+// Panics if the context is nil.
+func (x *RequestBuilder) WithContext(ctx context.Context) *RequestBuilder {
+	if ctx == nil {
+		panic("nil context")
 	}
+	x.ctx = ctx
+	return x
 }
 
-// URL returns the whole URL, includes all added query strings.
+// URL returns the whole URL, includes all added query strings and the default query strings of the Client.
+// If the URL is a URI template which can not be expanded, the template is returned as is, Build() returns the error.
 func (x *RequestBuilder) URL() string {
-	uri := x.baseUrl
-	queryString := x.query.Encode()
+	uri, err := x.buildURL()
+	if err != nil {
+		return x.appendQuery(x.baseUrl)
+	}
+	return uri
+}
+
+// buildURL is like URL(), but returns an error if the URI template can not be expanded.
+func (x *RequestBuilder) buildURL() (string, error) {
+	uri, err := x.expandURL()
+	if err != nil {
+		return "", err
+	}
+	return x.appendQuery(uri), nil
+}
+
+func (x *RequestBuilder) appendQuery(uri string) string {
+	queryString := x.Client().mergeQuery(x.query).Encode()
 
 	if queryString != "" {
 		hasQuestionMark := false
@@ -63,7 +128,7 @@ func (x *RequestBuilder) URL() string {
 //
 // If the given value is not a string, it will be converted to a string.
 func (x *RequestBuilder) WithQuery(name string, value any) *RequestBuilder {
-	s := x.toString(value)
+	s := toString(value)
 	x.query.Add(name, s)
 	return x
 }
@@ -77,7 +142,7 @@ func (x *RequestBuilder) WithQueries(values map[string]any) *RequestBuilder {
 	}
 
 	for k, v := range values {
-		s := x.toString(v)
+		s := toString(v)
 		x.query.Add(k, s)
 	}
 	return x
@@ -91,7 +156,7 @@ func (x *RequestBuilder) WithQueries(values map[string]any) *RequestBuilder {
 // If the current body set is not a form, it will be replaced.
 func (x *RequestBuilder) WithForm(name string, value any) *RequestBuilder {
 	q := x.ensureForm()
-	s := x.toString(value)
+	s := toString(value)
 	q.Add(name, s)
 	return x
 }
@@ -110,7 +175,7 @@ func (x *RequestBuilder) WithForms(values map[string]any) *RequestBuilder {
 	}
 
 	for k, v := range values {
-		s := x.toString(v)
+		s := toString(v)
 		q.Add(k, s)
 	}
 	return x
@@ -120,7 +185,7 @@ func (x *RequestBuilder) WithForms(values map[string]any) *RequestBuilder {
 //
 // If the given value is not a string, it will be converted to a string.
 func (x *RequestBuilder) WithHeader(name string, value any) *RequestBuilder {
-	s := x.toString(value)
+	s := toString(value)
 	x.header.Add(name, s)
 	return x
 }
@@ -134,12 +199,44 @@ func (x *RequestBuilder) WithHeaders(values map[string]any) *RequestBuilder {
 	}
 
 	for k, v := range values {
-		s := x.toString(v)
+		s := toString(v)
 		x.header.Add(k, s)
 	}
 	return x
 }
 
+// ExpectStatus sets the status codes accepted by the Read* methods.
+//
+// By default only 200 OK is accepted. Once this method or ExpectStatusRange() is called,
+// the default is dropped and the status codes given by all the calls are accepted.
+func (x *RequestBuilder) ExpectStatus(codes ...int) *RequestBuilder {
+	for _, code := range codes {
+		x.expectStatus = append(x.expectStatus, statusRange{code, code})
+	}
+	return x
+}
+
+// ExpectStatusRange is like ExpectStatus(), it accepts the status codes in the closed interval [min, max],
+// e.g. ExpectStatusRange(200, 299) accepts all 2xx status codes.
+func (x *RequestBuilder) ExpectStatusRange(min, max int) *RequestBuilder {
+	x.expectStatus = append(x.expectStatus, statusRange{min, max})
+	return x
+}
+
+// IsExpectedStatus returns true if the given status code is accepted by the Read* methods.
+func (x *RequestBuilder) IsExpectedStatus(code int) bool {
+	if len(x.expectStatus) == 0 {
+		return code == http.StatusOK
+	}
+
+	for _, r := range x.expectStatus {
+		if code >= r.min && code <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
 // SetStringBody set a string as the request body. If another body was set, it will be replaced.
 func (x *RequestBuilder) SetStringBody(body string) *RequestBuilder {
 	x.body = body
@@ -169,38 +266,73 @@ func (x *RequestBuilder) SetReaderBody(reader io.Reader) *RequestBuilder {
 // is performed, the reader reached the end and is not reusable, you can call SetReaderBody() again to
 // setup a new body.
 func (x *RequestBuilder) Build() (*http.Request, error) {
-	uri := x.URL()
+	return x.BuildContext(x.Context())
+}
+
+// BuildContext is the same as Build(), but uses the given context instead of the one given by WithContext().
+func (x *RequestBuilder) BuildContext(ctx context.Context) (*http.Request, error) {
+	if x.err != nil {
+		return nil, x.err
+	}
+
+	uri, err := x.buildURL()
+	if err != nil {
+		return nil, err
+	}
+
+	if x.hasCredentials() {
+		ctx = withCredentials(ctx)
+	}
+
 	body := x.buildBody()
-	request, err := http.NewRequest(x.Method, uri, body)
+	request, err := http.NewRequestWithContext(ctx, x.Method, uri, body)
 	if err != nil {
 		return nil, err
 	}
 
-	request.Header = x.header
+	request.Header = x.Client().mergeHeader(x.header)
 	return request, nil
 }
 
-// Do executes the HTTP request.
+// Do executes the HTTP request through the middlewares and the http.Client of the Client.
+// If a RetryPolicy is given by WithRetry(), the request may be sent multiple times.
 func (x *RequestBuilder) Do() (*http.Response, error) {
-	request, err := x.Build()
-	if err != nil {
-		return nil, err
+	return x.DoContext(x.Context())
+}
+
+// DoContext is the same as Do(), but uses the given context instead of the one given by WithContext().
+func (x *RequestBuilder) DoContext(ctx context.Context) (*http.Response, error) {
+	if len(x.timeouts) > 0 {
+		return x.timeouts.do(ctx, x.doContext)
+	}
+	return x.doContext(ctx)
+}
+
+func (x *RequestBuilder) doContext(ctx context.Context) (*http.Response, error) {
+	if x.retry != nil {
+		return x.retry.do(ctx, x)
 	}
 
-	response, err := new(http.Client).Do(request)
+	request, err := x.BuildContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return response, nil
+	return x.send(request)
 }
 
-// ReadBinary executes the HTTP request, if the status code of the response is 200 OK,
-// it returns the whole response body as a slice of byte; otherwise returns an error.
+// ReadBinary executes the HTTP request, if the status code of the response is accepted
+// (200 OK by default, see ExpectStatus()), it returns the whole response body as a slice of byte;
+// otherwise returns an *HTTPError.
 //
-// If you need to get the body when the status code is not 200 OK, call Do().
-func (x *RequestBuilder) ReadBinary() (data []byte, err error) {
-	res, err := x.Do()
+// If you need to get the body when the status code is not accepted, call Do().
+func (x *RequestBuilder) ReadBinary() ([]byte, error) {
+	return x.ReadBinaryContext(x.Context())
+}
+
+// ReadBinaryContext is the same as ReadBinary(), but uses the given context instead of the one given by WithContext().
+func (x *RequestBuilder) ReadBinaryContext(ctx context.Context) (data []byte, err error) {
+	res, err := x.DoContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -213,19 +345,25 @@ func (x *RequestBuilder) ReadBinary() (data []byte, err error) {
 		// Drop e if err is not nil.
 	}()
 
-	if res.StatusCode != http.StatusOK {
-		return nil, errors.New(res.Status)
+	if !x.IsExpectedStatus(res.StatusCode) {
+		return nil, newHTTPError(res)
 	}
 
 	return io.ReadAll(res.Body)
 }
 
-// ReadString executes the HTTP request, if the status code of the response is 200 OK,
-// it returns the whole response body as a string; otherwise returns an error.
+// ReadString executes the HTTP request, if the status code of the response is accepted
+// (200 OK by default, see ExpectStatus()), it returns the whole response body as a string;
+// otherwise returns an *HTTPError.
 //
-// If you need to get the body when the status code is not 200 OK, call Do().
+// If you need to get the body when the status code is not accepted, call Do().
 func (x *RequestBuilder) ReadString() (string, error) {
-	res, err := x.ReadBinary()
+	return x.ReadStringContext(x.Context())
+}
+
+// ReadStringContext is the same as ReadString(), but uses the given context instead of the one given by WithContext().
+func (x *RequestBuilder) ReadStringContext(ctx context.Context) (string, error) {
+	res, err := x.ReadBinaryContext(ctx)
 	return string(res), err
 }
 
@@ -271,13 +409,16 @@ func (x *RequestBuilder) buildBody() io.Reader {
 		case url.Values:
 			q := v.Encode()
 			return strings.NewReader(q)
+
+		case *multipartBody:
+			return v.reader()
 		}
 	}
 
 	return nil
 }
 
-func (x *RequestBuilder) toString(v any) string {
+func toString(v any) string {
 	if v == nil {
 		return ""
 	}
@@ -292,8 +433,38 @@ func (x *RequestBuilder) toString(v any) string {
 	}
 }
 
+// send sends the request through the middlewares and the http.Client of the Client.
+func (x *RequestBuilder) send(request *http.Request) (*http.Response, error) {
+	response, err := x.doer().Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// setErr records the error if there is no error recorded before.
+func (x *RequestBuilder) setErr(err error) {
+	if x.err == nil {
+		x.err = err
+	}
+}
+
+// setContentType sets the header Content-Type to the given media type. If the current Content-Type is the same media type,
+// its parameters are kept, e.g. 'charset', and the parameters of mt take precedence.
+func (x *RequestBuilder) setContentType(mt headers.MediaType) {
+	if current, err := headers.ParseMediaType(x.header.Get(headers.ContentType)); err == nil && current.Essence() == mt.Essence() {
+		for k, v := range mt.Params {
+			current = current.WithParam(k, v)
+		}
+		mt = current
+	}
+
+	x.header.Set(headers.ContentType, mt.String())
+}
+
 func (x *RequestBuilder) ensureForm() url.Values {
-	x.header.Set("Content-Type", "application/x-www-form-urlencoded")
+	x.setContentType(headers.MediaTypeFormURLEncoded)
 
 	if values, ok := x.body.(url.Values); ok {
 		return values
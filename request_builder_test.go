@@ -1,12 +1,15 @@
 package httplib_test
 
 import (
+	"context"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/cmstar/go-httplib"
 	"github.com/stretchr/testify/assert"
@@ -33,6 +36,28 @@ func NewTestServer(status int, body []byte) *TestServer {
 	return ts
 }
 
+// NewBlockingServer creates a server which does not respond until the request is canceled.
+// The started channel receives a value when a request arrives.
+func NewBlockingServer() (s *httptest.Server, started chan struct{}) {
+	started = make(chan struct{}, 1)
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body) // The cancellation can not be detected until the body is consumed.
+		started <- struct{}{}
+		<-r.Context().Done()
+	}))
+	return
+}
+
+// CancelOnStart cancels the returned context once the started channel receives a value.
+func CancelOnStart(started chan struct{}) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	return ctx
+}
+
 func TestRequestBuilder_URL(t *testing.T) {
 	t.Run("url-without-query", func(t *testing.T) {
 		urlBase := "http://temp.org"
@@ -312,3 +337,144 @@ func TestRequestBuilder_MustReadString(t *testing.T) {
 		b.MustReadString()
 	})
 }
+
+func TestRequestBuilder_WithContext(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		b := httplib.NewBuilder("GET", "http://temp.org")
+		assert.Equal(t, context.Background(), b.Context())
+
+		req, err := b.Build()
+		assert.NoError(t, err)
+		assert.Equal(t, context.Background(), req.Context())
+	})
+
+	t.Run("build", func(t *testing.T) {
+		type key struct{}
+		ctx := context.WithValue(context.Background(), key{}, 1)
+		b := httplib.NewBuilder("GET", "http://temp.org").WithContext(ctx)
+		assert.Same(t, ctx, b.Context())
+
+		req, err := b.Build()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, req.Context().Value(key{}))
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		assert.Panics(t, func() {
+			httplib.NewBuilder("GET", "http://temp.org").WithContext(nil)
+		})
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		s, started := NewBlockingServer()
+		defer s.Close()
+
+		ctx := CancelOnStart(started)
+		content, err := httplib.NewBuilder("GET", s.URL).WithContext(ctx).ReadString()
+		assert.True(t, errors.Is(err, context.Canceled), "%v", err)
+		assert.Equal(t, "", content)
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		s, _ := NewBlockingServer()
+		defer s.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		resp, err := httplib.NewBuilder("GET", s.URL).WithContext(ctx).Do()
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+		assert.Nil(t, resp)
+	})
+}
+
+func TestRequestBuilder_DoContext(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		s := NewTestServer(http.StatusOK, DefaultBody)
+		defer s.Close()
+
+		resp, err := httplib.NewBuilder("GET", s.URL).DoContext(context.Background())
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		s, started := NewBlockingServer()
+		defer s.Close()
+
+		// The context given to DoContext() overrides the one given by WithContext().
+		b := httplib.NewBuilder("GET", s.URL).WithContext(context.Background())
+		resp, err := b.DoContext(CancelOnStart(started))
+		assert.True(t, errors.Is(err, context.Canceled), "%v", err)
+		assert.Nil(t, resp)
+	})
+}
+
+func TestRequestBuilder_ReadBinaryContext(t *testing.T) {
+	s, started := NewBlockingServer()
+	defer s.Close()
+
+	content, err := httplib.NewBuilder("POST", s.URL).SetStringBody("body").ReadBinaryContext(CancelOnStart(started))
+	assert.True(t, errors.Is(err, context.Canceled), "%v", err)
+	assert.Nil(t, content)
+}
+
+func TestRequestBuilder_ReadStringContext(t *testing.T) {
+	s := NewTestServer(http.StatusOK, DefaultBody)
+	defer s.Close()
+
+	content, err := httplib.NewBuilder("GET", s.URL).ReadStringContext(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, string(DefaultBody), content)
+}
+
+func TestRequestBuilder_ExpectStatus(t *testing.T) {
+	cases := []struct {
+		name   string
+		setup  func(b *httplib.RequestBuilder)
+		status int
+		ok     bool
+	}{
+		{"default-200", func(b *httplib.RequestBuilder) {}, 200, true},
+		{"default-201", func(b *httplib.RequestBuilder) {}, 201, false},
+		{"codes-201", func(b *httplib.RequestBuilder) { b.ExpectStatus(201, 202) }, 201, true},
+		{"codes-202", func(b *httplib.RequestBuilder) { b.ExpectStatus(201, 202) }, 202, true},
+		{"codes-200", func(b *httplib.RequestBuilder) { b.ExpectStatus(201, 202) }, 200, false},
+		{"range-299", func(b *httplib.RequestBuilder) { b.ExpectStatusRange(200, 299) }, 299, true},
+		{"range-300", func(b *httplib.RequestBuilder) { b.ExpectStatusRange(200, 299) }, 300, false},
+		{"mixed-404", func(b *httplib.RequestBuilder) { b.ExpectStatusRange(200, 299).ExpectStatus(404) }, 404, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := NewTestServer(c.status, DefaultBody)
+			defer s.Close()
+
+			b := httplib.NewBuilder("GET", s.URL)
+			c.setup(b)
+			assert.Equal(t, c.ok, b.IsExpectedStatus(c.status))
+
+			content, err := b.ReadString()
+			if c.ok {
+				assert.NoError(t, err)
+				assert.Equal(t, string(DefaultBody), content)
+			} else {
+				assert.True(t, httplib.IsStatus(err, c.status), "%v", err)
+				assert.Equal(t, "", content)
+			}
+		})
+	}
+
+	t.Run("client-option", func(t *testing.T) {
+		s := NewTestServer(http.StatusCreated, DefaultBody)
+		defer s.Close()
+
+		c := httplib.NewClient(s.URL).WithOption(func(b *httplib.RequestBuilder) {
+			b.ExpectStatusRange(200, 299)
+		})
+		content, err := c.NewBuilder("POST", "").ReadString()
+		assert.NoError(t, err)
+		assert.Equal(t, string(DefaultBody), content)
+	})
+}
@@ -0,0 +1,127 @@
+package httplib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Response wraps an http.Response whose body has been read into memory and closed.
+// It is returned by RequestBuilder.Send().
+type Response struct {
+	raw     *http.Response
+	body    []byte
+	elapsed time.Duration
+}
+
+// Raw returns the underlying http.Response. Its body is replaced with a reader of the buffered body.
+func (r *Response) Raw() *http.Response {
+	return r.raw
+}
+
+// Request returns the request which the response is for. If redirects were followed, it is the last request.
+func (r *Response) Request() *http.Request {
+	return r.raw.Request
+}
+
+// StatusCode returns the status code of the response, e.g. 200.
+func (r *Response) StatusCode() int {
+	return r.raw.StatusCode
+}
+
+// Status returns the status line of the response, e.g. '200 OK'.
+func (r *Response) Status() string {
+	return r.raw.Status
+}
+
+// Header returns the header of the response.
+func (r *Response) Header() http.Header {
+	return r.raw.Header
+}
+
+// Bytes returns the whole response body.
+func (r *Response) Bytes() []byte {
+	return r.body
+}
+
+// String returns the whole response body as a string.
+func (r *Response) String() string {
+	return string(r.body)
+}
+
+// JSON decodes the response body as JSON into v.
+func (r *Response) JSON(v any) error {
+	return json.Unmarshal(r.body, v)
+}
+
+// XML decodes the response body as XML into v.
+func (r *Response) XML(v any) error {
+	return xml.Unmarshal(r.body, v)
+}
+
+// IsSuccess returns true if the status code is 2xx.
+func (r *Response) IsSuccess() bool {
+	return r.raw.StatusCode >= 200 && r.raw.StatusCode < 300
+}
+
+// Location returns the URL of the response's Location header, resolved against the URL of the request.
+// Returns http.ErrNoLocation if no Location header is present.
+func (r *Response) Location() (*url.URL, error) {
+	return r.raw.Location()
+}
+
+// Cookies parses and returns the cookies set in the Set-Cookie headers.
+func (r *Response) Cookies() []*http.Cookie {
+	return r.raw.Cookies()
+}
+
+// Elapsed returns the time elapsed from sending the request to reading the end of the response body.
+func (r *Response) Elapsed() time.Duration {
+	return r.elapsed
+}
+
+// Send executes the HTTP request, reads the whole response body and closes it.
+//
+// Unlike the Read* methods, Send() does not check the status code. An error is returned only if the request
+// can not be sent or the body can not be read.
+func (x *RequestBuilder) Send() (*Response, error) {
+	return x.SendContext(x.Context())
+}
+
+// SendContext is the same as Send(), but uses the given context instead of the one given by WithContext().
+func (x *RequestBuilder) SendContext(ctx context.Context) (*Response, error) {
+	start := time.Now()
+
+	raw, err := x.DoContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The error of Close() is dropped, the body has been read completely or the error of reading is returned.
+	body, err := io.ReadAll(raw.Body)
+	raw.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	raw.Body = io.NopCloser(bytes.NewReader(body))
+	return &Response{
+		raw:     raw,
+		body:    body,
+		elapsed: time.Since(start),
+	}, nil
+}
+
+// MustSend is the panic version of Send().
+func (x *RequestBuilder) MustSend() *Response {
+	res, err := x.Send()
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
@@ -0,0 +1,128 @@
+package httplib_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBuilder_Send(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.SetCookie(w, &http.Cookie{Name: "c", Value: "v"})
+			w.Header().Set("Location", "/next")
+			w.Header().Set("X-Custom", "x")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"name":"n","value":1}`))
+		}))
+		defer s.Close()
+
+		res, err := httplib.NewBuilder("POST", s.URL+"/path").Send()
+		require.NoError(t, err)
+
+		assert := assert.New(t)
+		assert.Equal(http.StatusCreated, res.StatusCode())
+		assert.Equal("201 Created", res.Status())
+		assert.True(res.IsSuccess())
+		assert.Equal("x", res.Header().Get("X-Custom"))
+		assert.Equal(`{"name":"n","value":1}`, res.String())
+		assert.Equal([]byte(`{"name":"n","value":1}`), res.Bytes())
+		assert.Greater(res.Elapsed().Nanoseconds(), int64(0))
+		assert.Equal("POST", res.Request().Method)
+		assert.Equal("/path", res.Request().URL.Path)
+
+		var v jsonTestData
+		assert.NoError(res.JSON(&v))
+		assert.Equal(jsonTestData{"n", 1}, v)
+
+		loc, err := res.Location()
+		assert.NoError(err)
+		assert.Equal(s.URL+"/next", loc.String())
+
+		cookies := res.Cookies()
+		assert.Len(cookies, 1)
+		assert.Equal("c", cookies[0].Name)
+		assert.Equal("v", cookies[0].Value)
+
+		// The body of the raw response is still readable.
+		data := make([]byte, 100)
+		n, _ := res.Raw().Body.Read(data)
+		assert.Equal(res.String(), string(data[:n]))
+	})
+
+	t.Run("xml", func(t *testing.T) {
+		s := NewTestServer(http.StatusOK, []byte(`<data><name>n</name><value>2</value></data>`))
+		defer s.Close()
+
+		res := httplib.NewBuilder("GET", s.URL).MustSend()
+
+		var v struct {
+			Name  string `xml:"name"`
+			Value int    `xml:"value"`
+		}
+		assert.NoError(t, res.XML(&v))
+		assert.Equal(t, "n", v.Name)
+		assert.Equal(t, 2, v.Value)
+
+		_, err := res.Location()
+		assert.ErrorIs(t, err, http.ErrNoLocation)
+		assert.Empty(t, res.Cookies())
+	})
+
+	t.Run("status-not-checked", func(t *testing.T) {
+		s := NewTestServer(http.StatusNotFound, DefaultBody)
+		defer s.Close()
+
+		res, err := httplib.NewBuilder("GET", s.URL).Send()
+		assert.NoError(t, err)
+		assert.False(t, res.IsSuccess())
+		assert.Equal(t, string(DefaultBody), res.String())
+	})
+
+	t.Run("err", func(t *testing.T) {
+		res, err := httplib.NewBuilder("GET", "wrong url").Send()
+		assert.Error(t, err)
+		assert.Nil(t, res)
+	})
+
+	t.Run("close-error", func(t *testing.T) {
+		s := NewTestServer(http.StatusOK, DefaultBody)
+		defer s.Close()
+
+		// The error of closing the body is dropped once the body is read completely.
+		res, err := httplib.NewBuilder("GET", s.URL).
+			WithMiddleware(func(next httplib.Doer) httplib.Doer {
+				return httplib.DoerFunc(func(req *http.Request) (*http.Response, error) {
+					res, err := next.Do(req)
+					if err == nil {
+						res.Body = closeErrorBody{res.Body}
+					}
+					return res, err
+				})
+			}).
+			Send()
+		require.NoError(t, err)
+		assert.Equal(t, string(DefaultBody), res.String())
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		assert.Panics(t, func() {
+			httplib.NewBuilder("GET", "wrong url").MustSend()
+		})
+	})
+}
+
+// closeErrorBody returns an error on Close().
+type closeErrorBody struct {
+	io.Reader
+}
+
+func (closeErrorBody) Close() error {
+	return errors.New("close error")
+}
@@ -0,0 +1,301 @@
+package httplib
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cmstar/go-httplib/headers"
+)
+
+// RetryPolicy describes when and how a request is retried. It is given to RequestBuilder.WithRetry().
+//
+// A request is retried when the response has a status code in RetryableStatus, or the request fails
+// with an error accepted by RetryableError. Between two attempts, the delay given by the Retry-After header
+// of the response is used if present; otherwise an exponential backoff with jitter is used.
+//
+// A request can be retried only if its body can be rewound. The string/[]byte/form bodies, the multipart bodies
+// without readers, and the reader bodies which implement io.Seeker but not io.Closer (such as strings.Reader
+// and bytes.Reader) can be rewound.
+type RetryPolicy struct {
+	// MaxAttempts is the max number of attempts, includes the first one. A value less than 2 disables retry.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the max delay between two attempts computed by backoff. Zero means no limit.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor by which the delay increases after each retry.
+	Multiplier float64
+
+	// Jitter randomizes the delay, it should be in [0, 1]. The delay d becomes a random value in [d*(1-Jitter), d*(1+Jitter)].
+	Jitter float64
+
+	// RetryableStatus gives the status codes of the responses to be retried.
+	RetryableStatus []int
+
+	// RetryableError returns true if the request should be retried on the given error.
+	// If nil, the request is not retried on any error.
+	RetryableError func(err error) bool
+
+	// RetryNonIdempotent allows retrying the requests with non-idempotent methods such as POST and PATCH.
+	// By default, only GET/HEAD/OPTIONS/TRACE/PUT/DELETE requests and the requests with an Idempotency-Key
+	// or X-Idempotency-Key header are retried.
+	RetryNonIdempotent bool
+
+	// MaxRetryAfter limits the delay given by the Retry-After header. If the delay is greater than it,
+	// the response is returned without retrying. Zero means no limit.
+	MaxRetryAfter time.Duration
+}
+
+// NewRetryPolicy creates a RetryPolicy with the given max number of attempts and the default settings:
+// the backoff starts at 100ms, doubles after each retry, up to 10s, with 20% jitter;
+// the responses with the status codes 408/429/502/503/504 and the errors accepted by IsRetryableError() are retried.
+func NewRetryPolicy(maxAttempts int) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryableStatus: []int{
+			http.StatusRequestTimeout,
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RetryableError: IsRetryableError,
+	}
+}
+
+// WithRetry sets the RetryPolicy used by Do() and the Read* methods. If the policy is nil, the request is not retried.
+func (x *RequestBuilder) WithRetry(policy *RetryPolicy) *RequestBuilder {
+	x.retry = policy
+	return x
+}
+
+// IsRetryableError returns true if the error is a temporary network error: a timeout which is not caused by
+// the context, a refused or reset connection, or a connection closed unexpectedly.
+func IsRetryableError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Backoff returns the delay computed by backoff before the n-th retry, n starts from 1.
+func (p *RetryPolicy) Backoff(n int) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(n-1))
+
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(2*randFloat64()-1)
+	}
+
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+
+	// The conversion of a float out of the range of int64 is implementation-defined.
+	// NaN is given by a zero InitialBackoff multiplied by an infinite growth, no delay is wanted.
+	switch {
+	case d >= math.MaxInt64:
+		return math.MaxInt64
+	case d < 0 || math.IsNaN(d):
+		return 0
+	}
+	return time.Duration(d)
+}
+
+func (p *RetryPolicy) isRetryableStatus(code int) bool {
+	for _, v := range p.RetryableStatus {
+		if v == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RetryPolicy) isRetryableMethod(req *http.Request) bool {
+	if p.RetryNonIdempotent {
+		return true
+	}
+
+	switch req.Method {
+	case "", "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+
+	_, hasKey := req.Header["Idempotency-Key"]
+	_, hasXKey := req.Header["X-Idempotency-Key"]
+	return hasKey || hasXKey
+}
+
+// do executes the request with the builder, retries on need.
+func (p *RetryPolicy) do(ctx context.Context, x *RequestBuilder) (*http.Response, error) {
+	rewind := x.bodyRewinder()
+
+	for n := 1; ; n++ {
+		req, err := x.BuildContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := x.send(req)
+
+		if n >= p.MaxAttempts || rewind == nil || !p.isRetryableMethod(req) {
+			return res, err
+		}
+
+		var delay time.Duration
+		if err != nil {
+			if p.RetryableError == nil || !p.RetryableError(err) {
+				return nil, err
+			}
+			delay = p.Backoff(n)
+		} else {
+			if !p.isRetryableStatus(res.StatusCode) {
+				return res, nil
+			}
+
+			retryAfter, ok := parseRetryAfter(res.Header.Get(headers.RetryAfter), time.Now())
+			if !ok {
+				delay = p.Backoff(n)
+			} else if p.MaxRetryAfter > 0 && retryAfter > p.MaxRetryAfter {
+				return res, nil
+			} else {
+				delay = retryAfter
+			}
+
+			// Drain the body so that the connection can be reused.
+			drainAndClose(res.Body)
+		}
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+
+		if err := rewind(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// bodyRewinder returns a function which rewinds the body before sending the request again.
+// Returns nil if the body can not be rewound.
+func (x *RequestBuilder) bodyRewinder() func() error {
+	noop := func() error { return nil }
+
+	switch v := x.body.(type) {
+	case nil, string, []byte, url.Values:
+		return noop
+
+	case *multipartBody:
+		for _, part := range v.parts {
+			if _, ok := part.content.(string); !ok {
+				return nil
+			}
+		}
+		return noop
+
+	case io.Reader:
+		seeker, ok := v.(io.Seeker)
+		if !ok {
+			return nil
+		}
+
+		// The http.Client closes the body if it is an io.Closer, it can not be reused.
+		if _, ok := v.(io.Closer); ok {
+			return nil
+		}
+
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil
+		}
+
+		return func() error {
+			_, err := seeker.Seek(offset, io.SeekStart)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseRetryAfter parses the value of the Retry-After header, which is either delay-seconds or an HTTP-date.
+// Returns false if the value is empty or invalid.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	d := t.Sub(now)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// sleepContext waits for the duration or until the context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+var (
+	randMu     sync.Mutex
+	randSource = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+func randFloat64() float64 {
+	randMu.Lock()
+	defer randMu.Unlock()
+	return randSource.Float64()
+}
@@ -0,0 +1,276 @@
+package httplib_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/stretchr/testify/assert"
+)
+
+// SequenceServer responds with the given handlers one by one, the last handler is used for the rest requests.
+type SequenceServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	Bodies []string // The bodies of all requests.
+}
+
+func NewSequenceServer(handlers ...http.HandlerFunc) *SequenceServer {
+	s := &SequenceServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		s.mu.Lock()
+		n := len(s.Bodies)
+		s.Bodies = append(s.Bodies, string(body))
+		s.mu.Unlock()
+
+		if n >= len(handlers) {
+			n = len(handlers) - 1
+		}
+		handlers[n](w, r)
+	}))
+	return s
+}
+
+func (s *SequenceServer) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.Bodies)
+}
+
+func statusHandler(status int, header ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < len(header); i += 2 {
+			w.Header().Set(header[i], header[i+1])
+		}
+		w.WriteHeader(status)
+		w.Write(DefaultBody)
+	}
+}
+
+func closeConnHandler(w http.ResponseWriter, r *http.Request) {
+	conn, _, _ := w.(http.Hijacker).Hijack()
+	conn.Close()
+}
+
+func fastRetry(maxAttempts int) *httplib.RetryPolicy {
+	p := httplib.NewRetryPolicy(maxAttempts)
+	p.InitialBackoff = time.Millisecond
+	return p
+}
+
+func TestRequestBuilder_WithRetry(t *testing.T) {
+	t.Run("status", func(t *testing.T) {
+		s := NewSequenceServer(statusHandler(503), statusHandler(502), statusHandler(200))
+		defer s.Close()
+
+		content, err := httplib.NewBuilder("GET", s.URL).WithRetry(fastRetry(3)).ReadString()
+		assert.NoError(t, err)
+		assert.Equal(t, string(DefaultBody), content)
+		assert.Equal(t, 3, s.Count())
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		s := NewSequenceServer(statusHandler(503))
+		defer s.Close()
+
+		_, err := httplib.NewBuilder("GET", s.URL).WithRetry(fastRetry(3)).ReadString()
+		assert.True(t, httplib.IsStatus(err, 503), "%v", err)
+		assert.Equal(t, 3, s.Count())
+	})
+
+	t.Run("not-retryable-status", func(t *testing.T) {
+		s := NewSequenceServer(statusHandler(500), statusHandler(200))
+		defer s.Close()
+
+		_, err := httplib.NewBuilder("GET", s.URL).WithRetry(fastRetry(3)).ReadString()
+		assert.True(t, httplib.IsStatus(err, 500), "%v", err)
+		assert.Equal(t, 1, s.Count())
+	})
+
+	t.Run("network-error", func(t *testing.T) {
+		s := NewSequenceServer(closeConnHandler, statusHandler(200))
+		defer s.Close()
+
+		content, err := httplib.NewBuilder("GET", s.URL).WithRetry(fastRetry(2)).ReadString()
+		assert.NoError(t, err)
+		assert.Equal(t, string(DefaultBody), content)
+		assert.Equal(t, 2, s.Count())
+	})
+
+	t.Run("network-error-disabled", func(t *testing.T) {
+		s := NewSequenceServer(closeConnHandler, statusHandler(200))
+		defer s.Close()
+
+		p := fastRetry(2)
+		p.RetryableError = nil
+		_, err := httplib.NewBuilder("GET", s.URL).WithRetry(p).ReadString()
+		assert.Error(t, err)
+		assert.Equal(t, 1, s.Count())
+	})
+
+	t.Run("non-idempotent", func(t *testing.T) {
+		s := NewSequenceServer(statusHandler(503), statusHandler(200))
+		defer s.Close()
+
+		_, err := httplib.NewBuilder("POST", s.URL).WithRetry(fastRetry(3)).ReadString()
+		assert.True(t, httplib.IsStatus(err, 503), "%v", err)
+		assert.Equal(t, 1, s.Count())
+	})
+
+	t.Run("idempotency-key", func(t *testing.T) {
+		s := NewSequenceServer(statusHandler(503), statusHandler(200))
+		defer s.Close()
+
+		_, err := httplib.NewBuilder("POST", s.URL).WithHeader("Idempotency-Key", "k").WithRetry(fastRetry(3)).ReadString()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, s.Count())
+	})
+
+	t.Run("rewind", func(t *testing.T) {
+		cases := []struct {
+			want  string
+			setup func(b *httplib.RequestBuilder)
+		}{
+			{"body", func(b *httplib.RequestBuilder) { b.SetStringBody("body") }},
+			{"bytes", func(b *httplib.RequestBuilder) { b.SetBinaryBody([]byte("bytes")) }},
+			{"a=1", func(b *httplib.RequestBuilder) { b.WithForm("a", 1) }},
+			{`{"a":1}`, func(b *httplib.RequestBuilder) { b.SetJSONBody(map[string]int{"a": 1}) }},
+			{"ader", func(b *httplib.RequestBuilder) { b.SetReaderBody(seekedReader("reader", 2)) }},
+		}
+
+		p := fastRetry(3)
+		p.RetryNonIdempotent = true
+
+		for _, c := range cases {
+			s := NewSequenceServer(statusHandler(503), statusHandler(503), statusHandler(200))
+
+			b := httplib.NewBuilder("POST", s.URL).WithRetry(p)
+			c.setup(b)
+			_, err := b.ReadString()
+			s.Close()
+
+			assert.NoError(t, err, c.want)
+			assert.Equal(t, []string{c.want, c.want, c.want}, s.Bodies, c.want)
+		}
+	})
+
+	t.Run("not-rewindable", func(t *testing.T) {
+		s := NewSequenceServer(statusHandler(503), statusHandler(200))
+		defer s.Close()
+
+		p := fastRetry(3)
+		p.RetryNonIdempotent = true
+		body := io.MultiReader(strings.NewReader("body"))
+		_, err := httplib.NewBuilder("POST", s.URL).SetReaderBody(body).WithRetry(p).ReadString()
+		assert.True(t, httplib.IsStatus(err, 503), "%v", err)
+		assert.Equal(t, 1, s.Count())
+	})
+
+	t.Run("retry-after", func(t *testing.T) {
+		cases := []struct {
+			name       string
+			retryAfter string
+			maxRetry   time.Duration
+			backoff    time.Duration
+			count      int
+		}{
+			// The backoff is long, the test timeouts if the Retry-After header is not used.
+			{"seconds", "0", 0, time.Hour, 2},
+			{"seconds-too-long", "3600", time.Second, time.Hour, 1},
+			{"date-past", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, time.Hour, 2},
+			{"date-too-long", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), time.Second, time.Hour, 1},
+			{"invalid", "soon", 0, time.Millisecond, 2},
+		}
+
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				s := NewSequenceServer(statusHandler(429, "Retry-After", c.retryAfter), statusHandler(200))
+				defer s.Close()
+
+				p := fastRetry(2)
+				p.InitialBackoff = c.backoff
+				p.MaxRetryAfter = c.maxRetry
+
+				res, err := httplib.NewBuilder("GET", s.URL).WithRetry(p).Do()
+				assert.NoError(t, err)
+				res.Body.Close()
+				assert.Equal(t, c.count, s.Count())
+			})
+		}
+	})
+
+	t.Run("cancel-while-waiting", func(t *testing.T) {
+		s := NewSequenceServer(statusHandler(503))
+		defer s.Close()
+
+		p := fastRetry(3)
+		p.InitialBackoff = time.Hour
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err := httplib.NewBuilder("GET", s.URL).WithRetry(p).ReadStringContext(ctx)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+		assert.Equal(t, 1, s.Count())
+	})
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := httplib.NewRetryPolicy(5)
+	p.InitialBackoff = 10 * time.Millisecond
+	p.MaxBackoff = 50 * time.Millisecond
+	p.Jitter = 0
+
+	assert.Equal(t, 10*time.Millisecond, p.Backoff(1))
+	assert.Equal(t, 20*time.Millisecond, p.Backoff(2))
+	assert.Equal(t, 40*time.Millisecond, p.Backoff(3))
+	assert.Equal(t, 50*time.Millisecond, p.Backoff(4))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.Backoff(2)
+		assert.GreaterOrEqual(t, d, 10*time.Millisecond)
+		assert.LessOrEqual(t, d, 30*time.Millisecond)
+	}
+
+	// Without MaxBackoff, the delay does not overflow.
+	p.MaxBackoff = 0
+	p.Jitter = 0
+	assert.Equal(t, time.Duration(math.MaxInt64), p.Backoff(100))
+	assert.Equal(t, time.Duration(math.MaxInt64), p.Backoff(10000))
+
+	p.InitialBackoff = 0
+	assert.Equal(t, time.Duration(0), p.Backoff(10000))
+}
+
+func TestIsRetryableError(t *testing.T) {
+	assert := assert.New(t)
+	assert.False(httplib.IsRetryableError(nil))
+	assert.False(httplib.IsRetryableError(errors.New("x")))
+	assert.False(httplib.IsRetryableError(context.Canceled))
+	assert.False(httplib.IsRetryableError(context.DeadlineExceeded))
+	assert.True(httplib.IsRetryableError(io.ErrUnexpectedEOF))
+	assert.True(httplib.IsRetryableError(&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}))
+	assert.True(httplib.IsRetryableError(&net.OpError{Op: "read", Err: syscall.ECONNRESET}))
+	assert.True(httplib.IsRetryableError(&net.DNSError{IsTimeout: true}))
+	assert.False(httplib.IsRetryableError(&net.DNSError{IsNotFound: true}))
+}
+
+func seekedReader(s string, offset int64) *strings.Reader {
+	r := strings.NewReader(s)
+	r.Seek(offset, io.SeekStart)
+	return r
+}
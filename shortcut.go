@@ -1,7 +1,16 @@
 package httplib
 
+import "context"
+
 /*
 Shortcut to send HTTP requests.
+
+The requests are sent through DefaultClient. Only 200 OK is accepted by default, this can be changed
+by giving an option to DefaultClient, e.g.
+
+	httplib.DefaultClient.WithOption(func(b *httplib.RequestBuilder) {
+		b.ExpectStatusRange(200, 299)
+	})
 */
 
 /* ==== GET ==== */
@@ -46,6 +55,30 @@ func GetBinaryWithHeaders(uri string, headers map[string]any) ([]byte, error) {
 	return req.ReadBinary()
 }
 
+// GetContext is the same as Get(), but sends the request with the given context.
+func GetContext(ctx context.Context, uri string) (string, error) {
+	req := NewBuilder("GET", uri)
+	return req.ReadStringContext(ctx)
+}
+
+// GetWithHeadersContext is the same as GetWithHeaders(), but sends the request with the given context.
+func GetWithHeadersContext(ctx context.Context, uri string, headers map[string]any) (string, error) {
+	req := NewBuilder("GET", uri).WithHeaders(headers)
+	return req.ReadStringContext(ctx)
+}
+
+// GetBinaryContext is the same as GetBinary(), but sends the request with the given context.
+func GetBinaryContext(ctx context.Context, uri string) ([]byte, error) {
+	req := NewBuilder("GET", uri)
+	return req.ReadBinaryContext(ctx)
+}
+
+// GetBinaryWithHeadersContext is the same as GetBinaryWithHeaders(), but sends the request with the given context.
+func GetBinaryWithHeadersContext(ctx context.Context, uri string, headers map[string]any) ([]byte, error) {
+	req := NewBuilder("GET", uri).WithHeaders(headers)
+	return req.ReadBinaryContext(ctx)
+}
+
 // MustGet sends a GET request.
 // If the status code of the response is 200 OK, returns the whole response body as a string.
 // Panics if the status code is not 200 OK.
@@ -128,6 +161,30 @@ func PostBinaryWithHeaders(uri string, body []byte, headers map[string]any) ([]b
 	return req.ReadBinary()
 }
 
+// PostContext is the same as Post(), but sends the request with the given context.
+func PostContext(ctx context.Context, uri string, body string) (string, error) {
+	req := NewBuilder("POST", uri).SetStringBody(body)
+	return req.ReadStringContext(ctx)
+}
+
+// PostWithHeadersContext is the same as PostWithHeaders(), but sends the request with the given context.
+func PostWithHeadersContext(ctx context.Context, uri string, body string, headers map[string]any) (string, error) {
+	req := NewBuilder("POST", uri).WithHeaders(headers).SetStringBody(body)
+	return req.ReadStringContext(ctx)
+}
+
+// PostBinaryContext is the same as PostBinary(), but sends the request with the given context.
+func PostBinaryContext(ctx context.Context, uri string, body []byte) ([]byte, error) {
+	req := NewBuilder("POST", uri).SetBinaryBody(body)
+	return req.ReadBinaryContext(ctx)
+}
+
+// PostBinaryWithHeadersContext is the same as PostBinaryWithHeaders(), but sends the request with the given context.
+func PostBinaryWithHeadersContext(ctx context.Context, uri string, body []byte, headers map[string]any) ([]byte, error) {
+	req := NewBuilder("POST", uri).WithHeaders(headers).SetBinaryBody(body)
+	return req.ReadBinaryContext(ctx)
+}
+
 // MustPost sends a POST request.
 // If the status code of the response is 200 OK, returns the whole response body as a string.
 // Panics if the status code is not 200 OK.
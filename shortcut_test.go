@@ -1,6 +1,8 @@
 package httplib_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"testing"
@@ -326,3 +328,56 @@ func TestMustPostBinaryWithHeaders(t *testing.T) {
 	})
 	assert.Fail("should panic")
 }
+
+func TestShortcutContext(t *testing.T) {
+	headers := map[string]any{"X-Header1": "v1"}
+	body := []byte("request body")
+
+	t.Run("ok", func(t *testing.T) {
+		s := NewTestServer(http.StatusOK, DefaultBody)
+		defer s.Close()
+
+		ctx := context.Background()
+		callers := map[string]func() (string, error){
+			"GetContext":            func() (string, error) { return httplib.GetContext(ctx, s.URL) },
+			"GetWithHeadersContext": func() (string, error) { return httplib.GetWithHeadersContext(ctx, s.URL, headers) },
+			"GetBinaryContext": func() (string, error) {
+				res, err := httplib.GetBinaryContext(ctx, s.URL)
+				return string(res), err
+			},
+			"GetBinaryWithHeadersContext": func() (string, error) {
+				res, err := httplib.GetBinaryWithHeadersContext(ctx, s.URL, headers)
+				return string(res), err
+			},
+			"PostContext": func() (string, error) { return httplib.PostContext(ctx, s.URL, string(body)) },
+			"PostWithHeadersContext": func() (string, error) {
+				return httplib.PostWithHeadersContext(ctx, s.URL, string(body), headers)
+			},
+			"PostBinaryContext": func() (string, error) {
+				res, err := httplib.PostBinaryContext(ctx, s.URL, body)
+				return string(res), err
+			},
+			"PostBinaryWithHeadersContext": func() (string, error) {
+				res, err := httplib.PostBinaryWithHeadersContext(ctx, s.URL, body, headers)
+				return string(res), err
+			},
+		}
+
+		for name, caller := range callers {
+			content, err := caller()
+			assert.NoError(t, err, name)
+			assert.Equal(t, string(DefaultBody), content, name)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		s, started := NewBlockingServer()
+		defer s.Close()
+
+		_, err := httplib.GetContext(CancelOnStart(started), s.URL)
+		assert.True(t, errors.Is(err, context.Canceled), "%v", err)
+
+		_, err = httplib.PostBinaryWithHeadersContext(CancelOnStart(started), s.URL, body, headers)
+		assert.True(t, errors.Is(err, context.Canceled), "%v", err)
+	})
+}
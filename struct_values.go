@@ -0,0 +1,376 @@
+package httplib
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArrayStyle specifies how a slice or an array is encoded by WithQueryStruct() and WithFormStruct().
+type ArrayStyle int
+
+const (
+	// ArrayRepeat repeats the name for each element: a=1&a=2 . It is the default style.
+	ArrayRepeat ArrayStyle = iota
+
+	// ArrayComma joins the elements with commas: a=1,2 .
+	ArrayComma
+
+	// ArrayBrackets appends '[]' to the name: a[]=1&a[]=2 .
+	ArrayBrackets
+
+	// ArrayIndexed appends the index to the name: a[0]=1&a[1]=2 .
+	ArrayIndexed
+)
+
+// StructOption is used to setup the encoding of WithQueryStruct(), WithFormStruct() and EncodeStruct().
+type StructOption func(e *structEncoder)
+
+// StructArrayStyle sets the style of the slices and arrays which do not specify a style in the tag.
+func StructArrayStyle(style ArrayStyle) StructOption {
+	return func(e *structEncoder) {
+		e.arrayStyle = style
+	}
+}
+
+// StructTimeLayout sets the layout of the time.Time values which do not specify a layout in the tag.
+// The default layout is time.RFC3339.
+func StructTimeLayout(layout string) StructOption {
+	return func(e *structEncoder) {
+		e.timeLayout = layout
+	}
+}
+
+// WithQueryStruct appends the fields of a struct to the query strings, see EncodeStruct() for the details,
+// the tag name is 'query'.
+//
+// If the value can not be encoded, the error is returned by Build() and the methods which send the request.
+func (x *RequestBuilder) WithQueryStruct(v any, options ...StructOption) *RequestBuilder {
+	values, err := EncodeStruct(v, "query", options...)
+	if err != nil {
+		x.setErr(err)
+		return x
+	}
+
+	for k, list := range values {
+		x.query[k] = append(x.query[k], list...)
+	}
+	return x
+}
+
+// WithFormStruct appends the fields of a struct to the form, see EncodeStruct() for the details,
+// the tag name is 'form'. It sets the header Content-Type to 'application/x-www-form-urlencoded'.
+//
+// If the current body set is not a form, it will be replaced.
+//
+// If the value can not be encoded, the error is returned by Build() and the methods which send the request.
+func (x *RequestBuilder) WithFormStruct(v any, options ...StructOption) *RequestBuilder {
+	q := x.ensureForm()
+
+	values, err := EncodeStruct(v, "form", options...)
+	if err != nil {
+		x.setErr(err)
+		return x
+	}
+
+	for k, list := range values {
+		q[k] = append(q[k], list...)
+	}
+	return x
+}
+
+// EncodeStruct encodes the exported fields of a struct into url.Values, v must be a struct or a pointer to a struct,
+// a nil pointer results in empty values. The fields are configured by the tag with the given name, such as:
+//
+//	type Params struct {
+//	    Name    string    `query:"name"`                // name=value
+//	    Page    int       `query:"page,omitempty"`      // Omitted if it is zero.
+//	    IDs     []int     `query:"id,comma"`            // id=1,2,3
+//	    Since   time.Time `query:"since" layout:"2006-01-02"`
+//	    Ignored string    `query:"-"`
+//	    Common                                         // Fields of embedded structs are promoted.
+//	}
+//
+// The name defaults to the field name. The options after the name are:
+//   - omitempty: omits the field if it has the zero value, or it is an empty slice.
+//   - repeat, comma, brackets, indexed: the ArrayStyle of a slice or an array.
+//
+// The supported field types are:
+//   - string, bool, integers and floats.
+//   - time.Time, formatted with the layout given by the 'layout' tag, StructTimeLayout() or time.RFC3339 .
+//     The layout can be 'unix' or 'unixmilli' for Unix timestamps.
+//   - encoding.TextMarshaler and fmt.Stringer.
+//   - Pointers of the above types, a nil pointer is omitted.
+//   - Slices and arrays of the above types; []byte is encoded as a string.
+//
+// Returns an error if there is an unsupported field type.
+func EncodeStruct(v any, tag string, options ...StructOption) (url.Values, error) {
+	e := &structEncoder{
+		tag:        tag,
+		arrayStyle: ArrayRepeat,
+		timeLayout: time.RFC3339,
+		values:     make(url.Values),
+	}
+
+	for _, opt := range options {
+		opt(e)
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return e.values, nil
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("httplib: encode struct: %T is not a struct", v)
+	}
+
+	if err := e.encodeStruct(rv); err != nil {
+		return nil, err
+	}
+	return e.values, nil
+}
+
+type structEncoder struct {
+	tag        string
+	arrayStyle ArrayStyle
+	timeLayout string
+	values     url.Values
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+func (e *structEncoder) encodeStruct(rv reflect.Value) error {
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tagValue, hasTag := field.Tag.Lookup(e.tag)
+		if tagValue == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tagValue, ",")
+		fv := rv.Field(i)
+
+		// Promote the fields of an embedded struct if it is not named by the tag.
+		if field.Anonymous && name == "" && !isScalarType(field.Type) {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				if err := e.encodeStruct(fv); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		f := structField{
+			name:       name,
+			arrayStyle: e.arrayStyle,
+			timeLayout: e.timeLayout,
+		}
+		if layout := field.Tag.Get("layout"); layout != "" {
+			f.timeLayout = layout
+		}
+
+		if hasTag {
+			if err := f.parseOptions(opts); err != nil {
+				return fmt.Errorf("httplib: encode struct: field %s: %w", field.Name, err)
+			}
+		}
+
+		if err := e.encodeField(f, fv); err != nil {
+			return fmt.Errorf("httplib: encode struct: field %s: %w", field.Name, err)
+		}
+	}
+
+	return nil
+}
+
+type structField struct {
+	name       string
+	omitEmpty  bool
+	arrayStyle ArrayStyle
+	timeLayout string
+}
+
+func (f *structField) parseOptions(opts string) error {
+	for _, opt := range strings.Split(opts, ",") {
+		switch strings.TrimSpace(opt) {
+		case "":
+		case "omitempty":
+			f.omitEmpty = true
+		case "repeat":
+			f.arrayStyle = ArrayRepeat
+		case "comma":
+			f.arrayStyle = ArrayComma
+		case "brackets":
+			f.arrayStyle = ArrayBrackets
+		case "indexed":
+			f.arrayStyle = ArrayIndexed
+		default:
+			return fmt.Errorf("unknown tag option %q", opt)
+		}
+	}
+	return nil
+}
+
+func (e *structEncoder) encodeField(f structField, fv reflect.Value) error {
+	fv, ok := deref(fv)
+	if !ok {
+		return nil
+	}
+
+	if f.omitEmpty && fv.IsZero() {
+		return nil
+	}
+
+	isList := (fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array) && !isScalarType(fv.Type())
+	if !isList {
+		s, err := formatScalar(fv, f.timeLayout)
+		if err != nil {
+			return err
+		}
+		e.values.Add(f.name, s)
+		return nil
+	}
+
+	list := make([]string, 0, fv.Len())
+	for i := 0; i < fv.Len(); i++ {
+		item, ok := deref(fv.Index(i))
+		if !ok {
+			continue // Nil elements are omitted.
+		}
+
+		s, err := formatScalar(item, f.timeLayout)
+		if err != nil {
+			return err
+		}
+		list = append(list, s)
+	}
+
+	if len(list) == 0 {
+		return nil
+	}
+
+	switch f.arrayStyle {
+	case ArrayComma:
+		e.values.Add(f.name, strings.Join(list, ","))
+	case ArrayBrackets:
+		e.values[f.name+"[]"] = append(e.values[f.name+"[]"], list...)
+	case ArrayIndexed:
+		for i, s := range list {
+			e.values.Add(f.name+"["+strconv.Itoa(i)+"]", s)
+		}
+	default:
+		e.values[f.name] = append(e.values[f.name], list...)
+	}
+
+	return nil
+}
+
+// deref returns the value which the pointers or the interfaces point to. ok is false if there is a nil.
+func deref(v reflect.Value) (_ reflect.Value, ok bool) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, true
+}
+
+// isScalarType returns true if the type is encoded as a single value, even if it is a slice or a struct.
+func isScalarType(t reflect.Type) bool {
+	if t == timeType || t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		return true
+	}
+
+	if t.Implements(stringerType) || reflect.PointerTo(t).Implements(stringerType) {
+		return true
+	}
+
+	// []byte
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+func formatScalar(v reflect.Value, timeLayout string) (string, error) {
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		switch timeLayout {
+		case "unix":
+			return strconv.FormatInt(t.Unix(), 10), nil
+		case "unixmilli":
+			return strconv.FormatInt(t.UnixMilli(), 10), nil
+		default:
+			return t.Format(timeLayout), nil
+		}
+	}
+
+	// The methods may be defined on the pointer, make the value addressable.
+	if !v.CanAddr() {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		v = p.Elem()
+	}
+
+	if m, ok := v.Addr().Interface().(encoding.TextMarshaler); ok {
+		data, err := m.MarshalText()
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+
+	if s, ok := v.Addr().Interface().(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
@@ -0,0 +1,242 @@
+package httplib_test
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type structTestCommon struct {
+	Token string `query:"token" form:"token"`
+}
+
+type structTestPaging struct {
+	Page int `query:"page,omitempty"`
+	Size int `query:"size,omitempty"`
+}
+
+type structTestLevel int
+
+func (v structTestLevel) String() string {
+	return [...]string{"low", "high"}[v]
+}
+
+type structTestMarshaler struct {
+	fail bool
+}
+
+func (v *structTestMarshaler) MarshalText() ([]byte, error) {
+	if v.fail {
+		return nil, errors.New("marshal failed")
+	}
+	return []byte("marshaled"), nil
+}
+
+type structTestParams struct {
+	structTestCommon
+	*structTestPaging
+
+	Name      string              `query:"name"`
+	Ignored   string              `query:"-"`
+	Empty     string              `query:"empty"`
+	Omitted   string              `query:"omitted,omitempty"`
+	Bool      bool                `query:"bool"`
+	Int       int64               `query:"int"`
+	Uint      uint8               `query:"uint"`
+	Float     float64             `query:"float"`
+	Float32   float32             `query:"float32"`
+	Ptr       *int                `query:"ptr"`
+	NilPtr    *int                `query:"nil_ptr"`
+	Bytes     []byte              `query:"bytes"`
+	Repeat    []int               `query:"repeat"`
+	Comma     []string            `query:"comma,comma"`
+	Brackets  []*int              `query:"brackets,brackets"`
+	Indexed   [2]int              `query:"indexed,indexed"`
+	EmptyList []int               `query:"empty_list"`
+	Time      time.Time           `query:"time"`
+	Date      *time.Time          `query:"date" layout:"2006-01-02"`
+	Unix      time.Time           `query:"unix" layout:"unix"`
+	Zero      time.Time           `query:"zero,omitempty"`
+	IP        net.IP              `query:"ip"`
+	Marshaler structTestMarshaler `query:"marshaler"`
+	Level     structTestLevel     `query:"level"`
+	Any       any                 `query:"any"`
+
+	Untagged string
+	private  string
+}
+
+func TestEncodeStruct(t *testing.T) {
+	n := 3
+	tm := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	params := structTestParams{
+		structTestCommon: structTestCommon{"tk"},
+		Name:             "n",
+		Untagged:         "u",
+		Ignored:          "i",
+		private:          "p",
+		Bool:             true,
+		Int:              -1,
+		Uint:             2,
+		Float:            1.5,
+		Float32:          0.1,
+		Ptr:              &n,
+		Bytes:            []byte("bb"),
+		Repeat:           []int{1, 2},
+		Comma:            []string{"a", "b"},
+		Brackets:         []*int{&n, nil, &n},
+		Indexed:          [2]int{5, 6},
+		EmptyList:        []int{},
+		Time:             tm,
+		Date:             &tm,
+		Unix:             tm,
+		IP:               net.IPv4(127, 0, 0, 1),
+		Level:            1,
+		Any:              "any",
+	}
+
+	values, err := httplib.EncodeStruct(&params, "query")
+	require.NoError(t, err)
+	assert.Equal(t, url.Values{
+		"token":      {"tk"},
+		"name":       {"n"},
+		"Untagged":   {"u"},
+		"empty":      {""},
+		"bool":       {"true"},
+		"int":        {"-1"},
+		"uint":       {"2"},
+		"float":      {"1.5"},
+		"float32":    {"0.1"},
+		"ptr":        {"3"},
+		"bytes":      {"bb"},
+		"repeat":     {"1", "2"},
+		"comma":      {"a,b"},
+		"brackets[]": {"3", "3"},
+		"indexed[0]": {"5"},
+		"indexed[1]": {"6"},
+		"time":       {"2024-05-06T07:08:09Z"},
+		"date":       {"2024-05-06"},
+		"unix":       {"1714979289"},
+		"ip":         {"127.0.0.1"},
+		"marshaler":  {"marshaled"},
+		"level":      {"high"},
+		"any":        {"any"},
+	}, values)
+
+	// Embedded pointer.
+	values, err = httplib.EncodeStruct(structTestParams{structTestPaging: &structTestPaging{Page: 2}}, "query")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2"}, values["page"])
+	assert.NotContains(t, values, "size")
+
+	// The field names are used without the tag.
+	values, err = httplib.EncodeStruct(structTestCommon{"tk"}, "other")
+	require.NoError(t, err)
+	assert.Equal(t, url.Values{"Token": {"tk"}}, values)
+
+	// Nil.
+	values, err = httplib.EncodeStruct((*structTestCommon)(nil), "query")
+	require.NoError(t, err)
+	assert.Empty(t, values)
+}
+
+func TestEncodeStruct_Options(t *testing.T) {
+	type params struct {
+		A    []int     `query:"a"`
+		B    []int     `query:"b,repeat"`
+		Time time.Time `query:"time"`
+	}
+
+	v := params{[]int{1, 2}, []int{3, 4}, time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)}
+	cases := []struct {
+		style httplib.ArrayStyle
+		want  url.Values
+	}{
+		{httplib.ArrayRepeat, url.Values{"a": {"1", "2"}}},
+		{httplib.ArrayComma, url.Values{"a": {"1,2"}}},
+		{httplib.ArrayBrackets, url.Values{"a[]": {"1", "2"}}},
+		{httplib.ArrayIndexed, url.Values{"a[0]": {"1"}, "a[1]": {"2"}}},
+	}
+
+	for _, c := range cases {
+		values, err := httplib.EncodeStruct(v, "query", httplib.StructArrayStyle(c.style), httplib.StructTimeLayout("unixmilli"))
+		require.NoError(t, err)
+
+		c.want["b"] = []string{"3", "4"}
+		c.want["time"] = []string{"1714979289000"}
+		assert.Equal(t, c.want, values)
+	}
+}
+
+func TestEncodeStruct_Error(t *testing.T) {
+	_, err := httplib.EncodeStruct(1, "query")
+	assert.EqualError(t, err, "httplib: encode struct: int is not a struct")
+
+	_, err = httplib.EncodeStruct(struct {
+		M map[string]int `query:"m"`
+	}{}, "query")
+	assert.EqualError(t, err, "httplib: encode struct: field M: unsupported type map[string]int")
+
+	_, err = httplib.EncodeStruct(struct {
+		L []struct{} `query:"l"`
+	}{L: []struct{}{{}}}, "query")
+	assert.EqualError(t, err, "httplib: encode struct: field L: unsupported type struct {}")
+
+	_, err = httplib.EncodeStruct(struct {
+		A string `query:"a,bad"`
+	}{}, "query")
+	assert.EqualError(t, err, `httplib: encode struct: field A: unknown tag option "bad"`)
+
+	_, err = httplib.EncodeStruct(struct {
+		M structTestMarshaler `query:"m"`
+	}{structTestMarshaler{fail: true}}, "query")
+	assert.EqualError(t, err, "httplib: encode struct: field M: marshal failed")
+}
+
+func TestRequestBuilder_WithQueryStruct(t *testing.T) {
+	b := httplib.NewBuilder("GET", "http://temp.org/?a=1").
+		WithQuery("name", "first").
+		WithQueryStruct(structTestParams{Name: "n", Repeat: []int{1, 2}}, httplib.StructArrayStyle(httplib.ArrayComma))
+
+	u, err := url.Parse(b.URL())
+	require.NoError(t, err)
+	q := u.Query()
+	assert.Equal(t, []string{"first", "n"}, q["name"])
+	assert.Equal(t, []string{"1,2"}, q["repeat"])
+	assert.Equal(t, []string{"1"}, q["a"])
+
+	// Error.
+	_, err = httplib.NewBuilder("GET", "http://temp.org/").WithQueryStruct("bad").Build()
+	assert.Error(t, err)
+}
+
+func TestRequestBuilder_WithFormStruct(t *testing.T) {
+	s := NewTestServer(http.StatusOK, DefaultBody)
+	defer s.Close()
+
+	type form struct {
+		structTestCommon
+		IDs []int `form:"id,brackets"`
+		Msg string
+	}
+
+	_, err := httplib.NewBuilder("POST", s.URL).
+		WithForm("a", 1).
+		WithFormStruct(&form{structTestCommon{"tk"}, []int{1, 2}, "中"}).
+		ReadBinary()
+	require.NoError(t, err)
+	assert.Equal(t, "application/x-www-form-urlencoded", s.Request.Header.Get("Content-Type"))
+	assert.Equal(t, "Msg=%E4%B8%AD&a=1&id%5B%5D=1&id%5B%5D=2&token=tk", string(s.Body))
+
+	// Error.
+	_, err = httplib.NewBuilder("POST", s.URL).WithFormStruct(nil).Build()
+	assert.Error(t, err)
+}
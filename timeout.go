@@ -0,0 +1,260 @@
+package httplib
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// TimeoutPhase names the phase of an HTTP exchange which a timeout applies to.
+type TimeoutPhase string
+
+const (
+	// PhaseTotal is the whole exchange, from sending the request to reading the end of the response body.
+	PhaseTotal TimeoutPhase = "total"
+
+	// PhaseConnect is from starting to get a connection, includes DNS lookup, to the connection established.
+	PhaseConnect TimeoutPhase = "connect"
+
+	// PhaseTLSHandshake is the TLS handshake.
+	PhaseTLSHandshake TimeoutPhase = "TLS handshake"
+
+	// PhaseResponseHeader is from the request written to the first byte of the response received.
+	PhaseResponseHeader TimeoutPhase = "response header"
+
+	// PhaseBodyRead is from the response header received to reading the end of the response body.
+	PhaseBodyRead TimeoutPhase = "body read"
+)
+
+// TimeoutError is returned when a timeout set on a RequestBuilder is exceeded.
+//
+// errors.Is(err, context.DeadlineExceeded) returns true for a TimeoutError.
+type TimeoutError struct {
+	// Phase is the phase which timed out.
+	Phase TimeoutPhase
+
+	// Duration is the timeout of the phase.
+	Duration time.Duration
+
+	// Err is the error caused by the timeout.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timeout (%s) exceeded: %v", e.Phase, e.Duration, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout returns true, it implements net.Error .
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// Temporary returns false, it implements net.Error .
+func (e *TimeoutError) Temporary() bool {
+	return false
+}
+
+// Is returns true if the target is context.DeadlineExceeded .
+func (e *TimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// timeouts holds the timeouts of each phase, zero means no timeout.
+type timeouts map[TimeoutPhase]time.Duration
+
+// WithTimeout sets the timeout of the whole exchange, from sending the request to reading the end of the response body.
+// Zero means no timeout.
+//
+// The timeouts set on the RequestBuilder work with the ones of the http.Client, whichever is shorter takes effect.
+// Once a timeout is exceeded, the request is canceled and a *TimeoutError is returned, it is not retried
+// by the RetryPolicy. If the response is returned by Do(), the timeouts continue to apply while reading
+// the body, the body must be closed to release the resources.
+func (x *RequestBuilder) WithTimeout(d time.Duration) *RequestBuilder {
+	return x.setTimeout(PhaseTotal, d)
+}
+
+// WithConnectTimeout sets the timeout of establishing a connection, includes DNS lookup. Zero means no timeout.
+// See WithTimeout() for details.
+func (x *RequestBuilder) WithConnectTimeout(d time.Duration) *RequestBuilder {
+	return x.setTimeout(PhaseConnect, d)
+}
+
+// WithTLSHandshakeTimeout sets the timeout of the TLS handshake. Zero means no timeout.
+// See WithTimeout() for details.
+func (x *RequestBuilder) WithTLSHandshakeTimeout(d time.Duration) *RequestBuilder {
+	return x.setTimeout(PhaseTLSHandshake, d)
+}
+
+// WithResponseHeaderTimeout sets the timeout of waiting for the response after the request is written.
+// Zero means no timeout. See WithTimeout() for details.
+func (x *RequestBuilder) WithResponseHeaderTimeout(d time.Duration) *RequestBuilder {
+	return x.setTimeout(PhaseResponseHeader, d)
+}
+
+// WithBodyReadTimeout sets the timeout of reading the response body, it starts when the response is received.
+// Zero means no timeout. See WithTimeout() for details.
+func (x *RequestBuilder) WithBodyReadTimeout(d time.Duration) *RequestBuilder {
+	return x.setTimeout(PhaseBodyRead, d)
+}
+
+func (x *RequestBuilder) setTimeout(phase TimeoutPhase, d time.Duration) *RequestBuilder {
+	if d <= 0 {
+		delete(x.timeouts, phase)
+		return x
+	}
+
+	if x.timeouts == nil {
+		x.timeouts = make(timeouts)
+	}
+	x.timeouts[phase] = d
+	return x
+}
+
+// do calls fn with a context which is canceled when a timeout is exceeded.
+func (t timeouts) do(ctx context.Context, fn func(ctx context.Context) (*http.Response, error)) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &timeoutWatcher{
+		timeouts: t,
+		cancel:   cancel,
+		timers:   make(map[TimeoutPhase]*time.Timer),
+	}
+
+	w.start(PhaseTotal)
+	ctx = httptrace.WithClientTrace(ctx, w.clientTrace())
+
+	res, err := fn(ctx)
+	if err != nil {
+		w.close()
+		return nil, w.wrap(err)
+	}
+
+	w.start(PhaseBodyRead)
+	res.Body = &timeoutBody{res.Body, w}
+	return res, nil
+}
+
+// timeoutWatcher starts and stops the timers of the phases, cancels the context when a timer fires.
+type timeoutWatcher struct {
+	timeouts timeouts
+	cancel   context.CancelFunc
+
+	mu     sync.Mutex
+	timers map[TimeoutPhase]*time.Timer
+	fired  TimeoutPhase
+}
+
+func (w *timeoutWatcher) start(phase TimeoutPhase) {
+	d, ok := w.timeouts[phase]
+	if !ok {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.timers[phase]; ok {
+		return
+	}
+
+	w.timers[phase] = time.AfterFunc(d, func() {
+		w.mu.Lock()
+		if w.fired == "" {
+			w.fired = phase
+		}
+		w.mu.Unlock()
+		w.cancel()
+	})
+}
+
+func (w *timeoutWatcher) stop(phase TimeoutPhase) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if timer, ok := w.timers[phase]; ok {
+		timer.Stop()
+		delete(w.timers, phase)
+	}
+}
+
+func (w *timeoutWatcher) close() {
+	w.mu.Lock()
+	for _, timer := range w.timers {
+		timer.Stop()
+	}
+	w.mu.Unlock()
+	w.cancel()
+}
+
+// wrap returns a *TimeoutError if a timer fired; otherwise returns the error itself.
+func (w *timeoutWatcher) wrap(err error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.fired == "" {
+		return err
+	}
+	return &TimeoutError{
+		Phase:    w.fired,
+		Duration: w.timeouts[w.fired],
+		Err:      err,
+	}
+}
+
+func (w *timeoutWatcher) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			w.start(PhaseConnect)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			w.stop(PhaseConnect)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				w.stop(PhaseConnect)
+			}
+		},
+		TLSHandshakeStart: func() {
+			w.start(PhaseTLSHandshake)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			w.stop(PhaseTLSHandshake)
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			w.start(PhaseResponseHeader)
+		},
+		GotFirstResponseByte: func() {
+			w.stop(PhaseResponseHeader)
+		},
+	}
+}
+
+// timeoutBody wraps the response body, converts the errors caused by timeouts to *TimeoutError.
+type timeoutBody struct {
+	io.ReadCloser
+	w *timeoutWatcher
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = b.w.wrap(err)
+	}
+	return n, err
+}
+
+func (b *timeoutBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.w.close()
+	return err
+}
@@ -0,0 +1,150 @@
+package httplib_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assertTimeoutError(t *testing.T, err error, phase httplib.TimeoutPhase, d time.Duration) {
+	var e *httplib.TimeoutError
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, phase, e.Phase)
+	assert.Equal(t, d, e.Duration)
+	assert.True(t, e.Timeout())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Contains(t, err.Error(), string(phase)+" timeout")
+}
+
+// NewSilentListener creates a listener which accepts connections but never writes anything.
+func NewSilentListener(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(io.Discard, conn)
+		}
+	}()
+	return l
+}
+
+func TestRequestBuilder_WithTimeout(t *testing.T) {
+	const d = 50 * time.Millisecond
+
+	t.Run("ok", func(t *testing.T) {
+		s := NewTestServer(http.StatusOK, DefaultBody)
+		defer s.Close()
+
+		content, err := httplib.NewBuilder("GET", s.URL).
+			WithTimeout(time.Second).
+			WithConnectTimeout(time.Second).
+			WithResponseHeaderTimeout(time.Second).
+			WithBodyReadTimeout(time.Second).
+			ReadString()
+		assert.NoError(t, err)
+		assert.Equal(t, string(DefaultBody), content)
+	})
+
+	t.Run("total", func(t *testing.T) {
+		s, _ := NewBlockingServer()
+		defer s.Close()
+
+		_, err := httplib.NewBuilder("GET", s.URL).WithTimeout(d).ReadString()
+		assertTimeoutError(t, err, httplib.PhaseTotal, d)
+	})
+
+	t.Run("total-disabled", func(t *testing.T) {
+		s := NewTestServer(http.StatusOK, DefaultBody)
+		defer s.Close()
+
+		_, err := httplib.NewBuilder("GET", s.URL).WithTimeout(time.Nanosecond).WithTimeout(0).ReadString()
+		assert.NoError(t, err)
+	})
+
+	t.Run("connect", func(t *testing.T) {
+		hc := &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				},
+			},
+		}
+
+		c := httplib.NewClient("http://temp.org").WithHTTPClient(hc)
+		_, err := c.NewBuilder("GET", "").WithConnectTimeout(d).WithTimeout(time.Minute).ReadString()
+		assertTimeoutError(t, err, httplib.PhaseConnect, d)
+	})
+
+	t.Run("tls-handshake", func(t *testing.T) {
+		l := NewSilentListener(t)
+		defer l.Close()
+
+		_, err := httplib.NewBuilder("GET", "https://"+l.Addr().String()).
+			WithConnectTimeout(time.Minute).
+			WithTLSHandshakeTimeout(d).
+			ReadString()
+		assertTimeoutError(t, err, httplib.PhaseTLSHandshake, d)
+	})
+
+	t.Run("response-header", func(t *testing.T) {
+		s, _ := NewBlockingServer()
+		defer s.Close()
+
+		_, err := httplib.NewBuilder("GET", s.URL).
+			WithConnectTimeout(time.Minute).
+			WithResponseHeaderTimeout(d).
+			Do()
+		assertTimeoutError(t, err, httplib.PhaseResponseHeader, d)
+	})
+
+	t.Run("body-read", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer s.Close()
+
+		b := httplib.NewBuilder("GET", s.URL).
+			WithResponseHeaderTimeout(time.Minute).
+			WithBodyReadTimeout(d)
+
+		_, err := b.ReadString()
+		assertTimeoutError(t, err, httplib.PhaseBodyRead, d)
+
+		// The timeouts apply to the response returned by Do().
+		res, err := b.Do()
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		data, err := io.ReadAll(res.Body)
+		assert.Equal(t, "partial", string(data))
+		assertTimeoutError(t, err, httplib.PhaseBodyRead, d)
+	})
+
+	t.Run("not-retried", func(t *testing.T) {
+		s, _ := NewBlockingServer()
+		defer s.Close()
+
+		_, err := httplib.NewBuilder("GET", s.URL).
+			WithRetry(fastRetry(3)).
+			WithResponseHeaderTimeout(d).
+			ReadString()
+		assertTimeoutError(t, err, httplib.PhaseResponseHeader, d)
+	})
+}
@@ -0,0 +1,406 @@
+package httplib
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// NewBuilderTemplate creates a new instance of RequestBuilder with a URI template, the request is sent through DefaultClient.
+// See Client.NewBuilderTemplate() for the details.
+func NewBuilderTemplate(method string, template string) *RequestBuilder {
+	return DefaultClient.NewBuilderTemplate(method, template)
+}
+
+// NewBuilderTemplate creates a new instance of RequestBuilder with a URI template defined in [RFC 6570],
+// levels 1 to 4 are supported, e.g.
+//
+//	c.NewBuilderTemplate("GET", "/users/{id}/posts{?page,limit}").
+//	    WithPathParam("id", 12).
+//	    WithPathParam("page", 2)
+//	// The URL is '/users/12/posts?page=2'.
+//
+// The values of the variables are given by WithPathParam(). The template is resolved against the base URL
+// of the Client like NewBuilder().
+//
+// If the template is invalid, the error is returned by Build() and the methods which send the request.
+//
+// [RFC 6570]: https://datatracker.ietf.org/doc/html/rfc6570
+func (c *Client) NewBuilderTemplate(method string, template string) *RequestBuilder {
+	b := c.NewBuilder(method, template)
+
+	t, err := parseURITemplate(b.baseUrl)
+	if err != nil {
+		b.setErr(err)
+		return b
+	}
+
+	b.template = t
+	return b
+}
+
+// WithPathParam sets the value of a variable in the URI template. The value is percent-encoded
+// while expanding the template.
+//
+// The value can be:
+//   - nil, the variable is undefined, it is omitted from the expansion.
+//   - A slice or an array, the variable is a list; []byte is treated as a string.
+//   - A map, the variable is an associative array, the keys are sorted.
+//   - Other values, they are converted to strings.
+//
+// If the builder is not created by NewBuilderTemplate(), the URL given to NewBuilder() is treated as a template
+// once this method is called.
+//
+// Build() returns an error if a variable in the template is not given, except the variables
+// in the form-style query expansions, such as {?page} and {&limit}, which are optional.
+func (x *RequestBuilder) WithPathParam(name string, value any) *RequestBuilder {
+	if x.pathParams == nil {
+		x.pathParams = make(map[string]any)
+	}
+	x.pathParams[name] = value
+	return x
+}
+
+// expandURL returns the URL with the URI template expanded, without the query strings added by WithQuery().
+func (x *RequestBuilder) expandURL() (string, error) {
+	t := x.template
+	if t == nil {
+		if x.pathParams == nil {
+			return x.baseUrl, nil
+		}
+
+		var err error
+		t, err = parseURITemplate(x.baseUrl)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return t.expand(x.pathParams)
+}
+
+// uriTemplate is a parsed URI template of RFC 6570.
+type uriTemplate struct {
+	raw   string
+	parts []uriTemplatePart
+}
+
+// uriTemplatePart is either a literal or an expression.
+type uriTemplatePart struct {
+	literal string
+	op      *uriTemplateOp // nil for a literal.
+	vars    []uriTemplateVar
+}
+
+type uriTemplateVar struct {
+	name      string
+	maxLength int // The prefix modifier, 0 if absent.
+	explode   bool
+}
+
+// uriTemplateOp defines the expansion of an operator, see RFC 6570 appendix A.
+type uriTemplateOp struct {
+	first         string
+	sep           string
+	named         bool
+	ifEmpty       string
+	allowReserved bool
+}
+
+var uriTemplateOps = map[byte]*uriTemplateOp{
+	0:   {"", ",", false, "", false},
+	'+': {"", ",", false, "", true},
+	'.': {".", ".", false, "", false},
+	'/': {"/", "/", false, "", false},
+	';': {";", ";", true, "", false},
+	'?': {"?", "&", true, "=", false},
+	'&': {"&", "&", true, "=", false},
+	'#': {"#", ",", false, "", true},
+}
+
+func parseURITemplate(s string) (*uriTemplate, error) {
+	t := &uriTemplate{raw: s}
+
+	for len(s) > 0 {
+		start := strings.IndexByte(s, '{')
+		literal := s
+		if start >= 0 {
+			literal = s[:start]
+		}
+
+		if strings.IndexByte(literal, '}') >= 0 {
+			return nil, fmt.Errorf("httplib: URI template %q: unexpected '}'", t.raw)
+		}
+
+		if start < 0 {
+			t.parts = append(t.parts, uriTemplatePart{literal: s})
+			break
+		}
+
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("httplib: URI template %q: unclosed expression", t.raw)
+		}
+		end += start
+
+		if start > 0 {
+			t.parts = append(t.parts, uriTemplatePart{literal: s[:start]})
+		}
+
+		part, err := parseURITemplateExpression(s[start+1 : end])
+		if err != nil {
+			return nil, fmt.Errorf("httplib: URI template %q: %w", t.raw, err)
+		}
+		t.parts = append(t.parts, part)
+
+		s = s[end+1:]
+	}
+
+	return t, nil
+}
+
+func parseURITemplateExpression(s string) (uriTemplatePart, error) {
+	var part uriTemplatePart
+	if s == "" {
+		return part, fmt.Errorf("empty expression")
+	}
+
+	var opChar byte
+	if strings.IndexByte("+#./;?&", s[0]) >= 0 {
+		opChar = s[0]
+		s = s[1:]
+	} else if strings.IndexByte("=,!@|", s[0]) >= 0 {
+		return part, fmt.Errorf("reserved operator '%c'", s[0])
+	}
+	part.op = uriTemplateOps[opChar]
+
+	for _, spec := range strings.Split(s, ",") {
+		v := uriTemplateVar{name: spec}
+
+		if strings.HasSuffix(spec, "*") {
+			v.name = spec[:len(spec)-1]
+			v.explode = true
+		} else if i := strings.IndexByte(spec, ':'); i >= 0 {
+			v.name = spec[:i]
+			n, err := strconv.Atoi(spec[i+1:])
+			if err != nil || n <= 0 || n >= 10000 || spec[i+1] == '0' {
+				return part, fmt.Errorf("invalid prefix modifier %q", spec)
+			}
+			v.maxLength = n
+		}
+
+		if !isValidVarName(v.name) {
+			return part, fmt.Errorf("invalid variable name %q", v.name)
+		}
+
+		part.vars = append(part.vars, v)
+	}
+
+	return part, nil
+}
+
+// isValidVarName checks: varname = varchar *( ["."] varchar ), varchar = ALPHA / DIGIT / "_" / pct-encoded .
+func isValidVarName(s string) bool {
+	if s == "" || s[0] == '.' || s[len(s)-1] == '.' {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_':
+		case c == '.':
+			if s[i-1] == '.' {
+				return false
+			}
+		case c == '%':
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				return false
+			}
+			i += 2
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (t *uriTemplate) expand(vars map[string]any) (string, error) {
+	var b strings.Builder
+
+	for _, part := range t.parts {
+		if part.op == nil {
+			b.WriteString(encodeURITemplate(part.literal, true))
+			continue
+		}
+
+		if err := t.expandExpression(&b, part, vars); err != nil {
+			return "", err
+		}
+	}
+
+	return b.String(), nil
+}
+
+func (t *uriTemplate) expandExpression(b *strings.Builder, part uriTemplatePart, vars map[string]any) error {
+	op := part.op
+	optional := op == uriTemplateOps['?'] || op == uriTemplateOps['&']
+	first := true
+
+	for _, v := range part.vars {
+		value, ok := vars[v.name]
+		if !ok && !optional {
+			return fmt.Errorf("httplib: URI template %q: unresolved variable %q", t.raw, v.name)
+		}
+
+		scalar, list, keys, defined := uriTemplateValue(value)
+		if !defined {
+			continue
+		}
+
+		if first {
+			b.WriteString(op.first)
+			first = false
+		} else {
+			b.WriteString(op.sep)
+		}
+
+		switch {
+		case list == nil && keys == nil:
+			if v.maxLength > 0 && utf8.RuneCountInString(scalar) > v.maxLength {
+				scalar = string([]rune(scalar)[:v.maxLength])
+			}
+			writeNamed(b, op, v.name, scalar)
+
+		case v.maxLength > 0:
+			return fmt.Errorf("httplib: URI template %q: prefix modifier can not be applied to the composite variable %q", t.raw, v.name)
+
+		case !v.explode:
+			if op.named {
+				b.WriteString(v.name + "=")
+			}
+			for i, item := range list {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				if keys != nil {
+					b.WriteString(encodeURITemplate(keys[i], op.allowReserved) + ",")
+				}
+				b.WriteString(encodeURITemplate(item, op.allowReserved))
+			}
+
+		default:
+			for i, item := range list {
+				if i > 0 {
+					b.WriteString(op.sep)
+				}
+
+				switch {
+				case keys != nil:
+					b.WriteString(encodeURITemplate(keys[i], op.allowReserved))
+					if op.named && item == "" {
+						b.WriteString(op.ifEmpty)
+					} else {
+						b.WriteString("=" + encodeURITemplate(item, op.allowReserved))
+					}
+				default:
+					writeNamed(b, op, v.name, item)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func writeNamed(b *strings.Builder, op *uriTemplateOp, name, value string) {
+	if op.named {
+		b.WriteString(name)
+		if value == "" {
+			b.WriteString(op.ifEmpty)
+			return
+		}
+		b.WriteByte('=')
+	}
+	b.WriteString(encodeURITemplate(value, op.allowReserved))
+}
+
+// uriTemplateValue converts a value to a string, a list or an associative array (keys with the list of values).
+// defined is false if the value is nil, or is an empty list or an empty associative array.
+func uriTemplateValue(value any) (scalar string, list []string, keys []string, defined bool) {
+	if value == nil {
+		return "", nil, nil, false
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 && rv.Kind() == reflect.Slice {
+			return string(rv.Bytes()), nil, nil, true
+		}
+
+		for i := 0; i < rv.Len(); i++ {
+			list = append(list, toString(rv.Index(i).Interface()))
+		}
+		return "", list, nil, len(list) > 0
+
+	case reflect.Map:
+		m := make(map[string]string, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k := toString(iter.Key().Interface())
+			keys = append(keys, k)
+			m[k] = toString(iter.Value().Interface())
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			list = append(list, m[k])
+		}
+		return "", list, keys, len(keys) > 0
+
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return "", nil, nil, false
+		}
+	}
+
+	return toString(value), nil, nil, true
+}
+
+// encodeURITemplate percent-encodes the characters which are not unreserved.
+// If allowReserved is true, the reserved characters and the pct-encoded triplets are kept.
+func encodeURITemplate(s string, allowReserved bool) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isUnreserved(c):
+			b.WriteByte(c)
+		case allowReserved && strings.IndexByte(":/?#[]@!$&'()*+,;=", c) >= 0:
+			b.WriteByte(c)
+		case allowReserved && c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			b.WriteString(s[i : i+3])
+			i += 2
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		}
+	}
+
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
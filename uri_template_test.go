@@ -0,0 +1,235 @@
+package httplib_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The variables used by the examples of RFC 6570 section 3.2 .
+// The keys of the associative arrays are sorted, so the order differs from the RFC.
+var uriTemplateTestVars = map[string]any{
+	"count":      []string{"one", "two", "three"},
+	"dom":        []string{"example", "com"},
+	"dub":        "me/too",
+	"hello":      "Hello World!",
+	"half":       "50%",
+	"var":        "value",
+	"who":        "fred",
+	"base":       "http://example.com/home/",
+	"path":       "/foo/bar",
+	"list":       []string{"red", "green", "blue"},
+	"keys":       map[string]string{"semi": ";", "dot": ".", "comma": ","},
+	"v":          6,
+	"x":          1024,
+	"y":          768,
+	"empty":      "",
+	"empty_keys": map[string]string{},
+	"undef":      nil,
+}
+
+func expandTestTemplate(template string) *httplib.RequestBuilder {
+	b := httplib.NewBuilderTemplate("GET", template)
+	for k, v := range uriTemplateTestVars {
+		b.WithPathParam(k, v)
+	}
+	return b
+}
+
+func TestURITemplate(t *testing.T) {
+	cases := []struct {
+		template string
+		want     string
+	}{
+		// Level 1.
+		{"{var}", "value"},
+		{"{hello}", "Hello%20World%21"},
+		{"{half}", "50%25"},
+		{"O{empty}X", "OX"},
+		{"O{undef}X", "OX"},
+		{"{x,y}", "1024,768"},
+		{"{x,hello,y}", "1024,Hello%20World%21,768"},
+		{"?{x,empty}", "?1024,"},
+		{"?{x,undef}", "?1024"},
+		{"?{undef,y}", "?768"},
+		{"{var:3}", "val"},
+		{"{var:30}", "value"},
+		{"{list}", "red,green,blue"},
+		{"{list*}", "red,green,blue"},
+		{"{keys}", "comma,%2C,dot,.,semi,%3B"},
+		{"{keys*}", "comma=%2C,dot=.,semi=%3B"},
+
+		// Level 2, reserved expansion.
+		{"{+var}", "value"},
+		{"{+hello}", "Hello%20World!"},
+		{"{+half}", "50%25"},
+		{"{base}index", "http%3A%2F%2Fexample.com%2Fhome%2Findex"},
+		{"{+base}index", "http://example.com/home/index"},
+		{"O{+empty}X", "OX"},
+		{"O{+undef}X", "OX"},
+		{"{+path}/here", "/foo/bar/here"},
+		{"here?ref={+path}", "here?ref=/foo/bar"},
+		{"up{+path}{var}/here", "up/foo/barvalue/here"},
+		{"{+x,hello,y}", "1024,Hello%20World!,768"},
+		{"{+path,x}/here", "/foo/bar,1024/here"},
+		{"{+path:6}/here", "/foo/b/here"},
+		{"{+list}", "red,green,blue"},
+		{"{+keys*}", "comma=,,dot=.,semi=;"},
+
+		// Level 2, fragment expansion.
+		{"{#var}", "#value"},
+		{"{#hello}", "#Hello%20World!"},
+		{"{#half}", "#50%25"},
+		{"foo{#empty}", "foo#"},
+		{"foo{#undef}", "foo"},
+		{"{#x,hello,y}", "#1024,Hello%20World!,768"},
+		{"{#path,x}/here", "#/foo/bar,1024/here"},
+		{"{#path:6}/here", "#/foo/b/here"},
+		{"{#list*}", "#red,green,blue"},
+		{"{#keys}", "#comma,,,dot,.,semi,;"},
+
+		// Level 3, label expansion.
+		{"{.who}", ".fred"},
+		{"{.who,who}", ".fred.fred"},
+		{"{.half,who}", ".50%25.fred"},
+		{"www{.dom*}", "www.example.com"},
+		{"X{.var}", "X.value"},
+		{"X{.empty}", "X."},
+		{"X{.undef}", "X"},
+		{"X{.var:3}", "X.val"},
+		{"X{.list}", "X.red,green,blue"},
+		{"X{.list*}", "X.red.green.blue"},
+		{"X{.keys*}", "X.comma=%2C.dot=..semi=%3B"},
+		{"X{.empty_keys}", "X"},
+
+		// Level 3, path segments.
+		{"{/who}", "/fred"},
+		{"{/who,who}", "/fred/fred"},
+		{"{/half,who}", "/50%25/fred"},
+		{"{/who,dub}", "/fred/me%2Ftoo"},
+		{"{/var}", "/value"},
+		{"{/var,empty}", "/value/"},
+		{"{/var,undef}", "/value"},
+		{"{/var,x}/here", "/value/1024/here"},
+		{"{/var:1,var}", "/v/value"},
+		{"{/list*}", "/red/green/blue"},
+		{"{/list*,path:4}", "/red/green/blue/%2Ffoo"},
+		{"{/keys*}", "/comma=%2C/dot=./semi=%3B"},
+
+		// Level 3, path-style parameters.
+		{"{;who}", ";who=fred"},
+		{"{;half}", ";half=50%25"},
+		{"{;empty}", ";empty"},
+		{"{;v,empty,who}", ";v=6;empty;who=fred"},
+		{"{;v,undef,who}", ";v=6;who=fred"},
+		{"{;x,y}", ";x=1024;y=768"},
+		{"{;x,y,empty}", ";x=1024;y=768;empty"},
+		{"{;x,y,undef}", ";x=1024;y=768"},
+		{"{;hello:5}", ";hello=Hello"},
+		{"{;list}", ";list=red,green,blue"},
+		{"{;list*}", ";list=red;list=green;list=blue"},
+		{"{;keys*}", ";comma=%2C;dot=.;semi=%3B"},
+
+		// Level 3, form-style query.
+		{"{?who}", "?who=fred"},
+		{"{?half}", "?half=50%25"},
+		{"{?x,y}", "?x=1024&y=768"},
+		{"{?x,y,empty}", "?x=1024&y=768&empty="},
+		{"{?x,y,undef}", "?x=1024&y=768"},
+		{"{?var:3}", "?var=val"},
+		{"{?list}", "?list=red,green,blue"},
+		{"{?list*}", "?list=red&list=green&list=blue"},
+		{"{?keys}", "?keys=comma,%2C,dot,.,semi,%3B"},
+		{"{?keys*}", "?comma=%2C&dot=.&semi=%3B"},
+
+		// Level 3, form-style query continuation.
+		{"{&who}", "&who=fred"},
+		{"{&half}", "&half=50%25"},
+		{"?fixed=yes{&x}", "?fixed=yes&x=1024"},
+		{"{&x,y,empty}", "&x=1024&y=768&empty="},
+		{"{&var:3}", "&var=val"},
+		{"{&list*}", "&list=red&list=green&list=blue"},
+		{"{&keys*}", "&comma=%2C&dot=.&semi=%3B"},
+
+		// Literals and non-ASCII.
+		{"/a b/{who}", "/a%20b/fred"},
+		{"/%E4%B8%AD/{who}", "/%E4%B8%AD/fred"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.template, func(t *testing.T) {
+			assert.Equal(t, c.want, expandTestTemplate(c.template).URL())
+		})
+	}
+}
+
+func TestURITemplate_Unicode(t *testing.T) {
+	b := httplib.NewBuilderTemplate("GET", "/{name}/{name:1}").WithPathParam("name", "中文")
+	assert.Equal(t, "/%E4%B8%AD%E6%96%87/%E4%B8%AD", b.URL())
+}
+
+func TestURITemplate_Error(t *testing.T) {
+	templates := []string{
+		"{", "}", "a}{b}", "{}", "{=a}", "{a b}", "{.a.}", "{a..b}", "{a:0}", "{a:10000}", "{a:x}", "{a%2}",
+	}
+
+	for _, tpl := range templates {
+		t.Run(tpl, func(t *testing.T) {
+			b := httplib.NewBuilderTemplate("GET", tpl)
+			_, err := b.Build()
+			assert.Error(t, err)
+			assert.Equal(t, tpl, b.URL())
+		})
+	}
+
+	// Prefix modifier on a composite value.
+	_, err := expandTestTemplate("{list:1}").Build()
+	assert.EqualError(t, err, `httplib: URI template "{list:1}": prefix modifier can not be applied to the composite variable "list"`)
+}
+
+func TestRequestBuilder_WithPathParam(t *testing.T) {
+	s := NewTestServer(http.StatusOK, DefaultBody)
+	defer s.Close()
+
+	c := httplib.NewClient(s.URL + "/api")
+
+	t.Run("ok", func(t *testing.T) {
+		_, err := c.NewBuilderTemplate("GET", "/users/{id}/posts{?page,limit}").
+			WithPathParam("id", "a/b c").
+			WithPathParam("page", 2).
+			WithQuery("q", "v").
+			ReadString()
+		require.NoError(t, err)
+		assert.Equal(t, "/api/users/a%2Fb%20c/posts?page=2&q=v", s.Request.RequestURI)
+	})
+
+	t.Run("unresolved", func(t *testing.T) {
+		b := c.NewBuilderTemplate("GET", "/users/{id}/posts{?page,limit}")
+		assert.Equal(t, s.URL+"/api/users/{id}/posts{?page,limit}", b.URL())
+
+		_, err := b.Build()
+		assert.EqualError(t, err, `httplib: URI template "`+s.URL+`/api/users/{id}/posts{?page,limit}": unresolved variable "id"`)
+
+		_, err = b.ReadString()
+		assert.Error(t, err)
+	})
+
+	t.Run("undefined", func(t *testing.T) {
+		// A nil value is given, the variable is undefined but resolved.
+		req, err := c.NewBuilderTemplate("GET", "/users{/id}").WithPathParam("id", nil).Build()
+		require.NoError(t, err)
+		assert.Equal(t, s.URL+"/api/users", req.URL.String())
+	})
+
+	t.Run("NewBuilder", func(t *testing.T) {
+		// Without path parameters, the URL is not a template.
+		b := httplib.NewBuilder("GET", "http://temp.org/{id}")
+		assert.Equal(t, "http://temp.org/{id}", b.URL())
+
+		b.WithPathParam("id", 1)
+		assert.Equal(t, "http://temp.org/1", b.URL())
+	})
+}
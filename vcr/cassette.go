@@ -0,0 +1,148 @@
+package vcr
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CassetteVersion is the version of the cassette format written by this package.
+const CassetteVersion = 1
+
+// Cassette is the set of the recorded interactions, it is stored in a YAML or JSON file.
+type Cassette struct {
+	Version      int            `json:"version" yaml:"version"`
+	Interactions []*Interaction `json:"interactions" yaml:"interactions"`
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request    *Request  `json:"request" yaml:"request"`
+	Response   *Response `json:"response" yaml:"response"`
+	RecordedAt time.Time `json:"recorded_at" yaml:"recorded_at"`
+}
+
+// Request is a recorded request.
+type Request struct {
+	Method string      `json:"method" yaml:"method"`
+	URL    string      `json:"url" yaml:"url"`
+	Header http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body   Body        `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+// Response is a recorded response.
+type Response struct {
+	Status     string      `json:"status" yaml:"status"`
+	StatusCode int         `json:"status_code" yaml:"status_code"`
+	Proto      string      `json:"proto,omitempty" yaml:"proto,omitempty"`
+	Header     http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body       Body        `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+// Body is a recorded body. The text bodies are stored as strings,
+// the others are stored in base64 with the prefix 'base64:'.
+type Body []byte
+
+const base64Prefix = "base64:"
+
+func (b Body) encode() string {
+	if utf8.Valid(b) && !strings.HasPrefix(string(b), base64Prefix) {
+		return string(b)
+	}
+	return base64Prefix + base64.StdEncoding.EncodeToString(b)
+}
+
+func (b *Body) decode(s string) error {
+	if !strings.HasPrefix(s, base64Prefix) {
+		*b = Body(s)
+		return nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(s[len(base64Prefix):])
+	if err != nil {
+		return err
+	}
+	*b = data
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler .
+func (b Body) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.encode())
+}
+
+// UnmarshalJSON implements json.Unmarshaler .
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return b.decode(s)
+}
+
+// MarshalYAML implements yaml.Marshaler .
+func (b Body) MarshalYAML() (any, error) {
+	return b.encode(), nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler .
+func (b *Body) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+	return b.decode(s)
+}
+
+// LoadCassette reads the cassette from the named file.
+// The file is in JSON if the extension is '.json'; otherwise it is in YAML.
+func LoadCassette(name string) (*Cassette, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c := new(Cassette)
+	if isJSONFile(name) {
+		err = json.Unmarshal(data, c)
+	} else {
+		err = yaml.Unmarshal(data, c)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Save writes the cassette to the named file, the directory is created if it does not exist.
+// The file is in JSON if the extension is '.json'; otherwise it is in YAML.
+func (c *Cassette) Save(name string) error {
+	var data []byte
+	var err error
+	if isJSONFile(name) {
+		data, err = json.MarshalIndent(c, "", "  ")
+	} else {
+		data, err = yaml.Marshal(c)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(name, data, 0o644)
+}
+
+func isJSONFile(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".json")
+}
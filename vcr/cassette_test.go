@@ -0,0 +1,111 @@
+package vcr_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cmstar/go-httplib/vcr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCassette() *vcr.Cassette {
+	return &vcr.Cassette{
+		Version: vcr.CassetteVersion,
+		Interactions: []*vcr.Interaction{
+			{
+				Request: &vcr.Request{
+					Method: "POST",
+					URL:    "http://temp.org/?a=1",
+					Header: http.Header{"Content-Type": {"text/plain"}},
+					Body:   vcr.Body("line1\nline2"),
+				},
+				Response: &vcr.Response{
+					Status:     "200 OK",
+					StatusCode: 200,
+					Proto:      "HTTP/1.1",
+					Header:     http.Header{"X-A": {"1", "2"}},
+					Body:       vcr.Body{0xff, 0x00},
+				},
+				RecordedAt: time.Date(2023, 5, 1, 8, 0, 0, 0, time.UTC),
+			},
+			{
+				Request:    &vcr.Request{Method: "GET", URL: "http://temp.org/"},
+				Response:   &vcr.Response{StatusCode: 204, Body: vcr.Body("base64:looks like base64")},
+				RecordedAt: time.Date(2023, 5, 1, 8, 0, 1, 0, time.UTC),
+			},
+		},
+	}
+}
+
+func TestCassette_Save(t *testing.T) {
+	for _, ext := range []string{".yaml", ".JSON"} {
+		t.Run(ext, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "sub", "a"+ext)
+			c := newTestCassette()
+			require.NoError(t, c.Save(name))
+
+			loaded, err := vcr.LoadCassette(name)
+			require.NoError(t, err)
+			assert.Equal(t, c, loaded)
+		})
+	}
+}
+
+func TestCassette_YAML(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "a.yaml")
+	require.NoError(t, newTestCassette().Save(name))
+
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, `version: 1
+interactions:
+    - request:
+        method: POST
+        url: http://temp.org/?a=1
+        header:
+            Content-Type:
+                - text/plain
+        body: |-
+            line1
+            line2
+      response:
+        status: 200 OK
+        status_code: 200
+        proto: HTTP/1.1
+        header:
+            X-A:
+                - "1"
+                - "2"
+        body: base64:/wA=
+      recorded_at: 2023-05-01T08:00:00Z
+    - request:
+        method: GET
+        url: http://temp.org/
+      response:
+        status: ""
+        status_code: 204
+        body: base64:YmFzZTY0Omxvb2tzIGxpa2UgYmFzZTY0
+      recorded_at: 2023-05-01T08:00:01Z
+`, string(data))
+}
+
+func TestLoadCassette_Error(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := vcr.LoadCassette(filepath.Join(dir, "not-exist.yaml"))
+	assert.Error(t, err)
+
+	name := filepath.Join(dir, "a.yaml")
+	require.NoError(t, os.WriteFile(name, []byte("interactions: [{response: {body: 'base64:!'}}]"), 0o644))
+	_, err = vcr.LoadCassette(name)
+	assert.Error(t, err)
+
+	name = filepath.Join(dir, "a.json")
+	require.NoError(t, os.WriteFile(name, []byte(`{"interactions": [{"response": {"body": 1}}]}`), 0o644))
+	_, err = vcr.LoadCassette(name)
+	assert.Error(t, err)
+}
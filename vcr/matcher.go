@@ -0,0 +1,128 @@
+package vcr
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+
+	"github.com/cmstar/go-httplib"
+)
+
+// Matcher returns true if the request matches the recorded request. body is the body of the request, nil if no body.
+type Matcher func(req *http.Request, body []byte, recorded *Request) bool
+
+// MatchMethod matches the methods of the requests.
+func MatchMethod(req *http.Request, body []byte, recorded *Request) bool {
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	return method == recorded.Method
+}
+
+// MatchURL matches the URLs of the requests, the order of the query parameters is ignored.
+func MatchURL(req *http.Request, body []byte, recorded *Request) bool {
+	u, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+
+	a, b := *req.URL, *u
+	if !reflect.DeepEqual(a.Query(), b.Query()) {
+		return false
+	}
+
+	a.RawQuery, b.RawQuery = "", ""
+	return a.String() == b.String()
+}
+
+// MatchBody matches the bodies of the requests byte by byte.
+// Note that the recorded body may have been modified by the Scrubbers.
+func MatchBody(req *http.Request, body []byte, recorded *Request) bool {
+	return bytes.Equal(body, recorded.Body)
+}
+
+// MatchHeaders returns a Matcher which matches the values of the given headers, the names are case-insensitive.
+// Note that the recorded headers may have been modified by the Scrubbers: the default Scrubber replaces the values
+// of httplib.SensitiveHeaders, such as Authorization and Cookie, so such headers never match unless the Recorder
+// is created with WithoutDefaultScrubbers().
+func MatchHeaders(names ...string) Matcher {
+	return func(req *http.Request, body []byte, recorded *Request) bool {
+		for _, name := range names {
+			if !reflect.DeepEqual(req.Header.Values(name), recorded.Header.Values(name)) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Scrubber modifies an interaction before it is recorded, it is used to remove sensitive data such as credentials.
+// The response sent to the client is not affected.
+type Scrubber func(i *Interaction)
+
+// ScrubHeaders returns a Scrubber which replaces the values of the given headers of the request and the response
+// with httplib.RedactedValue, the names are case-insensitive. If no name is given, httplib.SensitiveHeaders are used.
+func ScrubHeaders(names ...string) Scrubber {
+	if len(names) == 0 {
+		names = httplib.SensitiveHeaders
+	}
+
+	scrub := func(header http.Header) {
+		for _, name := range names {
+			values := header.Values(name)
+			if len(values) == 0 {
+				continue
+			}
+
+			redacted := make([]string, len(values))
+			for i := range redacted {
+				redacted[i] = httplib.RedactedValue
+			}
+			header[http.CanonicalHeaderKey(name)] = redacted
+		}
+	}
+
+	return func(i *Interaction) {
+		scrub(i.Request.Header)
+		scrub(i.Response.Header)
+	}
+}
+
+// ScrubQuery returns a Scrubber which replaces the values of the given query parameters of the request URL
+// with httplib.RedactedValue.
+func ScrubQuery(names ...string) Scrubber {
+	return func(i *Interaction) {
+		u, err := url.Parse(i.Request.URL)
+		if err != nil {
+			return
+		}
+
+		query := u.Query()
+		changed := false
+		for _, name := range names {
+			if values, ok := query[name]; ok {
+				for j := range values {
+					values[j] = httplib.RedactedValue
+				}
+				changed = true
+			}
+		}
+
+		if changed {
+			u.RawQuery = query.Encode()
+			i.Request.URL = u.String()
+		}
+	}
+}
+
+// ScrubBody returns a Scrubber which replaces the matches of the regexp in the bodies of the request and
+// the response with the replacement, which can refer to the submatches as regexp.Regexp.ReplaceAll() does.
+func ScrubBody(re *regexp.Regexp, replacement string) Scrubber {
+	return func(i *Interaction) {
+		i.Request.Body = re.ReplaceAll(i.Request.Body, []byte(replacement))
+		i.Response.Body = re.ReplaceAll(i.Response.Body, []byte(replacement))
+	}
+}
@@ -0,0 +1,184 @@
+package vcr_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/cmstar/go-httplib/vcr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchers(t *testing.T) {
+	recorded := &vcr.Request{
+		Method: "POST",
+		URL:    "http://temp.org/path?a=1&b=2&b=3",
+		Header: http.Header{"X-A": {"1"}, "X-B": {"2"}},
+		Body:   vcr.Body("body"),
+	}
+
+	newRequest := func(method, uri string, header http.Header) *http.Request {
+		req, err := http.NewRequest(method, uri, nil)
+		require.NoError(t, err)
+		req.Header = header
+		return req
+	}
+
+	req := newRequest("POST", "http://temp.org/path?b=2&a=1&b=3", http.Header{"X-A": {"1"}, "X-B": {"x"}})
+	assert.True(t, vcr.MatchMethod(req, nil, recorded))
+	assert.True(t, vcr.MatchURL(req, nil, recorded))
+	assert.True(t, vcr.MatchBody(req, []byte("body"), recorded))
+	assert.False(t, vcr.MatchBody(req, []byte("other"), recorded))
+	assert.True(t, vcr.MatchHeaders("x-a")(req, nil, recorded))
+	assert.False(t, vcr.MatchHeaders("X-A", "X-B")(req, nil, recorded))
+
+	assert.False(t, vcr.MatchMethod(newRequest("GET", "http://temp.org/", nil), nil, recorded))
+	assert.False(t, vcr.MatchURL(newRequest("POST", "http://temp.org/path?a=1&b=3&b=2", nil), nil, recorded))
+	assert.False(t, vcr.MatchURL(newRequest("POST", "http://temp.org/other?a=1&b=2&b=3", nil), nil, recorded))
+	assert.False(t, vcr.MatchURL(newRequest("POST", "https://temp.org/path?a=1&b=2&b=3", nil), nil, recorded))
+}
+
+func TestWithMatchers(t *testing.T) {
+	s, _ := newCountingServer()
+	defer s.Close()
+
+	name := filepath.Join(t.TempDir(), "a.yaml")
+	rec, err := vcr.New(name, vcr.ModeRecord)
+	require.NoError(t, err)
+	send(t, rec, "POST", s.URL, "a")
+	send(t, rec, "POST", s.URL, "b")
+	require.NoError(t, rec.Stop())
+
+	// By default, the body is not matched.
+	rec, err = vcr.New(name, vcr.ModeStrict)
+	require.NoError(t, err)
+	assert.Equal(t, "1 POST a", send(t, rec, "POST", s.URL, "b"))
+
+	rec, err = vcr.New(name, vcr.ModeStrict, vcr.WithMatchers(vcr.MatchMethod, vcr.MatchURL, vcr.MatchBody))
+	require.NoError(t, err)
+	assert.Equal(t, "2 POST b", send(t, rec, "POST", s.URL, "b"))
+	assert.Equal(t, "1 POST a", send(t, rec, "POST", s.URL, "a"))
+}
+
+func TestScrubbers(t *testing.T) {
+	i := &vcr.Interaction{
+		Request: &vcr.Request{
+			Method: "POST",
+			URL:    "http://temp.org/?token=abc&a=1",
+			Header: http.Header{"Authorization": {"Bearer xx"}, "X-Key": {"k1", "k2"}},
+			Body:   vcr.Body(`{"password":"p1","name":"n"}`),
+		},
+		Response: &vcr.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Set-Cookie": {"a=1"}},
+			Body:       vcr.Body(`{"password":"p2"}`),
+		},
+	}
+
+	vcr.ScrubHeaders()(i)
+	vcr.ScrubHeaders("x-key", "X-Not-Exist")(i)
+	vcr.ScrubQuery("token", "not-exist")(i)
+	vcr.ScrubBody(regexp.MustCompile(`("password":)"[^"]*"`), `$1"***"`)(i)
+
+	assert.Equal(t, http.Header{"Authorization": {"***"}, "X-Key": {"***", "***"}}, i.Request.Header)
+	assert.Equal(t, "http://temp.org/?a=1&token=%2A%2A%2A", i.Request.URL)
+	assert.Equal(t, `{"password":"***","name":"n"}`, string(i.Request.Body))
+	assert.Equal(t, http.Header{"Set-Cookie": {"***"}}, i.Response.Header)
+	assert.Equal(t, `{"password":"***"}`, string(i.Response.Body))
+}
+
+func TestWithScrubbers(t *testing.T) {
+	s, _ := newCountingServer()
+	defer s.Close()
+
+	name := filepath.Join(t.TempDir(), "a.yaml")
+	rec, err := vcr.New(name, vcr.ModeRecord, vcr.WithScrubbers(
+		vcr.ScrubHeaders(),
+		vcr.ScrubBody(regexp.MustCompile("secret"), "xxx"),
+	))
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", s.URL, strings.NewReader("secret"))
+	req.Header.Set("Authorization", "Bearer token")
+	res, err := rec.HTTPClient().Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.NoError(t, rec.Stop())
+
+	// The response given to the client is not scrubbed.
+	assert.Equal(t, "1", res.Header.Get("X-Count"))
+
+	c, err := vcr.LoadCassette(name)
+	require.NoError(t, err)
+	require.Len(t, c.Interactions, 1)
+	assert.Equal(t, "***", c.Interactions[0].Request.Header.Get("Authorization"))
+	assert.Equal(t, "xxx", string(c.Interactions[0].Request.Body))
+	assert.Equal(t, "1 POST xxx", string(c.Interactions[0].Response.Body))
+}
+
+func TestDefaultScrubbers(t *testing.T) {
+	s, _ := newCountingServer()
+	defer s.Close()
+
+	record := func(options ...vcr.Option) string {
+		name := filepath.Join(t.TempDir(), "a.yaml")
+		rec, err := vcr.New(name, vcr.ModeRecord, options...)
+		require.NoError(t, err)
+
+		_, err = httplib.NewClient(s.URL).WithHTTPClient(rec.HTTPClient()).WithBasicAuth("user", "secret").
+			NewBuilder("GET", "/").
+			ReadString()
+		require.NoError(t, err)
+		require.NoError(t, rec.Stop())
+
+		data, err := os.ReadFile(name)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	// The credentials are not written to the cassette by default.
+	credentials := base64.StdEncoding.EncodeToString([]byte("user:secret"))
+	assert.NotContains(t, record(), credentials)
+	assert.Contains(t, record(vcr.WithoutDefaultScrubbers()), credentials)
+}
+
+func TestMatchHeaders_Scrubbed(t *testing.T) {
+	s, _ := newCountingServer()
+	defer s.Close()
+
+	send := func(rec *vcr.Recorder, token string) (string, error) {
+		return httplib.NewClient(s.URL).WithHTTPClient(rec.HTTPClient()).WithBearerToken(token).
+			NewBuilder("GET", "/").
+			ReadString()
+	}
+
+	// replay returns the recorded content and the result of the replay.
+	replay := func(options ...vcr.Option) (string, string, error) {
+		name := filepath.Join(t.TempDir(), "a.yaml")
+		rec, err := vcr.New(name, vcr.ModeRecord, options...)
+		require.NoError(t, err)
+		recorded, err := send(rec, "token")
+		require.NoError(t, err)
+		require.NoError(t, rec.Stop())
+
+		options = append(options, vcr.WithMatchers(vcr.MatchMethod, vcr.MatchURL, vcr.MatchHeaders("Authorization")))
+		rec, err = vcr.New(name, vcr.ModeStrict, options...)
+		require.NoError(t, err)
+		content, err := send(rec, "token")
+		return recorded, content, err
+	}
+
+	// The recorded Authorization header is scrubbed by default, it never matches.
+	_, _, err := replay()
+	assert.ErrorIs(t, err, vcr.ErrInteractionNotFound)
+
+	recorded, content, err := replay(vcr.WithoutDefaultScrubbers())
+	require.NoError(t, err)
+	assert.Equal(t, recorded, content)
+}
@@ -0,0 +1,357 @@
+// Package vcr provides an http.RoundTripper which records the HTTP interactions into cassette files,
+// and replays them later, so that the tests depending on real endpoints can run offline and deterministically.
+//
+// Typical usage in a test:
+//
+//	rec, err := vcr.New("testdata/github.yaml", vcr.ModeReplay)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer rec.Stop()
+//
+//	client := httplib.NewClient("https://api.github.com").WithHTTPClient(rec.HTTPClient())
+//
+// On the first run, the cassette does not exist, the requests are sent and recorded; Stop() saves the cassette.
+// Later runs replay the responses from the cassette without accessing the network.
+package vcr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Mode decides how a Recorder handles the requests.
+type Mode string
+
+const (
+	// ModeRecord sends all the requests and records them, the existing interactions of the cassette are discarded.
+	ModeRecord Mode = "record"
+
+	// ModeReplay replays the matched interactions of the cassette, the requests which do not match any interaction
+	// are sent and recorded.
+	ModeReplay Mode = "replay"
+
+	// ModeStrict replays the matched interactions of the cassette, the requests which do not match any interaction
+	// fail with ErrInteractionNotFound. No request is sent.
+	ModeStrict Mode = "strict"
+
+	// ModePassthrough sends all the requests and records nothing, the cassette is not used.
+	ModePassthrough Mode = "passthrough"
+)
+
+// ErrInteractionNotFound is returned in ModeStrict when a request does not match any interaction.
+var ErrInteractionNotFound = errors.New("vcr: interaction not found")
+
+// Option is used to setup a Recorder.
+type Option func(r *Recorder)
+
+// WithTransport sets the http.RoundTripper which sends the requests. The default is http.DefaultTransport.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(r *Recorder) {
+		r.transport = transport
+	}
+}
+
+// WithMatchers sets the Matchers used to find the interaction for a request, a request matches an interaction
+// if all the Matchers return true. The default Matchers are MatchMethod and MatchURL.
+func WithMatchers(matchers ...Matcher) Option {
+	return func(r *Recorder) {
+		r.matchers = matchers
+	}
+}
+
+// WithScrubbers appends Scrubbers which modify the interactions before they are recorded.
+// They run after the default Scrubber, see WithoutDefaultScrubbers().
+func WithScrubbers(scrubbers ...Scrubber) Option {
+	return func(r *Recorder) {
+		r.scrubbers = append(r.scrubbers, scrubbers...)
+	}
+}
+
+// WithoutDefaultScrubbers disables the default Scrubber, which is ScrubHeaders() with httplib.SensitiveHeaders,
+// so that the credentials and the cookies are recorded as they are.
+func WithoutDefaultScrubbers() Option {
+	return func(r *Recorder) {
+		r.noDefaultScrubbers = true
+	}
+}
+
+// Recorder is an http.RoundTripper which records and replays the interactions. It can be used concurrently.
+//
+// The interactions matching a request are replayed in the order they are recorded, each is used once.
+// If all of them have been used, the request is sent and recorded in ModeReplay, so the requests repeated
+// in a recording session get their own responses; in ModeStrict, the last one is replayed again.
+type Recorder struct {
+	name      string
+	mode      Mode
+	transport http.RoundTripper
+	matchers  []Matcher
+	scrubbers []Scrubber
+
+	noDefaultScrubbers bool
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     map[*Interaction]bool
+	changed  bool
+}
+
+// New creates a Recorder with the cassette file of the given name. The file is in JSON if the extension is '.json';
+// otherwise it is in YAML. In ModeReplay and ModeStrict, the cassette is loaded if the file exists; in ModeStrict,
+// it is an error if the file does not exist.
+//
+// By default, the values of httplib.SensitiveHeaders, such as Authorization and Cookie, are replaced with
+// httplib.RedactedValue in the recorded interactions, see WithoutDefaultScrubbers(); so these headers can not be
+// matched by MatchHeaders().
+func New(name string, mode Mode, options ...Option) (*Recorder, error) {
+	r := &Recorder{
+		name:      name,
+		mode:      mode,
+		transport: http.DefaultTransport,
+		matchers:  []Matcher{MatchMethod, MatchURL},
+		cassette:  &Cassette{Version: CassetteVersion},
+		used:      make(map[*Interaction]bool),
+	}
+
+	for _, opt := range options {
+		opt(r)
+	}
+
+	if !r.noDefaultScrubbers {
+		r.scrubbers = append([]Scrubber{ScrubHeaders()}, r.scrubbers...)
+	}
+
+	switch mode {
+	case ModeRecord, ModePassthrough:
+		return r, nil
+
+	case ModeReplay, ModeStrict:
+		c, err := LoadCassette(name)
+		if err == nil {
+			r.cassette = c
+			return r, nil
+		}
+
+		if mode == ModeReplay && errors.Is(err, os.ErrNotExist) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("vcr: load cassette: %w", err)
+
+	default:
+		return nil, fmt.Errorf("vcr: unknown mode %q", mode)
+	}
+}
+
+// Mode returns the mode of the Recorder.
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Cassette returns the cassette which holds the interactions.
+func (r *Recorder) Cassette() *Cassette {
+	return r.cassette
+}
+
+// HTTPClient returns an http.Client which uses the Recorder as the transport.
+func (r *Recorder) HTTPClient() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Stop saves the cassette if any interaction is recorded.
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.changed {
+		return nil
+	}
+
+	if err := r.cassette.Save(r.name); err != nil {
+		return fmt.Errorf("vcr: save cassette: %w", err)
+	}
+	r.changed = false
+	return nil
+}
+
+// RoundTrip implements http.RoundTripper .
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.mode == ModePassthrough {
+		return r.transport.RoundTrip(req)
+	}
+
+	body, req, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.mode != ModeRecord {
+		i, last := r.find(req, body)
+		if i == nil && r.mode == ModeStrict {
+			if last == nil {
+				closeBody(req)
+				return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, req.Method, req.URL)
+			}
+			i = last
+		}
+
+		if i != nil {
+			closeBody(req)
+			return i.Response.toHTTP(req), nil
+		}
+	}
+
+	return r.record(req, body)
+}
+
+// find returns the first unused interaction matching the request and marks it used.
+// If there is no such interaction, returns nil and the last interaction matching the request.
+func (r *Recorder) find(req *http.Request, body []byte) (unused *Interaction, last *Interaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, i := range r.cassette.Interactions {
+		if !r.match(req, body, i.Request) {
+			continue
+		}
+
+		if !r.used[i] {
+			r.used[i] = true
+			return i, nil
+		}
+		last = i
+	}
+	return nil, last
+}
+
+func (r *Recorder) match(req *http.Request, body []byte, recorded *Request) bool {
+	for _, m := range r.matchers {
+		if !m(req, body, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	res, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	i := &Interaction{
+		Request: &Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header.Clone(),
+			Body:   body,
+		},
+		Response: &Response{
+			Status:     res.Status,
+			StatusCode: res.StatusCode,
+			Proto:      res.Proto,
+			Header:     res.Header.Clone(),
+			Body:       resBody,
+		},
+		RecordedAt: time.Now().UTC(),
+	}
+
+	for _, s := range r.scrubbers {
+		s(i)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, i)
+	r.used[i] = true
+	r.changed = true
+	return res, nil
+}
+
+// toHTTP creates an http.Response for the request from the recorded response.
+func (res *Response) toHTTP(req *http.Request) *http.Response {
+	proto := res.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+
+	major, minor, ok := http.ParseHTTPVersion(proto)
+	if !ok {
+		major, minor = 1, 1
+	}
+
+	status := res.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode))
+	}
+
+	header := res.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	return &http.Response{
+		Status:        status,
+		StatusCode:    res.StatusCode,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(res.Body)),
+		ContentLength: int64(len(res.Body)),
+		Request:       req,
+	}
+}
+
+// closeBody closes the request body which is not sent, an http.RoundTripper must always close the body.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// readRequestBody reads the request body without consuming it. Returns nil if there is no body.
+// If the body can not be got again by GetBody, a shallow copy of the request with a new body is returned,
+// since an http.RoundTripper should not modify the request.
+func readRequestBody(req *http.Request) ([]byte, *http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, req, nil
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer body.Close()
+
+		data, err := io.ReadAll(body)
+		return data, req, err
+	}
+
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clone := *req
+	clone.Body = io.NopCloser(bytes.NewReader(data))
+	clone.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return data, &clone, nil
+}
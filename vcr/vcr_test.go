@@ -0,0 +1,217 @@
+package vcr_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cmstar/go-httplib"
+	"github.com/cmstar/go-httplib/vcr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCountingServer creates a server which responds with the number of the requests it received and the request body.
+func newCountingServer() (*httptest.Server, *int32) {
+	var count int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&count, 1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Count", fmt.Sprint(n))
+		fmt.Fprintf(w, "%d %s %s", n, r.Method, body)
+	}))
+	return s, &count
+}
+
+func send(t *testing.T, rec *vcr.Recorder, method, uri, body string) string {
+	content, err := httplib.NewClient("").WithHTTPClient(rec.HTTPClient()).
+		NewBuilder(method, uri).
+		SetStringBody(body).
+		ReadString()
+	require.NoError(t, err)
+	return content
+}
+
+func TestRecorder_Replay(t *testing.T) {
+	s, count := newCountingServer()
+	defer s.Close()
+
+	for _, ext := range []string{".yaml", ".json"} {
+		t.Run(ext, func(t *testing.T) {
+			atomic.StoreInt32(count, 0)
+			name := filepath.Join(t.TempDir(), "cassettes", "a"+ext)
+
+			// The first run records.
+			rec, err := vcr.New(name, vcr.ModeReplay)
+			require.NoError(t, err)
+			assert.Equal(t, "1 GET ", send(t, rec, "GET", s.URL+"/a", ""))
+			assert.Equal(t, "2 GET ", send(t, rec, "GET", s.URL+"/a", ""))
+			assert.Equal(t, "3 POST x", send(t, rec, "POST", s.URL+"/b", "x"))
+			require.NoError(t, rec.Stop())
+			assert.FileExists(t, name)
+
+			// The second run replays, the matched interactions are used in order.
+			rec, err = vcr.New(name, vcr.ModeReplay)
+			require.NoError(t, err)
+			assert.Equal(t, "1 GET ", send(t, rec, "GET", s.URL+"/a", ""))
+			assert.Equal(t, "3 POST x", send(t, rec, "POST", s.URL+"/b", "x"))
+			assert.Equal(t, "2 GET ", send(t, rec, "GET", s.URL+"/a", ""))
+			assert.Equal(t, int32(3), atomic.LoadInt32(count))
+
+			// The new requests are recorded.
+			assert.Equal(t, "4 GET ", send(t, rec, "GET", s.URL+"/a", ""))
+			assert.Equal(t, "5 GET ", send(t, rec, "GET", s.URL+"/c", ""))
+			require.NoError(t, rec.Stop())
+			assert.Len(t, rec.Cassette().Interactions, 5)
+
+			// In strict mode, the last matched interaction is reused.
+			rec, err = vcr.New(name, vcr.ModeStrict)
+			require.NoError(t, err)
+			assert.Equal(t, "5 GET ", send(t, rec, "GET", s.URL+"/c", ""))
+			assert.Equal(t, "5 GET ", send(t, rec, "GET", s.URL+"/c", ""))
+
+			_, err = httplib.NewClient("").WithHTTPClient(rec.HTTPClient()).NewBuilder("GET", s.URL+"/d").ReadString()
+			assert.True(t, errors.Is(err, vcr.ErrInteractionNotFound))
+			assert.Contains(t, err.Error(), "vcr: interaction not found: GET "+s.URL+"/d")
+			assert.Equal(t, int32(5), atomic.LoadInt32(count))
+		})
+	}
+}
+
+func TestRecorder_Response(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Add("X-Multi", "1")
+		w.Header().Add("X-Multi", "2")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte{0xff, 0x00})
+	}))
+	defer s.Close()
+
+	name := filepath.Join(t.TempDir(), "a.yml")
+	rec, err := vcr.New(name, vcr.ModeRecord)
+	require.NoError(t, err)
+
+	res, err := rec.HTTPClient().Get(s.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.NoError(t, rec.Stop())
+	s.Close()
+
+	rec, err = vcr.New(name, vcr.ModeStrict)
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", s.URL, nil)
+	res, err = rec.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, "418 I'm a teapot", res.Status)
+	assert.Equal(t, http.StatusTeapot, res.StatusCode)
+	assert.Equal(t, "HTTP/1.1", res.Proto)
+	assert.Equal(t, 1, res.ProtoMinor)
+	assert.Equal(t, []string{"1", "2"}, res.Header.Values("X-Multi"))
+	assert.Equal(t, int64(2), res.ContentLength)
+	assert.Same(t, req, res.Request)
+
+	body, _ := io.ReadAll(res.Body)
+	assert.Equal(t, []byte{0xff, 0x00}, body)
+}
+
+func TestRecorder_Modes(t *testing.T) {
+	s, count := newCountingServer()
+	defer s.Close()
+
+	name := filepath.Join(t.TempDir(), "a.yaml")
+	rec, err := vcr.New(name, vcr.ModeRecord)
+	require.NoError(t, err)
+	assert.Equal(t, vcr.ModeRecord, rec.Mode())
+	send(t, rec, "GET", s.URL, "")
+	require.NoError(t, rec.Stop())
+
+	t.Run("record", func(t *testing.T) {
+		// The existing interactions are discarded.
+		rec, err := vcr.New(name, vcr.ModeRecord)
+		require.NoError(t, err)
+		assert.Equal(t, "2 GET ", send(t, rec, "GET", s.URL, ""))
+		require.NoError(t, rec.Stop())
+
+		c, err := vcr.LoadCassette(name)
+		require.NoError(t, err)
+		require.Len(t, c.Interactions, 1)
+		assert.Equal(t, "2 GET ", string(c.Interactions[0].Response.Body))
+	})
+
+	t.Run("passthrough", func(t *testing.T) {
+		rec, err := vcr.New(name, vcr.ModePassthrough)
+		require.NoError(t, err)
+		assert.Equal(t, "3 GET ", send(t, rec, "GET", s.URL, ""))
+		require.NoError(t, rec.Stop())
+		assert.Empty(t, rec.Cassette().Interactions)
+	})
+
+	t.Run("no-change", func(t *testing.T) {
+		rec, err := vcr.New(name, vcr.ModeReplay)
+		require.NoError(t, err)
+		assert.Equal(t, "2 GET ", send(t, rec, "GET", s.URL, ""))
+
+		// The file is not written if nothing is recorded.
+		require.NoError(t, os.Remove(name))
+		require.NoError(t, rec.Stop())
+		assert.NoFileExists(t, name)
+	})
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(count))
+}
+
+func TestNew_Error(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := vcr.New(filepath.Join(dir, "not-exist.yaml"), vcr.ModeStrict)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	_, err = vcr.New(filepath.Join(dir, "a.yaml"), "unknown")
+	assert.EqualError(t, err, `vcr: unknown mode "unknown"`)
+
+	bad := filepath.Join(dir, "bad.json")
+	require.NoError(t, os.WriteFile(bad, []byte("{"), 0o644))
+	_, err = vcr.New(bad, vcr.ModeReplay)
+	assert.Error(t, err)
+}
+
+func TestRecorder_Transport(t *testing.T) {
+	var sent []string
+	rt := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		sent = append(sent, string(body))
+		return &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader("ok")),
+		}, nil
+	})
+
+	rec, err := vcr.New(filepath.Join(t.TempDir(), "a.yaml"), vcr.ModeRecord, vcr.WithTransport(rt))
+	require.NoError(t, err)
+
+	// The body is read for recording, and is still sent.
+	req, _ := http.NewRequest("POST", "http://temp.org/", io.NopCloser(strings.NewReader("body")))
+	res, err := rec.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"body"}, sent)
+	assert.Equal(t, "body", string(rec.Cassette().Interactions[0].Request.Body))
+
+	data, _ := io.ReadAll(res.Body)
+	assert.Equal(t, "ok", string(data))
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}